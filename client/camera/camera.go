@@ -0,0 +1,71 @@
+package camera
+
+// Camera is a window of Width x Height world cells whose top-left corner is
+// at (X, Y). It follows a target while staying inside the world whenever the
+// world is larger than the window.
+type Camera struct {
+	X      int
+	Y      int
+	Width  int
+	Height int
+}
+
+func New(width, height int) *Camera {
+	return &Camera{Width: width, Height: height}
+}
+
+func (c *Camera) Resize(width, height int) {
+	c.Width = max(width, 1)
+	c.Height = max(height, 1)
+}
+
+// Follow centres the camera on (x, y) and clamps it to a world of
+// worldWidth x worldHeight. A world smaller than the window stays anchored at
+// the top-left corner.
+func (c *Camera) Follow(x, y, worldWidth, worldHeight int) {
+	c.X = clamp(x-c.Width/2, 0, worldWidth-c.Width)
+	c.Y = clamp(y-c.Height/2, 0, worldHeight-c.Height)
+}
+
+func (c *Camera) Visible(x, y int) bool {
+	return x >= c.X && x < c.X+c.Width && y >= c.Y && y < c.Y+c.Height
+}
+
+// ToScreen converts world coordinates into viewport coordinates.
+func (c *Camera) ToScreen(x, y int) (int, int) {
+	return x - c.X, y - c.Y
+}
+
+// EdgeIndicator places an off-screen position on the closest viewport edge and
+// returns an arrow pointing towards it.
+func (c *Camera) EdgeIndicator(x, y int) (int, int, rune) {
+	sx, sy := c.ToScreen(x, y)
+	ex := clamp(sx, 0, c.Width-1)
+	ey := clamp(sy, 0, c.Height-1)
+
+	dx, dy := abs(sx-ex), abs(sy-ey)
+	switch {
+	case dx >= dy && sx < 0:
+		return ex, ey, '<'
+	case dx >= dy && sx >= c.Width:
+		return ex, ey, '>'
+	case sy < 0:
+		return ex, ey, '^'
+	default:
+		return ex, ey, 'v'
+	}
+}
+
+func clamp(v, lo, hi int) int {
+	if hi < lo {
+		return lo
+	}
+	return min(max(v, lo), hi)
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,70 @@
+package camera
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFollowClampsToWorld(t *testing.T) {
+	c := New(10, 6)
+
+	c.Follow(50, 30, 100, 40)
+	assert.Equal(t, 45, c.X)
+	assert.Equal(t, 27, c.Y)
+
+	c.Follow(1, 1, 100, 40)
+	assert.Equal(t, 0, c.X)
+	assert.Equal(t, 0, c.Y)
+
+	c.Follow(99, 39, 100, 40)
+	assert.Equal(t, 90, c.X)
+	assert.Equal(t, 34, c.Y)
+
+	c.Follow(3, 3, 8, 4)
+	assert.Equal(t, 0, c.X, "small worlds stay anchored")
+	assert.Equal(t, 0, c.Y)
+}
+
+func TestVisibleAndToScreen(t *testing.T) {
+	c := &Camera{X: 10, Y: 5, Width: 10, Height: 6}
+
+	assert.True(t, c.Visible(10, 5))
+	assert.True(t, c.Visible(19, 10))
+	assert.False(t, c.Visible(20, 10))
+	assert.False(t, c.Visible(9, 5))
+
+	sx, sy := c.ToScreen(12, 7)
+	assert.Equal(t, 2, sx)
+	assert.Equal(t, 2, sy)
+}
+
+func TestEdgeIndicator(t *testing.T) {
+	c := &Camera{X: 10, Y: 10, Width: 10, Height: 6}
+
+	tests := []struct {
+		x, y   int
+		sx, sy int
+		glyph  rune
+	}{
+		{x: 2, y: 12, sx: 0, sy: 2, glyph: '<'},
+		{x: 40, y: 12, sx: 9, sy: 2, glyph: '>'},
+		{x: 14, y: 0, sx: 4, sy: 0, glyph: '^'},
+		{x: 14, y: 30, sx: 4, sy: 5, glyph: 'v'},
+		{x: 0, y: 0, sx: 0, sy: 0, glyph: '<'},
+	}
+
+	for _, tt := range tests {
+		sx, sy, glyph := c.EdgeIndicator(tt.x, tt.y)
+		assert.Equal(t, tt.sx, sx)
+		assert.Equal(t, tt.sy, sy)
+		assert.Equal(t, tt.glyph, glyph)
+	}
+}
+
+func TestResizeKeepsPositiveSize(t *testing.T) {
+	c := New(10, 10)
+	c.Resize(0, -3)
+	assert.Equal(t, 1, c.Width)
+	assert.Equal(t, 1, c.Height)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/zainokta/client-server-multiplayer/client/gameclient"
+	"github.com/zainokta/client-server-multiplayer/client/input"
+	"github.com/zainokta/client-server-multiplayer/shared/protocol"
+)
+
+// chatLines is how many chat messages the pane below the board shows.
+const chatLines = 4
+
+// chatPane holds the latest chat messages and the message being typed.
+type chatPane struct {
+	lines  []string
+	typing bool
+	text   string
+}
+
+func (p *chatPane) add(line string) {
+	p.lines = append(p.lines, line)
+	if len(p.lines) > chatLines {
+		p.lines = p.lines[len(p.lines)-chatLines:]
+	}
+}
+
+// key edits the message being typed. It returns the message once Enter is
+// pressed, and leaves the input mode on Enter or Escape.
+func (p *chatPane) key(key input.KeyEvent) (string, bool) {
+	switch key.Key {
+	case input.KeyCtrlC, input.KeyEscape:
+		p.typing = false
+	case input.KeyBackspace:
+		if len(p.text) > 0 {
+			runes := []rune(p.text)
+			p.text = string(runes[:len(runes)-1])
+		}
+	case input.KeyEnter:
+		text := p.text
+		p.typing = false
+		p.text = ""
+		return text, strings.TrimSpace(text) != ""
+	case input.KeyRune:
+		if unicode.IsPrint(key.Rune) && len(p.text)+len(string(key.Rune)) <= protocol.MaxChatLength {
+			p.text += string(key.Rune)
+		}
+	}
+	return "", false
+}
+
+// view returns the pane: the latest messages followed by the input line.
+func (p *chatPane) view() []string {
+	view := make([]string, chatLines+1)
+	copy(view[chatLines-len(p.lines):], p.lines)
+
+	if p.typing {
+		channel, to, _ := parseChat(p.text)
+		target := channel.String()
+		if channel == protocol.ChatWhisper {
+			target = "to " + to
+		}
+		view[chatLines] = fmt.Sprintf("Say (%s): %s_", target, p.text)
+	}
+	return view
+}
+
+// parseChat reads the channel of a typed message. Messages go to the room
+// unless they start with /g for everyone on the server or /w name to whisper.
+func parseChat(text string) (protocol.ChatChannel, string, string) {
+	command, rest, _ := strings.Cut(text, " ")
+	switch command {
+	case "/g":
+		return protocol.ChatGlobal, "", rest
+	case "/w":
+		to, message, _ := strings.Cut(rest, " ")
+		return protocol.ChatWhisper, to, message
+	}
+	return protocol.ChatRoom, "", text
+}
+
+func formatChat(msg gameclient.ChatMessage) string {
+	switch msg.Channel {
+	case protocol.ChatGlobal:
+		return fmt.Sprintf("[global] %s: %s", msg.From, msg.Text)
+	case protocol.ChatWhisper:
+		return fmt.Sprintf("[%s > %s] %s", msg.From, msg.To, msg.Text)
+	case protocol.ChatAnnouncement:
+		return fmt.Sprintf("[server] %s", msg.Text)
+	}
+	return fmt.Sprintf("%s: %s", msg.From, msg.Text)
+}
@@ -0,0 +1,248 @@
+// Command loadtest connects many simulated players to a running server and
+// reports how well it keeps up. Each bot is a headless gameclient that joins
+// a room, walks randomly or follows a script, and pings the server.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zainokta/client-server-multiplayer/client/config"
+	"github.com/zainokta/client-server-multiplayer/client/gameclient"
+	"github.com/zainokta/client-server-multiplayer/client/player"
+)
+
+type options struct {
+	addr     string
+	clients  int
+	firstID  int
+	room     uint
+	duration time.Duration
+	ramp     time.Duration
+	rate     int
+	script   string
+	metrics  string
+	seed     uint64
+}
+
+func main() {
+	var opts options
+	flag.StringVar(&opts.addr, "addr", "127.0.0.1:8000", "UDP address of the server")
+	flag.IntVar(&opts.clients, "clients", 100, "number of simulated clients")
+	flag.IntVar(&opts.firstID, "first-id", 1000, "player ID of the first client, the others count up from it")
+	flag.UintVar(&opts.room, "room", 0, "room to join, 0 for any room with a free seat")
+	flag.DurationVar(&opts.duration, "duration", 30*time.Second, "how long every client plays")
+	flag.DurationVar(&opts.ramp, "ramp", 10*time.Millisecond, "delay between starting two clients")
+	flag.IntVar(&opts.rate, "rate", 10, "moves per second of every client")
+	flag.StringVar(&opts.script, "script", "", "moves to repeat, such as wwddssaa, instead of walking randomly")
+	flag.StringVar(&opts.metrics, "metrics", "", "URL of the server metrics, such as http://127.0.0.1:9100/metrics, to report dropped packets")
+	flag.Uint64Var(&opts.seed, "seed", 1, "seed of the random walks")
+	flag.Parse()
+
+	serverAddr, err := net.ResolveUDPAddr("udp", opts.addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	dropsBefore, err := scrapeDrops(opts.metrics)
+	if err != nil {
+		log.Printf("Cannot read server metrics: %v", err)
+	}
+
+	results := run(ctx, serverAddr, opts)
+
+	dropsAfter, err := scrapeDrops(opts.metrics)
+	if err != nil {
+		log.Printf("Cannot read server metrics: %v", err)
+	}
+
+	results.drops = diffDrops(dropsBefore, dropsAfter)
+	results.write(os.Stdout, opts.metrics != "")
+}
+
+// run starts the clients one every ramp and waits until each has played for
+// the whole duration.
+func run(ctx context.Context, serverAddr *net.UDPAddr, opts options) *results {
+	results := &results{}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	var wg sync.WaitGroup
+	for i := range opts.clients {
+		if i > 0 {
+			select {
+			case <-time.After(opts.ramp):
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		b := newBot(serverAddr, int32(opts.firstID+i), opts, logger)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.play(ctx, opts.duration, results)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// bot is one simulated player.
+type bot struct {
+	client *gameclient.Client
+	rng    *rand.Rand
+	script string
+	rate   int
+	// snapshots counts the snapshots received. Every snapshot carries
+	// exactly one update for the bot's own player, so those are counted.
+	snapshots atomic.Int64
+
+	mu       sync.Mutex
+	position player.Player
+	step     int
+}
+
+func newBot(serverAddr *net.UDPAddr, id int32, opts options, logger *slog.Logger) *bot {
+	b := &bot{
+		rng:    rand.New(rand.NewPCG(opts.seed, uint64(id))),
+		script: opts.script,
+		rate:   max(opts.rate, 1),
+	}
+	b.client = gameclient.New(config.Config{RoomID: uint32(opts.room)},
+		gameclient.WithServerAddr(serverAddr),
+		gameclient.WithPlayerID(id),
+		gameclient.WithName(fmt.Sprintf("bot%d", id)),
+		gameclient.WithLogger(logger),
+		gameclient.WithOnEvent(b.handleEvent),
+		gameclient.WithOnUpdate(func(p player.Player) {
+			if p.ID == id {
+				b.snapshots.Add(1)
+			}
+		}),
+	)
+	return b
+}
+
+// play connects, then moves and pings until the duration is over.
+func (b *bot) play(ctx context.Context, duration time.Duration, results *results) {
+	started := time.Now()
+	if err := b.client.Connect(ctx); err != nil {
+		results.failed(err)
+		return
+	}
+	defer b.client.Close()
+	results.connected(time.Since(started))
+
+	welcome := b.client.Welcome()
+	b.mu.Lock()
+	b.position = player.Player{ID: b.client.PlayerID(), X: welcome.SpawnX, Y: welcome.SpawnY}
+	b.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		b.ping(ctx, results)
+	}()
+
+	playing := time.Now()
+	b.snapshots.Store(0)
+	moves := time.NewTicker(time.Second / time.Duration(b.rate))
+	defer moves.Stop()
+	for ctx.Err() == nil {
+		select {
+		case <-moves.C:
+			b.move()
+		case <-ctx.Done():
+		}
+	}
+	wg.Wait()
+
+	results.received(float64(b.snapshots.Load()) / time.Since(playing).Seconds())
+}
+
+func (b *bot) ping(ctx context.Context, results *results) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		rtt, err := b.client.Ping(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		results.pinged(rtt, err)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// move takes the next step of the script, or a random step, and sends it.
+func (b *bot) move() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := "wasd"[b.rng.IntN(4)]
+	if b.script != "" {
+		key = b.script[b.step%len(b.script)]
+		b.step++
+	}
+
+	next := b.position
+	switch key {
+	case 'w':
+		next.Y--
+	case 's':
+		next.Y++
+	case 'a':
+		next.X--
+	case 'd':
+		next.X++
+	}
+	if !b.client.World().Contains(next.X, next.Y) {
+		return
+	}
+
+	sent, err := b.client.Send(next)
+	if err != nil {
+		return
+	}
+	b.position = sent
+}
+
+// handleEvent follows the corrections of the server, like the terminal
+// client does.
+func (b *bot) handleEvent(e gameclient.Event) {
+	if e.Type != gameclient.EventCorrection {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if e.Player.ID == b.position.ID {
+		b.position.X, b.position.Y = e.Player.X, e.Player.Y
+	}
+}
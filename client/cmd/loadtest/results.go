@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// results collects what every bot measured.
+type results struct {
+	mu            sync.Mutex
+	connects      []time.Duration
+	failures      map[string]int
+	rtts          []time.Duration
+	lostPings     int
+	snapshotRates []float64
+	drops         map[string]float64
+}
+
+func (r *results) connected(took time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.connects = append(r.connects, took)
+}
+
+func (r *results) failed(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failures == nil {
+		r.failures = make(map[string]int)
+	}
+	r.failures[err.Error()]++
+}
+
+func (r *results) pinged(rtt time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.lostPings++
+		return
+	}
+	r.rtts = append(r.rtts, rtt)
+}
+
+func (r *results) received(perSecond float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.snapshotRates = append(r.snapshotRates, perSecond)
+}
+
+// percentile returns the nearest-rank percentile p, from 0 to 100, of
+// values, or zero when there are none.
+func percentile[T int64 | float64 | time.Duration](values []T, p float64) T {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	rank := int(p/100*float64(len(sorted))+0.5) - 1
+	return sorted[min(max(rank, 0), len(sorted)-1)]
+}
+
+func (r *results) write(w io.Writer, withDrops bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	failed := 0
+	for _, n := range r.failures {
+		failed += n
+	}
+	total := len(r.connects) + failed
+	rate := 0.0
+	if total > 0 {
+		rate = 100 * float64(len(r.connects)) / float64(total)
+	}
+
+	fmt.Fprintf(w, "Clients:   %d connected, %d failed (%.1f%% success)\n", len(r.connects), failed, rate)
+	for _, reason := range sortedKeys(r.failures) {
+		fmt.Fprintf(w, "           %d x %s\n", r.failures[reason], reason)
+	}
+	fmt.Fprintf(w, "Connect:   p50 %v  p90 %v  p99 %v\n",
+		percentile(r.connects, 50), percentile(r.connects, 90), percentile(r.connects, 99))
+	fmt.Fprintf(w, "RTT:       p50 %v  p90 %v  p99 %v  (%d pings, %d lost)\n",
+		percentile(r.rtts, 50), percentile(r.rtts, 90), percentile(r.rtts, 99), len(r.rtts)+r.lostPings, r.lostPings)
+
+	mean := 0.0
+	for _, perSecond := range r.snapshotRates {
+		mean += perSecond / float64(len(r.snapshotRates))
+	}
+	fmt.Fprintf(w, "Snapshots: %.1f/s per client on average, p10 %.1f/s\n", mean, percentile(r.snapshotRates, 10))
+
+	if !withDrops {
+		return
+	}
+	if len(r.drops) == 0 {
+		fmt.Fprintln(w, "Dropped:   none")
+		return
+	}
+	var drops []string
+	for _, reason := range sortedKeys(r.drops) {
+		drops = append(drops, fmt.Sprintf("%s %.0f", reason, r.drops[reason]))
+	}
+	fmt.Fprintf(w, "Dropped:   %s\n", strings.Join(drops, ", "))
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// scrapeDrops reads the dropped packet counters of the server by reason. It
+// does nothing without a URL.
+func scrapeDrops(url string) (map[string]float64, error) {
+	if url == "" {
+		return nil, nil
+	}
+
+	client := http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("metrics answered %s", resp.Status)
+	}
+	return parseDrops(resp.Body)
+}
+
+// parseDrops reads game_packets_dropped_total from the Prometheus text
+// format.
+func parseDrops(r io.Reader) (map[string]float64, error) {
+	const prefix = `game_packets_dropped_total{reason="`
+
+	drops := make(map[string]float64)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line, found := strings.CutPrefix(scanner.Text(), prefix)
+		if !found {
+			continue
+		}
+
+		reason, value, found := strings.Cut(line, `"} `)
+		if !found {
+			continue
+		}
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", reason, err)
+		}
+		drops[reason] = n
+	}
+	return drops, scanner.Err()
+}
+
+// diffDrops returns how many packets were dropped between two scrapes.
+func diffDrops(before, after map[string]float64) map[string]float64 {
+	drops := make(map[string]float64)
+	for reason, n := range after {
+		if n > before[reason] {
+			drops[reason] = n - before[reason]
+		}
+	}
+	return drops
+}
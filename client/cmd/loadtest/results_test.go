@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPercentile(t *testing.T) {
+	values := []time.Duration{5, 1, 4, 2, 3, 10, 9, 8, 7, 6}
+	assert.Equal(t, time.Duration(5), percentile(values, 50))
+	assert.Equal(t, time.Duration(9), percentile(values, 90))
+	assert.Equal(t, time.Duration(10), percentile(values, 99))
+	assert.Equal(t, time.Duration(1), percentile(values, 0))
+	assert.Equal(t, time.Duration(5), values[0], "values are left unsorted")
+	assert.Zero(t, percentile([]float64(nil), 50))
+}
+
+func TestParseDrops(t *testing.T) {
+	metrics := `# HELP game_packets_dropped_total Packets dropped before reaching a game.
+# TYPE game_packets_dropped_total counter
+game_packets_dropped_total{reason="no_session"} 7
+game_packets_dropped_total{reason="outdated"} 12
+game_packets_sent_total{type="pong"} 3
+`
+	drops, err := parseDrops(strings.NewReader(metrics))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]float64{"no_session": 7, "outdated": 12}, drops)
+
+	assert.Equal(t, map[string]float64{"outdated": 10}, diffDrops(map[string]float64{"no_session": 7, "outdated": 2}, drops))
+}
+
+func TestWriteResults(t *testing.T) {
+	r := &results{drops: map[string]float64{"outdated": 3}}
+	r.connected(2 * time.Millisecond)
+	r.connected(4 * time.Millisecond)
+	r.failed(errors.New("room is full"))
+	r.pinged(time.Millisecond, nil)
+	r.pinged(0, errors.New("ping timed out"))
+	r.received(20)
+	r.received(10)
+
+	var out strings.Builder
+	r.write(&out, true)
+	assert.Contains(t, out.String(), "2 connected, 1 failed (66.7% success)")
+	assert.Contains(t, out.String(), "1 x room is full")
+	assert.Contains(t, out.String(), "(2 pings, 1 lost)")
+	assert.Contains(t, out.String(), "Snapshots: 15.0/s per client on average")
+	assert.Contains(t, out.String(), "Dropped:   outdated 3")
+}
@@ -1,6 +1,53 @@
 package config
 
+import (
+	"time"
+
+	"github.com/zainokta/client-server-multiplayer/shared/netsim"
+)
+
 type Config struct {
-	Port         int `env:"PORT" envDefault:"8000"`
+	Port int `env:"PORT" envDefault:"8000"`
+	// GameTickRate is how many frames per second the client renders.
 	GameTickRate int `env:"GAME_TICK_RATE" envDefault:"30"`
+	// InputRate is how many times per second the player is sent to the
+	// server, independently of the server tick and snapshot rates.
+	InputRate          int           `env:"INPUT_RATE" envDefault:"15"`
+	MoveRepeatInterval time.Duration `env:"MOVE_REPEAT_INTERVAL" envDefault:"100ms"`
+	// RoomID is the room to join right away. Zero opens the lobby instead.
+	RoomID uint32 `env:"ROOM_ID" envDefault:"0"`
+	// PlayerName is the display name, unique on the server. When empty the
+	// name is derived from the player ID.
+	PlayerName string `env:"PLAYER_NAME"`
+	// PlayerColor is one of red, green, yellow, blue, magenta, cyan or white.
+	PlayerColor string `env:"PLAYER_COLOR"`
+	// LogLevel is the lowest level logged: debug, info, warn or error. Every
+	// packet is logged at debug.
+	LogLevel string `env:"LOG_LEVEL" envDefault:"warn"`
+	// LogFormat is text or json.
+	LogFormat string `env:"LOG_FORMAT" envDefault:"text"`
+	// LogFile is where logs are written. When empty they go to stderr, on top
+	// of the game.
+	LogFile string `env:"LOG_FILE"`
+	// The NetSim settings degrade every packet sent to the server to test
+	// bad networks locally. Loss, duplicate and reorder are shares from 0 to
+	// 1, and the same seed makes the same decisions.
+	NetSimLatency   time.Duration `env:"NETSIM_LATENCY" envDefault:"0"`
+	NetSimJitter    time.Duration `env:"NETSIM_JITTER" envDefault:"0"`
+	NetSimLoss      float64       `env:"NETSIM_LOSS" envDefault:"0"`
+	NetSimDuplicate float64       `env:"NETSIM_DUPLICATE" envDefault:"0"`
+	NetSimReorder   float64       `env:"NETSIM_REORDER" envDefault:"0"`
+	NetSimSeed      uint64        `env:"NETSIM_SEED" envDefault:"1"`
+}
+
+// NetworkConditions returns the simulated network conditions.
+func (c Config) NetworkConditions() netsim.Conditions {
+	return netsim.Conditions{
+		Latency:   c.NetSimLatency,
+		Jitter:    c.NetSimJitter,
+		Loss:      c.NetSimLoss,
+		Duplicate: c.NetSimDuplicate,
+		Reorder:   c.NetSimReorder,
+		Seed:      c.NetSimSeed,
+	}
 }
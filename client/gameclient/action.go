@@ -0,0 +1,51 @@
+package gameclient
+
+import (
+	"time"
+
+	"github.com/zainokta/client-server-multiplayer/shared/protocol"
+)
+
+// Tag tries to tag the player target. The server judges the tag against
+// where target was when it was drawn here, so the age of its last update is
+// sent along. The outcome comes back as EventActionResult, to the target as
+// well when it is hit.
+func (c *Client) Tag(target int32) error {
+	var viewDelay time.Duration
+	if p, exists := c.players.Load(target); exists {
+		viewDelay = c.clock.Since(time.UnixMilli(p.Timestamp))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		return ErrNotConnected
+	}
+
+	c.actionSequence++
+	data, err := protocol.Encode(protocol.MsgAction, protocol.Action{
+		PlayerID:    c.playerID,
+		Kind:        protocol.ActionTag,
+		Target:      target,
+		Sequence:    c.actionSequence,
+		ViewDelayMs: uint16(min(max(viewDelay.Milliseconds(), 0), 1<<16-1)),
+	})
+	if err != nil {
+		return err
+	}
+
+	c.log.Debug("Sending action", "seq", c.actionSequence, "target", target, "view_delay", viewDelay)
+	_, err = c.conn.Write(data)
+	return err
+}
+
+func (c *Client) handleActionResult(data []byte) {
+	var result protocol.ActionResult
+	if err := protocol.Decode(data, protocol.MsgActionResult, &result); err != nil {
+		c.emit(Event{Type: EventError, Err: err})
+		return
+	}
+
+	c.emit(Event{Type: EventActionResult, Action: result})
+}
@@ -0,0 +1,54 @@
+package gameclient
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zainokta/client-server-multiplayer/client/config"
+	"github.com/zainokta/client-server-multiplayer/client/player"
+	"github.com/zainokta/client-server-multiplayer/shared/clock"
+	"github.com/zainokta/client-server-multiplayer/shared/protocol"
+)
+
+func TestClientTag(t *testing.T) {
+	serverConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	assert.NoError(t, err)
+	defer serverConn.Close()
+
+	clk := clock.NewFake(time.Unix(1000, 0))
+	c := New(config.Config{}, WithServerAddr(serverConn.LocalAddr().(*net.UDPAddr)), WithPlayerID(1), WithClock(clk))
+	assert.ErrorIs(t, c.Tag(2), ErrNotConnected)
+
+	go answerHello(t, serverConn, protocol.Welcome{PlayerID: 1})
+	assert.NoError(t, c.Connect(context.Background()))
+	defer c.Close()
+
+	c.Players().Store(player.Player{ID: 2, X: 6, Y: 5, Timestamp: clk.Now().UnixMilli()})
+	clk.Advance(80 * time.Millisecond)
+	assert.NoError(t, c.Tag(2))
+
+	serverConn.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 1024)
+	n, clientAddr := readMessage(t, serverConn, buf, protocol.MsgAction)
+
+	var action protocol.Action
+	assert.NoError(t, protocol.Decode(buf[:n], protocol.MsgAction, &action))
+	assert.Equal(t, int32(1), action.PlayerID)
+	assert.Equal(t, protocol.ActionTag, action.Kind)
+	assert.Equal(t, int32(2), action.Target)
+	assert.Equal(t, uint32(1), action.Sequence)
+	assert.Equal(t, uint16(80), action.ViewDelayMs, "the age of the target's state is sent")
+
+	result := protocol.ActionResult{PlayerID: 1, Kind: protocol.ActionTag, Target: 2, Sequence: 1, Hit: true, RewindMs: 120}
+	data, err := protocol.Encode(protocol.MsgActionResult, result)
+	assert.NoError(t, err)
+	_, err = serverConn.WriteToUDP(data, clientAddr)
+	assert.NoError(t, err)
+
+	e := nextEvent(t, c)
+	assert.Equal(t, EventActionResult, e.Type)
+	assert.Equal(t, result, e.Action)
+}
@@ -0,0 +1,91 @@
+package gameclient
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/zainokta/client-server-multiplayer/shared/protocol"
+)
+
+var (
+	ErrChatEmpty       = errors.New("message is empty")
+	ErrChatTooLong     = errors.New("message is too long")
+	ErrChatRateLimited = errors.New("sending messages too fast")
+	ErrNoSuchPlayer    = errors.New("no such player")
+)
+
+// ChatMessage is a chat message relayed by the server. To is only set for
+// whispers.
+type ChatMessage struct {
+	Channel protocol.ChatChannel
+	From    string
+	To      string
+	Text    string
+}
+
+// Say sends a chat message to the room, to every player on the server, or as
+// a whisper to the player named to. The message comes back as EventChat once
+// the server relays it, or as EventChatRejected.
+func (c *Client) Say(channel protocol.ChatChannel, to, text string) error {
+	conn, err := c.connection()
+	if err != nil {
+		return err
+	}
+
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return ErrChatEmpty
+	}
+	if len(text) > protocol.MaxChatLength {
+		return ErrChatTooLong
+	}
+
+	chat := protocol.Chat{Channel: channel}
+	if channel == protocol.ChatWhisper {
+		if chat.To, err = protocol.ParseName(to); err != nil {
+			return err
+		}
+	}
+
+	data, err := protocol.EncodeChat(chat, text)
+	if err != nil {
+		return err
+	}
+	return c.sendReliable(conn, data)
+}
+
+func (c *Client) handleChat(data []byte) {
+	chat, text, err := protocol.DecodeChat(data)
+	if err != nil {
+		c.emit(Event{Type: EventError, Err: err})
+		return
+	}
+
+	c.emit(Event{Type: EventChat, Chat: ChatMessage{
+		Channel: chat.Channel,
+		From:    chat.From.String(),
+		To:      chat.To.String(),
+		Text:    text,
+	}})
+}
+
+func (c *Client) handleChatRejected(data []byte) {
+	var rejected protocol.ChatRejected
+	if err := protocol.Decode(data, protocol.MsgChatRejected, &rejected); err != nil {
+		c.emit(Event{Type: EventError, Err: err})
+		return
+	}
+
+	err := errors.New(rejected.Reason.String())
+	switch rejected.Reason {
+	case protocol.ChatEmpty:
+		err = ErrChatEmpty
+	case protocol.ChatTooLong:
+		err = ErrChatTooLong
+	case protocol.ChatRateLimited:
+		err = ErrChatRateLimited
+	case protocol.ChatNoSuchPlayer:
+		err = ErrNoSuchPlayer
+	}
+	c.emit(Event{Type: EventChatRejected, Err: err})
+}
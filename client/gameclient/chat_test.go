@@ -0,0 +1,80 @@
+package gameclient
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zainokta/client-server-multiplayer/client/config"
+	"github.com/zainokta/client-server-multiplayer/shared/protocol"
+	"github.com/zainokta/client-server-multiplayer/shared/reliable"
+)
+
+func TestClientSay(t *testing.T) {
+	serverConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	assert.NoError(t, err)
+	defer serverConn.Close()
+
+	c := New(config.Config{}, WithServerAddr(serverConn.LocalAddr().(*net.UDPAddr)), WithPlayerID(1))
+	assert.ErrorIs(t, c.Say(protocol.ChatRoom, "", "hi"), ErrNotConnected)
+
+	go answerHello(t, serverConn, protocol.Welcome{PlayerID: 1})
+	assert.NoError(t, c.Connect(context.Background()))
+	defer c.Close()
+
+	assert.ErrorIs(t, c.Say(protocol.ChatRoom, "", "  "), ErrChatEmpty)
+	assert.ErrorIs(t, c.Say(protocol.ChatRoom, "", strings.Repeat("a", protocol.MaxChatLength+1)), ErrChatTooLong)
+	assert.ErrorIs(t, c.Say(protocol.ChatWhisper, "", "hi"), protocol.ErrName)
+
+	assert.NoError(t, c.Say(protocol.ChatWhisper, "bob", " hi bob "))
+
+	serverConn.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 1024)
+	n, clientAddr := readMessage(t, serverConn, buf, protocol.MsgReliable)
+
+	seq, inner, err := protocol.DecodeReliable(buf[:n])
+	assert.NoError(t, err)
+	chat, text, err := protocol.DecodeChat(inner)
+	assert.NoError(t, err)
+	assert.Equal(t, protocol.ChatWhisper, chat.Channel)
+	assert.Equal(t, "bob", chat.To.String())
+	assert.Equal(t, "hi bob", text)
+
+	n, _ = readMessage(t, serverConn, buf, protocol.MsgReliable)
+	resent, _, err := protocol.DecodeReliable(buf[:n])
+	assert.NoError(t, err)
+	assert.Equal(t, seq, resent, "unacknowledged messages are resent")
+
+	ack, err := protocol.Encode(protocol.MsgAck, protocol.Ack{Seq: seq})
+	assert.NoError(t, err)
+	_, err = serverConn.WriteToUDP(ack, clientAddr)
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool { return c.reliable.Pending() == 0 }, time.Second, 10*time.Millisecond)
+
+	server := reliable.NewEndpoint()
+	from, err := protocol.ParseName("alice")
+	assert.NoError(t, err)
+	relayed, err := protocol.EncodeChat(protocol.Chat{Channel: protocol.ChatGlobal, From: from}, "hello")
+	assert.NoError(t, err)
+	rejected, err := protocol.Encode(protocol.MsgChatRejected, protocol.ChatRejected{Reason: protocol.ChatRateLimited})
+	assert.NoError(t, err)
+
+	for _, inner := range [][]byte{relayed, rejected} {
+		data, err := server.Wrap(inner, time.Now())
+		assert.NoError(t, err)
+		_, err = serverConn.WriteToUDP(data, clientAddr)
+		assert.NoError(t, err)
+	}
+
+	e := nextEvent(t, c)
+	assert.Equal(t, EventChat, e.Type)
+	assert.Equal(t, ChatMessage{Channel: protocol.ChatGlobal, From: "alice", Text: "hello"}, e.Chat)
+
+	e = nextEvent(t, c)
+	assert.Equal(t, EventChatRejected, e.Type)
+	assert.ErrorIs(t, e.Err, ErrChatRateLimited)
+}
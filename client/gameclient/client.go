@@ -0,0 +1,862 @@
+package gameclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zainokta/client-server-multiplayer/client/config"
+	"github.com/zainokta/client-server-multiplayer/client/player"
+	"github.com/zainokta/client-server-multiplayer/client/world"
+	"github.com/zainokta/client-server-multiplayer/shared/clock"
+	"github.com/zainokta/client-server-multiplayer/shared/netsim"
+	"github.com/zainokta/client-server-multiplayer/shared/protocol"
+	"github.com/zainokta/client-server-multiplayer/shared/reliable"
+)
+
+const (
+	updateBufferSize = 64
+	eventBufferSize  = 16
+
+	HandshakeTimeout       = 3 * time.Second
+	HandshakeRetryInterval = 250 * time.Millisecond
+)
+
+var (
+	ErrNotConnected     = errors.New("client not connected")
+	ErrAlreadyConnected = errors.New("client already connected")
+	ErrHandshakeTimeout = errors.New("handshake timed out")
+	ErrRoomFull         = errors.New("room is full")
+	ErrNoSuchRoom       = errors.New("room does not exist")
+	ErrNameTaken        = errors.New("name is taken")
+	ErrInvalidName      = errors.New("name is invalid")
+	ErrIDTaken          = errors.New("player ID is taken")
+	ErrTooManyRooms     = errors.New("too many rooms")
+	ErrKicked           = errors.New("kicked by the server")
+	ErrBanned           = errors.New("banned from the server")
+	ErrConnectionLost   = errors.New("connection to the server lost")
+)
+
+type EventType int
+
+const (
+	EventPlayerJoined EventType = iota
+	EventPlayerLeft
+	EventCorrection
+	EventError
+	EventQueueStatus
+	EventPlayerInfo
+	EventChat
+	EventChatRejected
+	EventDisconnected
+	EventActionResult
+)
+
+// Event reports something other than a plain world update. For
+// EventCorrection, Player holds the position the server kept for the local
+// player and Reason says why the move was rejected. EventQueueStatus carries
+// the matchmaking progress in Queue and EventPlayerInfo the player's Profile.
+// EventChat carries a chat message in Chat, and EventChatRejected says in Err
+// why a message sent with Say was not delivered. EventDisconnected means the
+// server removed the client from its room, or never acknowledged a reliable
+// message, with the reason in Err.
+// EventActionResult carries the outcome of a Tag in Action.
+type Event struct {
+	Type    EventType
+	Player  player.Player
+	Reason  protocol.CorrectionReason
+	Queue   protocol.QueueStatus
+	Profile player.Profile
+	Chat    ChatMessage
+	Action  protocol.ActionResult
+	Err     error
+}
+
+type Option func(*Client)
+
+// WithServerAddr overrides the server address. Defaults to 127.0.0.1 on the configured port.
+func WithServerAddr(addr *net.UDPAddr) Option {
+	return func(c *Client) {
+		c.serverAddr = addr
+	}
+}
+
+// WithPlayerID overrides the randomly chosen ID the client joins with.
+func WithPlayerID(id int32) Option {
+	return func(c *Client) {
+		c.playerID = id
+	}
+}
+
+// WithRoomID overrides the configured room the client joins. Zero lets the
+// server pick one.
+func WithRoomID(id uint32) Option {
+	return func(c *Client) {
+		c.roomID = id
+	}
+}
+
+// WithName overrides the configured display name.
+func WithName(name string) Option {
+	return func(c *Client) {
+		c.name = name
+	}
+}
+
+// WithColor overrides the configured color.
+func WithColor(color protocol.Color) Option {
+	return func(c *Client) {
+		c.color = color
+	}
+}
+
+// WithOnUpdate registers a callback invoked from the receive goroutine for every world update.
+func WithOnUpdate(fn func(p player.Player)) Option {
+	return func(c *Client) {
+		c.onUpdate = append(c.onUpdate, fn)
+	}
+}
+
+// WithOnEvent registers a callback invoked from the receive goroutine for every event.
+func WithOnEvent(fn func(e Event)) Option {
+	return func(c *Client) {
+		c.onEvent = append(c.onEvent, fn)
+	}
+}
+
+// WithNetworkConditions degrades every packet the client sends as described
+// by conditions, overriding the conditions of the config.
+func WithNetworkConditions(conditions netsim.Conditions) Option {
+	return func(c *Client) {
+		c.conditions = conditions
+	}
+}
+
+// WithClock sets the clock that stamps updates, ages the players seen and
+// times handshakes, lobby requests, reliable resends and pings. Defaults to
+// clock.Real.
+func WithClock(c clock.Clock) Option {
+	return func(cl *Client) {
+		cl.clock = c
+	}
+}
+
+// WithLogger sets the logger of the client. Defaults to slog.Default.
+func WithLogger(l *slog.Logger) Option {
+	return func(c *Client) {
+		c.log = l
+	}
+}
+
+// Client is a headless connection to the game server. It keeps its own
+// player store and never touches the terminal.
+type Client struct {
+	serverAddr *net.UDPAddr
+	playerID   int32
+	roomID     uint32
+	name       string
+	color      protocol.Color
+	onUpdate   []func(p player.Player)
+	onEvent    []func(e Event)
+	log        *slog.Logger
+	clock      clock.Clock
+	conditions netsim.Conditions
+
+	players *player.Store
+	updates chan player.Player
+	events  chan Event
+	joined  chan joinResult
+	// reliable delivers player metadata and chat in order despite packet
+	// loss.
+	reliable *reliable.Endpoint
+	// Lobby replies, each read by the request waiting for it.
+	roomLists chan []protocol.RoomEntry
+	created   chan lobbyReply[protocol.RoomCreated]
+	matched   chan lobbyReply[protocol.MatchFound]
+	// pongs answer the latest Ping.
+	pongs        chan protocol.Ping
+	pingSequence atomic.Uint32
+	welcome      protocol.Welcome
+	world        *world.World
+
+	// joinMu guards the handshake state shared by the receive goroutine and
+	// JoinRoom.
+	joinMu         sync.Mutex
+	joinRoom       uint32
+	joining        *world.World
+	joiningWelcome protocol.Welcome
+	joinDone       bool
+	switching      bool
+
+	mu       sync.Mutex
+	conn     net.Conn
+	sequence uint32
+	// actionSequence numbers actions apart from player updates.
+	actionSequence uint32
+	wg             sync.WaitGroup
+}
+
+func New(cfg config.Config, opts ...Option) *Client {
+	c := &Client{
+		serverAddr: &net.UDPAddr{Port: cfg.Port, IP: net.ParseIP("127.0.0.1")},
+		playerID:   player.NewID(),
+		roomID:     cfg.RoomID,
+		name:       cfg.PlayerName,
+		conditions: cfg.NetworkConditions(),
+		reliable:   reliable.NewEndpoint(),
+		updates:    make(chan player.Player, updateBufferSize),
+		events:     make(chan Event, eventBufferSize),
+		joined:     make(chan joinResult, 1),
+		roomLists:  make(chan []protocol.RoomEntry, 1),
+		created:    make(chan lobbyReply[protocol.RoomCreated], 1),
+		matched:    make(chan lobbyReply[protocol.MatchFound], 1),
+		pongs:      make(chan protocol.Ping, 1),
+		log:        slog.Default(),
+		clock:      clock.Real,
+	}
+
+	c.color, _ = protocol.ParseColor(cfg.PlayerColor)
+
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.players = player.NewStore(player.WithClock(c.clock))
+
+	if c.name == "" {
+		c.name = fmt.Sprintf("player%d", c.playerID)
+	}
+	c.log = c.log.With("player", c.playerID, "server", c.serverAddr.String())
+
+	return c
+}
+
+// Dial opens the connection and starts receiving until ctx is cancelled or
+// Close is called. The client waits in the lobby until it joins a room.
+func (c *Client) Dial(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn != nil {
+		return ErrAlreadyConnected
+	}
+
+	udpConn, err := net.DialUDP("udp", nil, c.serverAddr)
+	if err != nil {
+		return err
+	}
+	var conn net.Conn = udpConn
+	if c.conditions.Enabled() {
+		c.log.Info("Simulating network conditions", "conditions", c.conditions)
+		conn = netsim.Wrap(udpConn, c.conditions)
+	}
+	c.conn = conn
+
+	done := make(chan struct{})
+	c.wg.Add(2)
+	go func() {
+		defer c.wg.Done()
+		defer close(done)
+		c.receiveLoop(conn)
+	}()
+	go func() {
+		defer c.wg.Done()
+		c.resendLoop(conn, done)
+	}()
+
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	return nil
+}
+
+// Connect dials the server and joins the configured room.
+func (c *Client) Connect(ctx context.Context) error {
+	if err := c.Dial(ctx); err != nil {
+		return err
+	}
+
+	if err := c.JoinRoom(ctx, c.roomID); err != nil {
+		c.Close()
+		return err
+	}
+	return nil
+}
+
+// JoinRoom joins a public room, or lets the server pick one when roomID is 0.
+// The server sends the room's world, which replaces World and Welcome, and
+// every known player is forgotten. On failure the client stays where it was.
+func (c *Client) JoinRoom(ctx context.Context, roomID uint32) error {
+	return c.join(ctx, protocol.Hello{RoomID: roomID})
+}
+
+// JoinPrivate joins the private room with the given code like JoinRoom.
+func (c *Client) JoinPrivate(ctx context.Context, code protocol.JoinCode) error {
+	return c.join(ctx, protocol.Hello{Code: code})
+}
+
+func (c *Client) join(ctx context.Context, hello protocol.Hello) error {
+	conn, err := c.connection()
+	if err != nil {
+		return err
+	}
+
+	hello.PlayerID = c.playerID
+	hello.Color = c.color
+	hello.Name, err = protocol.ParseName(c.name)
+	if err != nil {
+		return err
+	}
+
+	c.resetJoin(hello.RoomID)
+	result, err := c.handshake(ctx, conn, hello)
+
+	c.joinMu.Lock()
+	c.switching = false
+	if err == nil {
+		c.players.Clear()
+	}
+	c.joinMu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.welcome = result.welcome
+	c.world = result.world
+	c.mu.Unlock()
+
+	c.log.Info("Joined room", "room", result.welcome.RoomID, "width", result.welcome.Width, "height", result.welcome.Height)
+	return nil
+}
+
+func (c *Client) connection() (net.Conn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		return nil, ErrNotConnected
+	}
+	return c.conn, nil
+}
+
+type joinResult struct {
+	welcome protocol.Welcome
+	world   *world.World
+	err     error
+}
+
+// resetJoin prepares a handshake for roomID. Until it completes, updates
+// still in flight from the previous room are dropped.
+func (c *Client) resetJoin(roomID uint32) {
+	c.joinMu.Lock()
+	defer c.joinMu.Unlock()
+
+	c.joinRoom = roomID
+	c.joining = nil
+	c.joinDone = false
+	c.switching = true
+}
+
+// handshake sends Hello until the server has answered with Welcome and every
+// row of the tile map, or has rejected the join.
+func (c *Client) handshake(ctx context.Context, conn net.Conn, hello protocol.Hello) (joinResult, error) {
+	data, err := protocol.Encode(protocol.MsgHello, hello)
+	if err != nil {
+		return joinResult{}, err
+	}
+
+	result, err := request(ctx, c.clock, conn, data, c.joined)
+	if err != nil {
+		return result, err
+	}
+	return result, result.err
+}
+
+// request sends data every HandshakeRetryInterval until a reply arrives on
+// replies. Replies left over from earlier requests are discarded first.
+func request[T any](ctx context.Context, clk clock.Clock, conn net.Conn, data []byte, replies chan T) (T, error) {
+	var reply T
+
+	select {
+	case <-replies:
+	default:
+	}
+
+	timeout := clk.NewTimer(HandshakeTimeout)
+	defer timeout.Stop()
+
+	retry := clk.NewTicker(HandshakeRetryInterval)
+	defer retry.Stop()
+
+	for {
+		if _, err := conn.Write(data); err != nil {
+			return reply, err
+		}
+
+		select {
+		case reply = <-replies:
+			return reply, nil
+		case <-retry.C():
+		case <-timeout.C():
+			return reply, ErrHandshakeTimeout
+		case <-ctx.Done():
+			return reply, ctx.Err()
+		}
+	}
+}
+
+// PlayerID returns the ID the client joined with.
+func (c *Client) PlayerID() int32 {
+	return c.playerID
+}
+
+// Logger returns the logger of the client, carrying the player and server.
+func (c *Client) Logger() *slog.Logger {
+	return c.log
+}
+
+// Name returns the display name the client joins with.
+func (c *Client) Name() string {
+	return c.name
+}
+
+// Color returns the color the client joins with.
+func (c *Client) Color() protocol.Color {
+	return c.color
+}
+
+// RoomID returns the room the client is in, or 0 while in the lobby.
+func (c *Client) RoomID() uint32 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.welcome.RoomID
+}
+
+// Welcome returns the world description received during the handshake.
+func (c *Client) Welcome() protocol.Welcome {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.welcome
+}
+
+// World returns the tile map received during the handshake.
+func (c *Client) World() *world.World {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.world
+}
+
+// Players returns the store of every player known to this client.
+func (c *Client) Players() *player.Store {
+	return c.players
+}
+
+// Updates delivers every world update received from the server. Updates are
+// dropped when the channel is full, so slow readers only miss stale state.
+func (c *Client) Updates() <-chan player.Player {
+	return c.updates
+}
+
+// Events delivers connection and membership events.
+func (c *Client) Events() <-chan Event {
+	return c.events
+}
+
+// Send stamps the player with the next sequence number and the current time
+// and sends it to the server. The stamped player is returned.
+func (c *Client) Send(p player.Player) (player.Player, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		return p, ErrNotConnected
+	}
+
+	c.sequence++
+	p.Sequence = c.sequence
+	p.Timestamp = c.clock.Now().UnixMilli()
+
+	data, err := player.SerializePlayer(p)
+	if err != nil {
+		return p, err
+	}
+
+	c.log.Debug("Sending update", "seq", p.Sequence, "x", p.X, "y", p.Y)
+	_, err = c.conn.Write(data)
+	return p, err
+}
+
+// Close disconnects from the server and waits for the receive goroutine to exit.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	if c.conn == nil {
+		c.mu.Unlock()
+		return ErrNotConnected
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	c.mu.Unlock()
+
+	c.wg.Wait()
+
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+func (c *Client) receiveLoop(conn net.Conn) {
+	buf := make([]byte, 1024)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			c.emit(Event{Type: EventError, Err: err})
+			continue
+		}
+
+		c.dispatch(conn, buf[:n])
+	}
+}
+
+func (c *Client) dispatch(conn net.Conn, data []byte) {
+	msgType, err := protocol.Type(data)
+	if err != nil {
+		c.emit(Event{Type: EventError, Err: err})
+		return
+	}
+	c.log.Debug("Received packet", "type", msgType.String(), "size", len(data))
+
+	switch msgType {
+	case protocol.MsgPlayerUpdate, protocol.MsgEntityEnter:
+		c.handlePlayerUpdate(msgType, data)
+	case protocol.MsgEntityLeave:
+		c.handleEntityLeave(data)
+	case protocol.MsgWelcome:
+		c.handleWelcome(data)
+	case protocol.MsgMapChunk:
+		c.handleMapChunk(data)
+	case protocol.MsgJoinRejected:
+		c.handleJoinRejected(data)
+	case protocol.MsgRoomList:
+		c.handleRoomList(data)
+	case protocol.MsgRoomCreated:
+		c.handleRoomCreated(data)
+	case protocol.MsgQueueStatus:
+		c.handleQueueStatus(data)
+	case protocol.MsgMatchFound:
+		c.handleMatchFound(data)
+	case protocol.MsgCorrection:
+		c.handleCorrection(data)
+	case protocol.MsgPlayerInfo:
+		c.handlePlayerInfo(data)
+	case protocol.MsgReliable:
+		c.handleReliable(conn, data)
+	case protocol.MsgAck:
+		c.handleAck(data)
+	case protocol.MsgChat:
+		c.handleChat(data)
+	case protocol.MsgChatRejected:
+		c.handleChatRejected(data)
+	case protocol.MsgDisconnect:
+		c.handleDisconnect(data)
+	case protocol.MsgPing:
+		c.handlePing(conn, data)
+	case protocol.MsgPong:
+		c.handlePong(data)
+	case protocol.MsgActionResult:
+		c.handleActionResult(data)
+	}
+}
+
+func (c *Client) handleWelcome(data []byte) {
+	var welcome protocol.Welcome
+	if err := protocol.Decode(data, protocol.MsgWelcome, &welcome); err != nil {
+		c.emit(Event{Type: EventError, Err: err})
+		return
+	}
+
+	c.joinMu.Lock()
+	defer c.joinMu.Unlock()
+
+	if c.joinDone || c.joining != nil {
+		return
+	}
+	if c.joinRoom != 0 && welcome.RoomID != c.joinRoom {
+		return
+	}
+
+	c.joiningWelcome = welcome
+	c.joining = world.New(int(welcome.Width), int(welcome.Height))
+	c.finishJoin()
+}
+
+func (c *Client) handleMapChunk(data []byte) {
+	c.joinMu.Lock()
+	defer c.joinMu.Unlock()
+
+	if c.joinDone || c.joining == nil {
+		return
+	}
+
+	chunk, tiles, err := protocol.DecodeMapChunk(data)
+	if err != nil {
+		c.emit(Event{Type: EventError, Err: err})
+		return
+	}
+
+	if err := c.joining.SetRows(int(chunk.StartRow), int(chunk.Rows), tiles); err != nil {
+		c.emit(Event{Type: EventError, Err: err})
+		return
+	}
+	c.finishJoin()
+}
+
+// finishJoin hands the joined world to the handshake once every row has
+// arrived. The caller holds joinMu.
+func (c *Client) finishJoin() {
+	if !c.joining.Complete() {
+		return
+	}
+
+	c.joinDone = true
+	c.joined <- joinResult{welcome: c.joiningWelcome, world: c.joining}
+}
+
+func (c *Client) handleJoinRejected(data []byte) {
+	var rejected protocol.JoinRejected
+	if err := protocol.Decode(data, protocol.MsgJoinRejected, &rejected); err != nil {
+		c.emit(Event{Type: EventError, Err: err})
+		return
+	}
+
+	if rejected.Reason == protocol.RejectTooManyRooms {
+		offer(c.created, lobbyReply[protocol.RoomCreated]{err: ErrTooManyRooms})
+		offer(c.matched, lobbyReply[protocol.MatchFound]{err: ErrTooManyRooms})
+		return
+	}
+
+	c.joinMu.Lock()
+	defer c.joinMu.Unlock()
+
+	if c.joinDone || rejected.RoomID != c.joinRoom {
+		return
+	}
+
+	err := ErrRoomFull
+	switch rejected.Reason {
+	case protocol.RejectNoSuchRoom:
+		err = ErrNoSuchRoom
+	case protocol.RejectNameTaken:
+		err = ErrNameTaken
+	case protocol.RejectInvalidName:
+		err = ErrInvalidName
+	case protocol.RejectIDTaken:
+		err = ErrIDTaken
+	}
+
+	c.joinDone = true
+	c.joined <- joinResult{err: err}
+}
+
+func (c *Client) handleCorrection(data []byte) {
+	var correction protocol.Correction
+	if err := protocol.Decode(data, protocol.MsgCorrection, &correction); err != nil {
+		c.emit(Event{Type: EventError, Err: err})
+		return
+	}
+
+	c.emit(Event{
+		Type: EventCorrection,
+		Player: player.Player{
+			ID:       correction.PlayerID,
+			X:        correction.X,
+			Y:        correction.Y,
+			Sequence: correction.Sequence,
+		},
+		Reason: correction.Reason,
+	})
+}
+
+func (c *Client) handleEntityLeave(data []byte) {
+	var leave protocol.EntityLeave
+	if err := protocol.Decode(data, protocol.MsgEntityLeave, &leave); err != nil {
+		c.emit(Event{Type: EventError, Err: err})
+		return
+	}
+
+	left, known := c.players.Load(leave.PlayerID)
+	if !known {
+		return
+	}
+
+	c.players.Delete(leave.PlayerID)
+	c.emit(Event{Type: EventPlayerLeft, Player: left})
+}
+
+// handlePlayerUpdate stores a replicated player. MsgEntityEnter carries the
+// same payload as MsgPlayerUpdate for players entering the area of interest.
+func (c *Client) handlePlayerUpdate(msgType protocol.MsgType, data []byte) {
+	var updatedPlayer player.Player
+	if err := protocol.Decode(data, msgType, &updatedPlayer); err != nil {
+		c.emit(Event{Type: EventError, Err: err})
+		return
+	}
+
+	c.joinMu.Lock()
+	switching := c.switching
+	c.joinMu.Unlock()
+	if switching {
+		return
+	}
+
+	if c.players.Apply(updatedPlayer) {
+		c.emit(Event{Type: EventPlayerJoined, Player: updatedPlayer})
+	}
+
+	for _, fn := range c.onUpdate {
+		fn(updatedPlayer)
+	}
+
+	select {
+	case c.updates <- updatedPlayer:
+	default:
+	}
+}
+
+func (c *Client) emit(e Event) {
+	if e.Type == EventError {
+		c.log.Warn("Client error", "err", e.Err)
+	}
+
+	for _, fn := range c.onEvent {
+		fn(e)
+	}
+
+	select {
+	case c.events <- e:
+	default:
+	}
+}
+
+func (c *Client) handlePlayerInfo(data []byte) {
+	var info protocol.PlayerInfo
+	if err := protocol.Decode(data, protocol.MsgPlayerInfo, &info); err != nil {
+		c.emit(Event{Type: EventError, Err: err})
+		return
+	}
+
+	profile := player.Profile{ID: info.PlayerID, Name: info.Name.String(), Color: info.Color}
+	c.players.SetProfile(profile)
+	c.emit(Event{Type: EventPlayerInfo, Profile: profile})
+}
+
+// handleReliable acknowledges a reliable packet and handles the messages it
+// completes in order.
+func (c *Client) handleReliable(conn net.Conn, data []byte) {
+	ack, ready, err := c.reliable.Receive(data)
+	if err != nil {
+		c.emit(Event{Type: EventError, Err: err})
+		return
+	}
+	if ack != nil {
+		conn.Write(ack)
+	}
+
+	for _, inner := range ready {
+		if msgType, _ := protocol.Type(inner); msgType == protocol.MsgReliable {
+			continue
+		}
+		c.dispatch(conn, inner)
+	}
+}
+
+// sendReliable sends a message that is resent until the server acknowledges
+// it.
+func (c *Client) sendReliable(conn net.Conn, inner []byte) error {
+	data, err := c.reliable.Wrap(inner, c.clock.Now())
+	if err != nil {
+		return err
+	}
+	_, err = conn.Write(data)
+	return err
+}
+
+// resendLoop sends unacknowledged reliable messages again until done is
+// closed, or until the server never acknowledged one, which ends the
+// connection with ErrConnectionLost.
+func (c *Client) resendLoop(conn net.Conn, done <-chan struct{}) {
+	ticker := c.clock.NewTicker(reliable.ResendInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C():
+			due, err := c.reliable.Resend(now)
+			if err != nil {
+				c.emit(Event{Type: EventDisconnected, Err: fmt.Errorf("%w: %w", ErrConnectionLost, err)})
+				return
+			}
+			for _, data := range due {
+				conn.Write(data)
+			}
+		}
+	}
+}
+
+func (c *Client) handleAck(data []byte) {
+	var ack protocol.Ack
+	if err := protocol.Decode(data, protocol.MsgAck, &ack); err != nil {
+		c.emit(Event{Type: EventError, Err: err})
+		return
+	}
+	c.reliable.Ack(ack.Seq)
+}
+
+func (c *Client) handleDisconnect(data []byte) {
+	var disconnect protocol.Disconnect
+	if err := protocol.Decode(data, protocol.MsgDisconnect, &disconnect); err != nil {
+		c.emit(Event{Type: EventError, Err: err})
+		return
+	}
+
+	err := ErrKicked
+	switch disconnect.Reason {
+	case protocol.DisconnectBanned:
+		err = ErrBanned
+	case protocol.DisconnectInvalidMovement:
+		err = fmt.Errorf("%w for invalid movement", ErrKicked)
+	}
+	c.emit(Event{Type: EventDisconnected, Err: err})
+}
+
+// handlePing answers the server's latency probe right away.
+func (c *Client) handlePing(conn net.Conn, data []byte) {
+	var ping protocol.Ping
+	if err := protocol.Decode(data, protocol.MsgPing, &ping); err != nil {
+		c.emit(Event{Type: EventError, Err: err})
+		return
+	}
+
+	pong, err := protocol.Encode(protocol.MsgPong, ping)
+	if err != nil {
+		c.emit(Event{Type: EventError, Err: err})
+		return
+	}
+	conn.Write(pong)
+}
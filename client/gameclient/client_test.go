@@ -0,0 +1,461 @@
+package gameclient
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zainokta/client-server-multiplayer/client/config"
+	"github.com/zainokta/client-server-multiplayer/client/player"
+	"github.com/zainokta/client-server-multiplayer/client/world"
+	"github.com/zainokta/client-server-multiplayer/shared/clock"
+	"github.com/zainokta/client-server-multiplayer/shared/protocol"
+	"github.com/zainokta/client-server-multiplayer/shared/reliable"
+)
+
+func TestClientSendAndReceive(t *testing.T) {
+	serverConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	assert.NoError(t, err)
+	defer serverConn.Close()
+
+	clk := clock.NewFake(time.Unix(1000, 0))
+	c := New(config.Config{}, WithServerAddr(serverConn.LocalAddr().(*net.UDPAddr)), WithPlayerID(1), WithClock(clk))
+
+	_, err = c.Send(player.Player{ID: 1})
+	assert.ErrorIs(t, err, ErrNotConnected)
+
+	welcome := protocol.Welcome{PlayerID: 1, Width: 20, Height: 10, SpawnX: 10, SpawnY: 5}
+	go answerHello(t, serverConn, welcome)
+
+	assert.NoError(t, c.Connect(context.Background()))
+	defer c.Close()
+	assert.Equal(t, welcome, c.Welcome())
+	assert.True(t, c.World().Complete())
+	assert.True(t, c.World().Contains(1, 1))
+	assert.False(t, c.World().Contains(0, 1))
+
+	sent, err := c.Send(player.Player{ID: 1, X: 2, Y: 3})
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(1), sent.Sequence)
+	assert.Equal(t, clk.Now().UnixMilli(), sent.Timestamp)
+	clk.Advance(time.Second)
+
+	serverConn.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 1024)
+	n, clientAddr := readMessage(t, serverConn, buf, protocol.MsgPlayerUpdate)
+
+	received, err := player.DeserializePlayer(buf[:n])
+	assert.NoError(t, err)
+	assert.Equal(t, sent, received)
+
+	other := player.Player{ID: 2, X: 4, Y: 5, Sequence: 1}
+	data, err := player.SerializePlayer(other)
+	assert.NoError(t, err)
+	_, err = serverConn.WriteToUDP(data, clientAddr)
+	assert.NoError(t, err)
+
+	select {
+	case e := <-c.Events():
+		assert.Equal(t, EventPlayerJoined, e.Type)
+		assert.Equal(t, other.ID, e.Player.ID)
+	case <-time.After(time.Second):
+		t.Fatal("expected join event")
+	}
+
+	select {
+	case update := <-c.Updates():
+		assert.Equal(t, other.ID, update.ID)
+	case <-time.After(time.Second):
+		t.Fatal("expected world update")
+	}
+
+	stored, exists := c.Players().Load(other.ID)
+	assert.True(t, exists)
+	assert.Equal(t, other.X, stored.X)
+	assert.Equal(t, clk.Now().UnixMilli(), stored.Timestamp, "updates are stamped when received")
+}
+
+func TestClientCorrection(t *testing.T) {
+	serverConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	assert.NoError(t, err)
+	defer serverConn.Close()
+
+	c := New(config.Config{}, WithServerAddr(serverConn.LocalAddr().(*net.UDPAddr)), WithPlayerID(1))
+	go answerHello(t, serverConn, protocol.Welcome{PlayerID: 1})
+
+	assert.NoError(t, c.Connect(context.Background()))
+	defer c.Close()
+
+	_, err = c.Send(player.Player{ID: 1, X: 50, Y: 3})
+	assert.NoError(t, err)
+
+	serverConn.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 1024)
+	_, clientAddr := readMessage(t, serverConn, buf, protocol.MsgPlayerUpdate)
+
+	data, err := protocol.Encode(protocol.MsgCorrection, protocol.Correction{
+		PlayerID: 1, X: 2, Y: 3, Sequence: 1, Reason: protocol.ReasonOutOfBounds,
+	})
+	assert.NoError(t, err)
+	_, err = serverConn.WriteToUDP(data, clientAddr)
+	assert.NoError(t, err)
+
+	select {
+	case e := <-c.Events():
+		assert.Equal(t, EventCorrection, e.Type)
+		assert.Equal(t, protocol.ReasonOutOfBounds, e.Reason)
+		assert.Equal(t, float32(2), e.Player.X)
+	case <-time.After(time.Second):
+		t.Fatal("expected correction event")
+	}
+}
+
+func TestClientHandshakeTimeout(t *testing.T) {
+	serverConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	assert.NoError(t, err)
+	defer serverConn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	c := New(config.Config{}, WithServerAddr(serverConn.LocalAddr().(*net.UDPAddr)))
+	assert.ErrorIs(t, c.Connect(ctx), context.DeadlineExceeded)
+	assert.ErrorIs(t, c.Close(), ErrNotConnected)
+}
+
+// answerHello plays the server side of the handshake once, sending a map
+// enclosed by a wall in a single chunk.
+func answerHello(t *testing.T, conn *net.UDPConn, welcome protocol.Welcome) {
+	buf := make([]byte, 1024)
+	_, addr := readMessage(t, conn, buf, protocol.MsgHello)
+
+	data, err := protocol.Encode(protocol.MsgWelcome, welcome)
+	assert.NoError(t, err)
+	_, err = conn.WriteToUDP(data, addr)
+	assert.NoError(t, err)
+
+	if welcome.Height == 0 {
+		return
+	}
+
+	width, height := int(welcome.Width), int(welcome.Height)
+	tiles := make([]byte, width*height)
+	for y := range height {
+		for x := range width {
+			if x == 0 || y == 0 || x == width-1 || y == height-1 {
+				tiles[y*width+x] = byte(world.TileWall)
+			}
+		}
+	}
+
+	data, err = protocol.EncodeMapChunk(protocol.MapChunk{Rows: welcome.Height, Width: welcome.Width}, tiles)
+	assert.NoError(t, err)
+	_, err = conn.WriteToUDP(data, addr)
+	assert.NoError(t, err)
+}
+
+// readMessage reads packets until one of msgType arrives, skipping handshake retries.
+func readMessage(t *testing.T, conn *net.UDPConn, buf []byte, msgType protocol.MsgType) (int, *net.UDPAddr) {
+	for {
+		n, addr, err := conn.ReadFromUDP(buf)
+		if !assert.NoError(t, err) {
+			return 0, nil
+		}
+		if got, _ := protocol.Type(buf[:n]); got == msgType {
+			return n, addr
+		}
+	}
+}
+
+func TestClientEntityEnterAndLeave(t *testing.T) {
+	serverConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	assert.NoError(t, err)
+	defer serverConn.Close()
+
+	c := New(config.Config{}, WithServerAddr(serverConn.LocalAddr().(*net.UDPAddr)), WithPlayerID(1))
+	go answerHello(t, serverConn, protocol.Welcome{PlayerID: 1})
+
+	assert.NoError(t, c.Connect(context.Background()))
+	defer c.Close()
+
+	_, err = c.Send(player.Player{ID: 1})
+	assert.NoError(t, err)
+
+	serverConn.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 1024)
+	_, clientAddr := readMessage(t, serverConn, buf, protocol.MsgPlayerUpdate)
+
+	other := player.Player{ID: 2, X: 4, Y: 5, Sequence: 1}
+	data, err := protocol.Encode(protocol.MsgEntityEnter, other)
+	assert.NoError(t, err)
+	_, err = serverConn.WriteToUDP(data, clientAddr)
+	assert.NoError(t, err)
+
+	e := nextEvent(t, c)
+	assert.Equal(t, EventPlayerJoined, e.Type)
+	assert.Equal(t, other.ID, e.Player.ID)
+
+	data, err = protocol.Encode(protocol.MsgEntityLeave, protocol.EntityLeave{PlayerID: other.ID})
+	assert.NoError(t, err)
+	_, err = serverConn.WriteToUDP(data, clientAddr)
+	assert.NoError(t, err)
+
+	e = nextEvent(t, c)
+	assert.Equal(t, EventPlayerLeft, e.Type)
+	assert.Equal(t, other.ID, e.Player.ID)
+
+	_, exists := c.Players().Load(other.ID)
+	assert.False(t, exists)
+}
+
+func nextEvent(t *testing.T, c *Client) Event {
+	select {
+	case e := <-c.Events():
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("expected event")
+	}
+	return Event{}
+}
+
+func TestClientJoinRoom(t *testing.T) {
+	serverConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	assert.NoError(t, err)
+	defer serverConn.Close()
+
+	c := New(config.Config{}, WithServerAddr(serverConn.LocalAddr().(*net.UDPAddr)), WithPlayerID(1))
+	go answerHello(t, serverConn, protocol.Welcome{PlayerID: 1, RoomID: 1, Width: 20, Height: 10})
+
+	assert.NoError(t, c.Connect(context.Background()))
+	defer c.Close()
+	assert.Equal(t, uint32(1), c.RoomID())
+
+	c.Players().Store(player.Player{ID: 2})
+
+	go func() {
+		buf := make([]byte, 1024)
+		n, addr := readMessage(t, serverConn, buf, protocol.MsgHello)
+
+		var hello protocol.Hello
+		assert.NoError(t, protocol.Decode(buf[:n], protocol.MsgHello, &hello))
+		assert.Equal(t, uint32(3), hello.RoomID)
+
+		data, err := protocol.Encode(protocol.MsgJoinRejected, protocol.JoinRejected{RoomID: 3, Reason: protocol.RejectRoomFull})
+		assert.NoError(t, err)
+		_, err = serverConn.WriteToUDP(data, addr)
+		assert.NoError(t, err)
+	}()
+
+	assert.ErrorIs(t, c.JoinRoom(context.Background(), 3), ErrRoomFull)
+	assert.Equal(t, uint32(1), c.RoomID(), "a rejected join keeps the current room")
+
+	go answerHello(t, serverConn, protocol.Welcome{PlayerID: 1, RoomID: 2, Width: 30, Height: 12})
+
+	assert.NoError(t, c.JoinRoom(context.Background(), 2))
+	assert.Equal(t, uint32(2), c.RoomID())
+	assert.Equal(t, 30, c.World().Width)
+
+	_, exists := c.Players().Load(2)
+	assert.False(t, exists, "players of the old room are forgotten")
+}
+
+func TestClientPlayerInfo(t *testing.T) {
+	serverConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	assert.NoError(t, err)
+	defer serverConn.Close()
+
+	c := New(config.Config{PlayerColor: "red"}, WithServerAddr(serverConn.LocalAddr().(*net.UDPAddr)), WithPlayerID(1), WithName("alice"))
+	assert.Equal(t, protocol.ColorRed, c.Color())
+
+	hellos := make(chan protocol.Hello, 1)
+	go func() {
+		buf := make([]byte, 1024)
+		n, _ := readMessage(t, serverConn, buf, protocol.MsgHello)
+		var hello protocol.Hello
+		assert.NoError(t, protocol.Decode(buf[:n], protocol.MsgHello, &hello))
+		hellos <- hello
+		answerHello(t, serverConn, protocol.Welcome{PlayerID: 1})
+	}()
+
+	assert.NoError(t, c.Connect(context.Background()))
+	defer c.Close()
+
+	hello := <-hellos
+	assert.Equal(t, "alice", hello.Name.String())
+	assert.Equal(t, protocol.ColorRed, hello.Color)
+
+	_, err = c.Send(player.Player{ID: 1})
+	assert.NoError(t, err)
+
+	serverConn.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 1024)
+	_, clientAddr := readMessage(t, serverConn, buf, protocol.MsgPlayerUpdate)
+
+	sendInfo := func(seq uint32, info protocol.PlayerInfo) {
+		inner, err := protocol.Encode(protocol.MsgPlayerInfo, info)
+		assert.NoError(t, err)
+		data, err := protocol.EncodeReliable(seq, inner)
+		assert.NoError(t, err)
+		_, err = serverConn.WriteToUDP(data, clientAddr)
+		assert.NoError(t, err)
+
+		n, _ := readMessage(t, serverConn, buf, protocol.MsgAck)
+		var ack protocol.Ack
+		assert.NoError(t, protocol.Decode(buf[:n], protocol.MsgAck, &ack))
+		assert.Equal(t, seq, ack.Seq)
+	}
+
+	bob, err := protocol.ParseName("bob")
+	assert.NoError(t, err)
+	self, err := protocol.ParseName("alice")
+	assert.NoError(t, err)
+
+	sendInfo(2, protocol.PlayerInfo{PlayerID: 2, Name: bob, Color: protocol.ColorBlue})
+	sendInfo(1, protocol.PlayerInfo{PlayerID: 1, Name: self, Color: protocol.ColorRed})
+	sendInfo(1, protocol.PlayerInfo{PlayerID: 1, Name: self, Color: protocol.ColorRed})
+
+	e := nextEvent(t, c)
+	assert.Equal(t, EventPlayerInfo, e.Type)
+	assert.Equal(t, player.Profile{ID: 1, Name: "alice", Color: protocol.ColorRed}, e.Profile, "messages are delivered in order")
+
+	e = nextEvent(t, c)
+	assert.Equal(t, player.Profile{ID: 2, Name: "bob", Color: protocol.ColorBlue}, e.Profile)
+
+	select {
+	case e := <-c.Events():
+		t.Fatalf("duplicate delivered: %+v", e)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	profile, exists := c.Players().Profile(2)
+	assert.True(t, exists)
+	assert.Equal(t, "bob", profile.Name)
+}
+
+func TestClientInvalidName(t *testing.T) {
+	serverConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	assert.NoError(t, err)
+	defer serverConn.Close()
+
+	c := New(config.Config{}, WithPlayerID(7))
+	assert.Equal(t, "player7", c.Name(), "an empty name is derived from the ID")
+
+	c = New(config.Config{PlayerName: "   "}, WithServerAddr(serverConn.LocalAddr().(*net.UDPAddr)))
+	assert.ErrorIs(t, c.Connect(context.Background()), protocol.ErrName)
+}
+
+func TestClientDisconnected(t *testing.T) {
+	serverConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	assert.NoError(t, err)
+	defer serverConn.Close()
+
+	c := New(config.Config{}, WithServerAddr(serverConn.LocalAddr().(*net.UDPAddr)), WithPlayerID(1))
+	go answerHello(t, serverConn, protocol.Welcome{PlayerID: 1})
+
+	assert.NoError(t, c.Connect(context.Background()))
+	defer c.Close()
+
+	_, err = c.Send(player.Player{ID: 1})
+	assert.NoError(t, err)
+
+	serverConn.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 1024)
+	_, clientAddr := readMessage(t, serverConn, buf, protocol.MsgPlayerUpdate)
+
+	data, err := protocol.Encode(protocol.MsgDisconnect, protocol.Disconnect{Reason: protocol.DisconnectBanned})
+	assert.NoError(t, err)
+	_, err = serverConn.WriteToUDP(data, clientAddr)
+	assert.NoError(t, err)
+
+	e := nextEvent(t, c)
+	assert.Equal(t, EventDisconnected, e.Type)
+	assert.ErrorIs(t, e.Err, ErrBanned)
+}
+
+func TestClientConnectionLost(t *testing.T) {
+	serverConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	assert.NoError(t, err)
+	defer serverConn.Close()
+
+	clk := clock.NewFake(time.Unix(1_700_000_000, 0))
+	c := New(config.Config{}, WithServerAddr(serverConn.LocalAddr().(*net.UDPAddr)), WithPlayerID(1), WithClock(clk))
+	go answerHello(t, serverConn, protocol.Welcome{PlayerID: 1})
+
+	assert.NoError(t, c.Connect(context.Background()))
+	defer c.Close()
+
+	assert.NoError(t, c.Say(protocol.ChatRoom, "", "hello"))
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(time.Millisecond):
+				clk.Advance(reliable.ResendInterval)
+			}
+		}
+	}()
+
+	e := nextEvent(t, c)
+	assert.Equal(t, EventDisconnected, e.Type)
+	assert.ErrorIs(t, e.Err, ErrConnectionLost, "the chat message was never acknowledged")
+	assert.ErrorIs(t, e.Err, reliable.ErrUndelivered)
+}
+
+func TestClientAnswersPing(t *testing.T) {
+	serverConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	assert.NoError(t, err)
+	defer serverConn.Close()
+
+	c := New(config.Config{}, WithServerAddr(serverConn.LocalAddr().(*net.UDPAddr)), WithPlayerID(1))
+	go answerHello(t, serverConn, protocol.Welcome{PlayerID: 1})
+
+	assert.NoError(t, c.Connect(context.Background()))
+	defer c.Close()
+
+	_, err = c.Send(player.Player{ID: 1})
+	assert.NoError(t, err)
+
+	serverConn.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 1024)
+	_, clientAddr := readMessage(t, serverConn, buf, protocol.MsgPlayerUpdate)
+
+	ping := protocol.Ping{Seq: 3, SentAt: 1234}
+	data, err := protocol.Encode(protocol.MsgPing, ping)
+	assert.NoError(t, err)
+	_, err = serverConn.WriteToUDP(data, clientAddr)
+	assert.NoError(t, err)
+
+	n, _ := readMessage(t, serverConn, buf, protocol.MsgPong)
+	var pong protocol.Ping
+	assert.NoError(t, protocol.Decode(buf[:n], protocol.MsgPong, &pong))
+	assert.Equal(t, ping, pong)
+}
+
+func TestClientLogsPacketsAtDebug(t *testing.T) {
+	serverConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	assert.NoError(t, err)
+	defer serverConn.Close()
+
+	var out bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	c := New(config.Config{}, WithServerAddr(serverConn.LocalAddr().(*net.UDPAddr)), WithPlayerID(1), WithLogger(logger))
+	go answerHello(t, serverConn, protocol.Welcome{PlayerID: 1, RoomID: 2})
+
+	assert.NoError(t, c.Connect(context.Background()))
+	_, err = c.Send(player.Player{ID: 1})
+	assert.NoError(t, err)
+	assert.NoError(t, c.Close())
+
+	server := "server=" + serverConn.LocalAddr().String()
+	assert.Contains(t, out.String(), "level=DEBUG msg=\"Received packet\" player=1 "+server+" type=welcome")
+	assert.Contains(t, out.String(), "level=INFO msg=\"Joined room\" player=1 "+server+" room=2")
+	assert.Contains(t, out.String(), "level=DEBUG msg=\"Sending update\" player=1 "+server+" seq=1")
+}
@@ -0,0 +1,145 @@
+package gameclient
+
+import (
+	"context"
+	"time"
+
+	"github.com/zainokta/client-server-multiplayer/shared/protocol"
+)
+
+// QueueRefreshInterval is how often a queued client tells the server it is
+// still waiting.
+const QueueRefreshInterval = time.Second
+
+// ListRooms asks the server for the public rooms and their player counts.
+func (c *Client) ListRooms(ctx context.Context) ([]protocol.RoomEntry, error) {
+	conn, err := c.connection()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := protocol.Encode(protocol.MsgListRooms, protocol.LobbyRequest{PlayerID: c.playerID})
+	if err != nil {
+		return nil, err
+	}
+
+	return request(ctx, c.clock, conn, data, c.roomLists)
+}
+
+// CreateRoom opens a private room and joins it. The returned code lets other
+// players join with JoinPrivate. It fails with ErrTooManyRooms when the server
+// may not open another room.
+func (c *Client) CreateRoom(ctx context.Context) (protocol.JoinCode, error) {
+	conn, err := c.connection()
+	if err != nil {
+		return protocol.JoinCode{}, err
+	}
+
+	data, err := protocol.Encode(protocol.MsgCreateRoom, protocol.LobbyRequest{PlayerID: c.playerID})
+	if err != nil {
+		return protocol.JoinCode{}, err
+	}
+
+	created, err := request(ctx, c.clock, conn, data, c.created)
+	if err == nil {
+		err = created.err
+	}
+	if err != nil {
+		return protocol.JoinCode{}, err
+	}
+	return created.reply.Code, c.JoinPrivate(ctx, created.reply.Code)
+}
+
+// Matchmake waits in the matchmaking queue for a party of partySize players,
+// or the server default when 0, and joins the room opened for the party.
+// Progress is reported as EventQueueStatus. Cancelling ctx leaves the queue,
+// and ErrTooManyRooms means the server could not open a room for the party.
+func (c *Client) Matchmake(ctx context.Context, partySize uint8) error {
+	conn, err := c.connection()
+	if err != nil {
+		return err
+	}
+
+	data, err := protocol.Encode(protocol.MsgQueueJoin, protocol.QueueJoin{PlayerID: c.playerID, PartySize: partySize})
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-c.matched:
+	default:
+	}
+
+	refresh := c.clock.NewTicker(QueueRefreshInterval)
+	defer refresh.Stop()
+
+	for {
+		if _, err := conn.Write(data); err != nil {
+			return err
+		}
+
+		select {
+		case found := <-c.matched:
+			if found.err != nil {
+				return found.err
+			}
+			return c.JoinPrivate(ctx, found.reply.Code)
+		case <-refresh.C():
+		case <-ctx.Done():
+			if leave, err := protocol.Encode(protocol.MsgQueueLeave, protocol.LobbyRequest{PlayerID: c.playerID}); err == nil {
+				conn.Write(leave)
+			}
+			return ctx.Err()
+		}
+	}
+}
+
+func (c *Client) handleRoomList(data []byte) {
+	entries, err := protocol.DecodeRoomList(data)
+	if err != nil {
+		c.emit(Event{Type: EventError, Err: err})
+		return
+	}
+	offer(c.roomLists, entries)
+}
+
+func (c *Client) handleRoomCreated(data []byte) {
+	var created protocol.RoomCreated
+	if err := protocol.Decode(data, protocol.MsgRoomCreated, &created); err != nil {
+		c.emit(Event{Type: EventError, Err: err})
+		return
+	}
+	offer(c.created, lobbyReply[protocol.RoomCreated]{reply: created})
+}
+
+func (c *Client) handleQueueStatus(data []byte) {
+	var status protocol.QueueStatus
+	if err := protocol.Decode(data, protocol.MsgQueueStatus, &status); err != nil {
+		c.emit(Event{Type: EventError, Err: err})
+		return
+	}
+	c.emit(Event{Type: EventQueueStatus, Queue: status})
+}
+
+func (c *Client) handleMatchFound(data []byte) {
+	var found protocol.MatchFound
+	if err := protocol.Decode(data, protocol.MsgMatchFound, &found); err != nil {
+		c.emit(Event{Type: EventError, Err: err})
+		return
+	}
+	offer(c.matched, lobbyReply[protocol.MatchFound]{reply: found})
+}
+
+// lobbyReply is the answer to a lobby request, or why the server refused it.
+type lobbyReply[T any] struct {
+	reply T
+	err   error
+}
+
+// offer hands a reply to a waiting request without blocking the receive loop.
+func offer[T any](replies chan T, reply T) {
+	select {
+	case replies <- reply:
+	default:
+	}
+}
@@ -0,0 +1,165 @@
+package gameclient
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zainokta/client-server-multiplayer/client/config"
+	"github.com/zainokta/client-server-multiplayer/shared/protocol"
+)
+
+// lobbyServer answers lobby requests with fixed replies. Hellos carrying
+// code are welcomed into room 9.
+func lobbyServer(t *testing.T, conn *net.UDPConn, code protocol.JoinCode, rooms []protocol.RoomEntry) {
+	buf := make([]byte, 1024)
+	queued := 0
+	for {
+		n, addr, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+
+		msgType, _ := protocol.Type(buf[:n])
+		switch msgType {
+		case protocol.MsgListRooms:
+			data, err := protocol.EncodeRoomList(rooms)
+			assert.NoError(t, err)
+			conn.WriteToUDP(data, addr)
+
+		case protocol.MsgCreateRoom:
+			data, err := protocol.Encode(protocol.MsgRoomCreated, protocol.RoomCreated{RoomID: 9, Code: code})
+			assert.NoError(t, err)
+			conn.WriteToUDP(data, addr)
+
+		case protocol.MsgQueueJoin:
+			queued++
+			var data []byte
+			if queued == 1 {
+				data, err = protocol.Encode(protocol.MsgQueueStatus, protocol.QueueStatus{Waiting: 1, PartySize: 2})
+			} else {
+				data, err = protocol.Encode(protocol.MsgMatchFound, protocol.MatchFound{RoomID: 9, Code: code})
+			}
+			assert.NoError(t, err)
+			conn.WriteToUDP(data, addr)
+
+		case protocol.MsgHello:
+			var hello protocol.Hello
+			assert.NoError(t, protocol.Decode(buf[:n], protocol.MsgHello, &hello))
+
+			var data []byte
+			if hello.Code == code {
+				data, err = protocol.Encode(protocol.MsgWelcome, protocol.Welcome{PlayerID: hello.PlayerID, RoomID: 9})
+			} else {
+				data, err = protocol.Encode(protocol.MsgJoinRejected, protocol.JoinRejected{RoomID: hello.RoomID, Reason: protocol.RejectNoSuchRoom})
+			}
+			assert.NoError(t, err)
+			conn.WriteToUDP(data, addr)
+		}
+	}
+}
+
+func TestClientLobby(t *testing.T) {
+	serverConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	assert.NoError(t, err)
+	defer serverConn.Close()
+
+	code, err := protocol.ParseJoinCode("ABC234")
+	assert.NoError(t, err)
+	rooms := []protocol.RoomEntry{{ID: 1, Players: 2, Capacity: 8, Width: 20, Height: 10}}
+	go lobbyServer(t, serverConn, code, rooms)
+
+	c := New(config.Config{}, WithServerAddr(serverConn.LocalAddr().(*net.UDPAddr)), WithPlayerID(1))
+
+	_, err = c.ListRooms(context.Background())
+	assert.ErrorIs(t, err, ErrNotConnected)
+
+	assert.NoError(t, c.Dial(context.Background()))
+	defer c.Close()
+	assert.Zero(t, c.RoomID(), "dialing leaves the client in the lobby")
+
+	listed, err := c.ListRooms(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, rooms, listed)
+
+	assert.ErrorIs(t, c.JoinPrivate(context.Background(), protocol.JoinCode{'W', 'R', 'O', 'N', 'G', '0'}), ErrNoSuchRoom)
+
+	created, err := c.CreateRoom(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, code, created)
+	assert.Equal(t, uint32(9), c.RoomID())
+}
+
+func TestClientMatchmake(t *testing.T) {
+	serverConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	assert.NoError(t, err)
+	defer serverConn.Close()
+
+	code, err := protocol.ParseJoinCode("XYZ789")
+	assert.NoError(t, err)
+	go lobbyServer(t, serverConn, code, nil)
+
+	c := New(config.Config{}, WithServerAddr(serverConn.LocalAddr().(*net.UDPAddr)), WithPlayerID(1))
+	assert.NoError(t, c.Dial(context.Background()))
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, c.Matchmake(ctx, 0))
+	assert.Equal(t, uint32(9), c.RoomID())
+
+	e := nextEvent(t, c)
+	assert.Equal(t, EventQueueStatus, e.Type)
+	assert.Equal(t, protocol.QueueStatus{Waiting: 1, PartySize: 2}, e.Queue)
+}
+
+func TestClientLobbyRefusedWhenServerHasTooManyRooms(t *testing.T) {
+	serverConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	assert.NoError(t, err)
+	defer serverConn.Close()
+
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			_, addr, err := serverConn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			data, err := protocol.Encode(protocol.MsgJoinRejected, protocol.JoinRejected{Reason: protocol.RejectTooManyRooms})
+			assert.NoError(t, err)
+			serverConn.WriteToUDP(data, addr)
+		}
+	}()
+
+	c := New(config.Config{}, WithServerAddr(serverConn.LocalAddr().(*net.UDPAddr)), WithPlayerID(1))
+	assert.NoError(t, c.Dial(context.Background()))
+	defer c.Close()
+
+	_, err = c.CreateRoom(context.Background())
+	assert.ErrorIs(t, err, ErrTooManyRooms)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.ErrorIs(t, c.Matchmake(ctx, 2), ErrTooManyRooms)
+	assert.Zero(t, c.RoomID())
+}
+
+func TestClientMatchmakeCancel(t *testing.T) {
+	serverConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	assert.NoError(t, err)
+	defer serverConn.Close()
+
+	c := New(config.Config{}, WithServerAddr(serverConn.LocalAddr().(*net.UDPAddr)))
+	assert.NoError(t, c.Dial(context.Background()))
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, c.Matchmake(ctx, 3), context.DeadlineExceeded)
+
+	serverConn.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 1024)
+	readMessage(t, serverConn, buf, protocol.MsgQueueLeave)
+}
@@ -0,0 +1,63 @@
+package gameclient
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/zainokta/client-server-multiplayer/shared/protocol"
+)
+
+// PingTimeout is how long Ping waits for the server to answer.
+const PingTimeout = time.Second
+
+var ErrPingTimeout = errors.New("ping timed out")
+
+// Ping measures the round trip time to the server, which only answers
+// clients seated in a room. Pings are not resent, so a lost ping or pong
+// times out. Only one Ping may wait at a time.
+func (c *Client) Ping(ctx context.Context) (time.Duration, error) {
+	conn, err := c.connection()
+	if err != nil {
+		return 0, err
+	}
+
+	sent := c.clock.Now()
+	timeout := c.clock.NewTimer(PingTimeout)
+	defer timeout.Stop()
+
+	seq := c.pingSequence.Add(1)
+	data, err := protocol.Encode(protocol.MsgPing, protocol.Ping{Seq: seq, SentAt: sent.UnixMilli()})
+	if err != nil {
+		return 0, err
+	}
+	if _, err := conn.Write(data); err != nil {
+		return 0, err
+	}
+
+	for {
+		select {
+		case pong := <-c.pongs:
+			if pong.Seq == seq {
+				return c.clock.Since(sent), nil
+			}
+		case <-timeout.C():
+			return 0, ErrPingTimeout
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+}
+
+func (c *Client) handlePong(data []byte) {
+	var pong protocol.Ping
+	if err := protocol.Decode(data, protocol.MsgPong, &pong); err != nil {
+		c.emit(Event{Type: EventError, Err: err})
+		return
+	}
+
+	select {
+	case c.pongs <- pong:
+	default:
+	}
+}
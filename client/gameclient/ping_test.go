@@ -0,0 +1,59 @@
+package gameclient
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zainokta/client-server-multiplayer/client/config"
+	"github.com/zainokta/client-server-multiplayer/shared/clock"
+	"github.com/zainokta/client-server-multiplayer/shared/protocol"
+)
+
+func TestClientPing(t *testing.T) {
+	serverConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	assert.NoError(t, err)
+	defer serverConn.Close()
+
+	clk := clock.NewFake(time.Unix(1_700_000_000, 0))
+	c := New(config.Config{}, WithServerAddr(serverConn.LocalAddr().(*net.UDPAddr)), WithPlayerID(1), WithClock(clk))
+	_, err = c.Ping(context.Background())
+	assert.ErrorIs(t, err, ErrNotConnected)
+
+	go answerHello(t, serverConn, protocol.Welcome{PlayerID: 1})
+	assert.NoError(t, c.Connect(context.Background()))
+	defer c.Close()
+
+	go func() {
+		buf := make([]byte, 1024)
+		serverConn.SetReadDeadline(time.Now().Add(time.Second))
+		n, clientAddr := readMessage(t, serverConn, buf, protocol.MsgPing)
+
+		stale, _ := protocol.Encode(protocol.MsgPong, protocol.Ping{Seq: 99})
+		serverConn.WriteToUDP(stale, clientAddr)
+
+		clk.Advance(20 * time.Millisecond)
+		buf[0] = byte(protocol.MsgPong)
+		serverConn.WriteToUDP(buf[:n], clientAddr)
+	}()
+
+	rtt, err := c.Ping(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 20*time.Millisecond, rtt, "pongs to other pings are ignored")
+
+	go func() {
+		buf := make([]byte, 1024)
+		serverConn.SetReadDeadline(time.Now().Add(time.Second))
+		readMessage(t, serverConn, buf, protocol.MsgPing)
+		clk.Advance(PingTimeout)
+	}()
+	_, err = c.Ping(context.Background())
+	assert.ErrorIs(t, err, ErrPingTimeout, "the timeout runs on the clock of the client")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = c.Ping(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
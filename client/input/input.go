@@ -0,0 +1,155 @@
+package input
+
+import (
+	"bufio"
+	"io"
+	"time"
+)
+
+type Key int
+
+const (
+	KeyRune Key = iota
+	KeyUp
+	KeyDown
+	KeyLeft
+	KeyRight
+	KeyEnter
+	KeyEscape
+	KeyBackspace
+	KeyCtrlC
+)
+
+// KeyHoldTimeout is how long after its last event a key still counts as held.
+// It is longer than typical terminal auto-repeat intervals.
+const KeyHoldTimeout = 150 * time.Millisecond
+
+const (
+	esc       = 0x1b
+	ctrlC     = 0x03
+	backspace = 0x7f
+)
+
+type KeyEvent struct {
+	Key  Key
+	Rune rune
+}
+
+// Reader decodes raw terminal bytes into individual key events, including the
+// escape sequences terminals send for arrow keys.
+type Reader struct {
+	r *bufio.Reader
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+func (r *Reader) ReadKey() (KeyEvent, error) {
+	ch, _, err := r.r.ReadRune()
+	if err != nil {
+		return KeyEvent{}, err
+	}
+
+	switch ch {
+	case esc:
+		return r.readEscape()
+	case ctrlC:
+		return KeyEvent{Key: KeyCtrlC}, nil
+	case '\r', '\n':
+		return KeyEvent{Key: KeyEnter}, nil
+	case backspace, '\b':
+		return KeyEvent{Key: KeyBackspace}, nil
+	}
+
+	return KeyEvent{Key: KeyRune, Rune: ch}, nil
+}
+
+// readEscape decodes CSI ("ESC [") and SS3 ("ESC O") arrow key sequences. A
+// lone escape with nothing buffered behind it is reported as KeyEscape.
+func (r *Reader) readEscape() (KeyEvent, error) {
+	if r.r.Buffered() == 0 {
+		return KeyEvent{Key: KeyEscape}, nil
+	}
+
+	prefix, err := r.r.ReadByte()
+	if err != nil {
+		return KeyEvent{}, err
+	}
+	if prefix != '[' && prefix != 'O' {
+		r.r.UnreadByte()
+		return KeyEvent{Key: KeyEscape}, nil
+	}
+
+	code, err := r.r.ReadByte()
+	if err != nil {
+		return KeyEvent{}, err
+	}
+
+	switch code {
+	case 'A':
+		return KeyEvent{Key: KeyUp}, nil
+	case 'B':
+		return KeyEvent{Key: KeyDown}, nil
+	case 'C':
+		return KeyEvent{Key: KeyRight}, nil
+	case 'D':
+		return KeyEvent{Key: KeyLeft}, nil
+	}
+
+	return KeyEvent{Key: KeyEscape}, nil
+}
+
+// Repeater paces the auto-repeat events of a held key so continuous movement
+// runs at a steady interval regardless of the terminal's own repeat rate.
+// Repeats arriving early are kept pending and released by Tick.
+type Repeater struct {
+	interval time.Duration
+	hold     time.Duration
+
+	key       KeyEvent
+	pending   bool
+	lastEvent time.Time
+	lastFire  time.Time
+}
+
+func NewRepeater(interval, hold time.Duration) *Repeater {
+	return &Repeater{interval: interval, hold: hold}
+}
+
+// Press records a key event and reports whether it should act right away.
+func (r *Repeater) Press(k KeyEvent, now time.Time) bool {
+	repeat := r.key == k && now.Sub(r.lastEvent) <= r.hold
+	r.key = k
+	r.lastEvent = now
+
+	if repeat && now.Sub(r.lastFire) < r.interval {
+		r.pending = true
+		return false
+	}
+
+	r.pending = false
+	r.lastFire = now
+	return true
+}
+
+// Tick releases a pending repeat once the interval has elapsed, as long as the
+// key is still held.
+func (r *Repeater) Tick(now time.Time) (KeyEvent, bool) {
+	if !r.pending {
+		return KeyEvent{}, false
+	}
+
+	if now.Sub(r.lastEvent) > r.hold {
+		r.pending = false
+		return KeyEvent{}, false
+	}
+
+	if now.Sub(r.lastFire) < r.interval {
+		return KeyEvent{}, false
+	}
+
+	r.pending = false
+	r.lastFire = now
+	return r.key, true
+}
@@ -0,0 +1,70 @@
+package input
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadKey(t *testing.T) {
+	r := NewReader(strings.NewReader("wA\x1b[A\x1b[B\x1bOC\x1b[D\r\x03\x7f"))
+
+	expected := []KeyEvent{
+		{Key: KeyRune, Rune: 'w'},
+		{Key: KeyRune, Rune: 'A'},
+		{Key: KeyUp},
+		{Key: KeyDown},
+		{Key: KeyRight},
+		{Key: KeyLeft},
+		{Key: KeyEnter},
+		{Key: KeyCtrlC},
+		{Key: KeyBackspace},
+	}
+
+	for _, want := range expected {
+		got, err := r.ReadKey()
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+
+	_, err := r.ReadKey()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestReadKeyLoneEscape(t *testing.T) {
+	r := NewReader(strings.NewReader("\x1b"))
+
+	got, err := r.ReadKey()
+	assert.NoError(t, err)
+	assert.Equal(t, KeyEvent{Key: KeyEscape}, got)
+}
+
+func TestRepeater(t *testing.T) {
+	r := NewRepeater(100*time.Millisecond, 150*time.Millisecond)
+	up := KeyEvent{Key: KeyUp}
+	start := time.Now()
+
+	assert.True(t, r.Press(up, start), "first press acts immediately")
+
+	_, ok := r.Tick(start.Add(120 * time.Millisecond))
+	assert.False(t, ok, "a single tap must not repeat")
+
+	assert.True(t, r.Press(up, start.Add(130*time.Millisecond)), "repeat after the interval acts immediately")
+
+	assert.False(t, r.Press(up, start.Add(160*time.Millisecond)), "early repeat is held back")
+	key, ok := r.Tick(start.Add(200 * time.Millisecond))
+	assert.False(t, ok)
+
+	key, ok = r.Tick(start.Add(230 * time.Millisecond))
+	assert.True(t, ok, "pending repeat is released after the interval")
+	assert.Equal(t, up, key)
+
+	assert.True(t, r.Press(KeyEvent{Key: KeyLeft}, start.Add(240*time.Millisecond)), "a new key acts immediately")
+
+	assert.False(t, r.Press(KeyEvent{Key: KeyLeft}, start.Add(260*time.Millisecond)))
+	_, ok = r.Tick(start.Add(600 * time.Millisecond))
+	assert.False(t, ok, "released key stops repeating")
+}
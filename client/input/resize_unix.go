@@ -0,0 +1,36 @@
+//go:build !windows
+
+package input
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// NotifyResize signals ch whenever the terminal is resized, using SIGWINCH.
+// The returned function stops the notifications.
+func NotifyResize(ch chan<- struct{}) func() {
+	sigs := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(sigs, syscall.SIGWINCH)
+
+	go func() {
+		for {
+			select {
+			case <-sigs:
+				select {
+				case ch <- struct{}{}:
+				default:
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(sigs)
+		close(done)
+	}
+}
@@ -0,0 +1,46 @@
+//go:build windows
+
+package input
+
+import (
+	"os"
+	"time"
+)
+
+const resizePollInterval = 250 * time.Millisecond
+
+// NotifyResize signals ch whenever the terminal is resized. Windows has no
+// SIGWINCH, so the console size is polled instead. The returned function stops
+// the notifications.
+func NotifyResize(ch chan<- struct{}) func() {
+	done := make(chan struct{})
+	fd := int(os.Stdout.Fd())
+
+	go func() {
+		ticker := time.NewTicker(resizePollInterval)
+		defer ticker.Stop()
+
+		width, height, _ := TerminalSize(fd)
+		for {
+			select {
+			case <-ticker.C:
+				w, h, err := TerminalSize(fd)
+				if err != nil || (w == width && h == height) {
+					continue
+				}
+				width, height = w, h
+
+				select {
+				case ch <- struct{}{}:
+				default:
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+	}
+}
@@ -0,0 +1,43 @@
+package input
+
+import (
+	"sync"
+
+	"golang.org/x/term"
+)
+
+// EnableRawMode switches the terminal behind fd into raw mode so keypresses
+// are delivered immediately without echo. The returned function restores the
+// previous mode and is safe to call more than once. When fd is not a terminal
+// raw mode is skipped and the restore function does nothing.
+func EnableRawMode(fd int) (func(), error) {
+	if !term.IsTerminal(fd) {
+		return func() {}, nil
+	}
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			term.Restore(fd, state)
+		})
+	}, nil
+}
+
+// RestoreOnPanic restores the terminal before re-raising a panic. It must be
+// deferred at the top of every goroutine that runs while raw mode is enabled.
+func RestoreOnPanic(restore func()) {
+	if r := recover(); r != nil {
+		restore()
+		panic(r)
+	}
+}
+
+// TerminalSize returns the number of columns and rows of the terminal behind fd.
+func TerminalSize(fd int) (int, int, error) {
+	return term.GetSize(fd)
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/zainokta/client-server-multiplayer/client/gameclient"
+	"github.com/zainokta/client-server-multiplayer/client/input"
+	"github.com/zainokta/client-server-multiplayer/shared/protocol"
+)
+
+// lobby is the menu shown after connecting, where the player picks a room.
+type lobby struct {
+	gameClient *gameclient.Client
+	keys       <-chan input.KeyEvent
+
+	rooms    []protocol.RoomEntry
+	message  string
+	code     string
+	entering bool
+}
+
+// runLobby shows the lobby until the player joins a room. It returns a notice
+// to show in the game, and false when the player quits instead.
+func runLobby(gameClient *gameclient.Client, keys <-chan input.KeyEvent) (string, bool) {
+	l := &lobby{gameClient: gameClient, keys: keys}
+	l.refresh()
+
+	for {
+		l.draw()
+
+		key := <-keys
+		if l.entering {
+			if notice, joined := l.enterCode(key); joined {
+				return notice, true
+			}
+			continue
+		}
+
+		if isQuit(key) {
+			return "", false
+		}
+		if key.Key != input.KeyRune {
+			continue
+		}
+
+		switch r := unicode.ToLower(key.Rune); {
+		case r == 'r':
+			l.refresh()
+		case r == 'a':
+			if l.join(gameClient.JoinRoom(context.Background(), 0)) {
+				return "", true
+			}
+		case r >= '1' && r <= '9':
+			if l.join(gameClient.JoinRoom(context.Background(), uint32(r-'0'))) {
+				return "", true
+			}
+		case r == 'c':
+			code, err := gameClient.CreateRoom(context.Background())
+			if l.join(err) {
+				return fmt.Sprintf("Private room code: %s", code), true
+			}
+		case r == 'j':
+			l.entering = true
+			l.code = ""
+			l.message = ""
+		case r == 'm':
+			if l.matchmake() {
+				return "", true
+			}
+		}
+	}
+}
+
+func (l *lobby) refresh() {
+	rooms, err := l.gameClient.ListRooms(context.Background())
+	if err != nil {
+		l.message = fmt.Sprintf("Cannot list rooms: %v", err)
+		return
+	}
+	l.rooms = rooms
+	l.message = ""
+}
+
+func (l *lobby) join(err error) bool {
+	if err != nil {
+		l.message = fmt.Sprintf("Cannot join: %v", err)
+		return false
+	}
+	return true
+}
+
+// enterCode edits the join code being typed and joins once Enter is pressed.
+func (l *lobby) enterCode(key input.KeyEvent) (string, bool) {
+	switch key.Key {
+	case input.KeyCtrlC, input.KeyEscape:
+		l.entering = false
+	case input.KeyBackspace:
+		if len(l.code) > 0 {
+			l.code = l.code[:len(l.code)-1]
+		}
+	case input.KeyEnter:
+		code, err := protocol.ParseJoinCode(l.code)
+		if err != nil {
+			l.message = err.Error()
+			return "", false
+		}
+		if l.join(l.gameClient.JoinPrivate(context.Background(), code)) {
+			return fmt.Sprintf("Private room code: %s", code), true
+		}
+		l.entering = false
+	case input.KeyRune:
+		if len(l.code) < len(protocol.JoinCode{}) && key.Rune < unicode.MaxASCII {
+			l.code += strings.ToUpper(string(key.Rune))
+		}
+	}
+	return "", false
+}
+
+// matchmake waits in the queue until a party is found or the player presses
+// Escape or q.
+func (l *lobby) matchmake() bool {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- l.gameClient.Matchmake(ctx, 0)
+	}()
+
+	l.message = "Searching for players... (Esc to cancel)"
+	for {
+		l.draw()
+
+		select {
+		case err := <-done:
+			if errors.Is(err, context.Canceled) {
+				l.message = "Left the queue"
+				return false
+			}
+			return l.join(err)
+
+		case key := <-l.keys:
+			if key.Key == input.KeyEscape || isQuit(key) {
+				cancel()
+			}
+
+		case event := <-l.gameClient.Events():
+			if event.Type == gameclient.EventQueueStatus {
+				l.message = fmt.Sprintf("Searching for players: %d/%d in queue (Esc to cancel)",
+					event.Queue.Waiting, event.Queue.PartySize)
+			}
+		}
+	}
+}
+
+func (l *lobby) draw() {
+	var b strings.Builder
+	b.WriteString("\033[H\033[2J")
+	b.WriteString("A simple 2D real-time environment\r\n\r\n")
+	b.WriteString("Lobby\r\n")
+
+	if len(l.rooms) == 0 {
+		b.WriteString("  No public rooms\r\n")
+	}
+	for _, room := range l.rooms {
+		capacity := "unlimited"
+		if room.Capacity > 0 {
+			capacity = fmt.Sprint(room.Capacity)
+		}
+		fmt.Fprintf(&b, "  [%d] Room %d  %d/%s players  %dx%d\r\n",
+			room.ID, room.ID, room.Players, capacity, room.Width, room.Height)
+	}
+
+	b.WriteString("\r\n1-9 join a room, a join any room, c create a private room, j join with a code,\r\n")
+	b.WriteString("m find a match, r refresh, q quit\r\n\r\n")
+
+	if l.entering {
+		fmt.Fprintf(&b, "Join code: %s_ (Enter to join, Esc to cancel)\r\n", l.code)
+	}
+	if l.message != "" {
+		b.WriteString(l.message + "\r\n")
+	}
+
+	fmt.Print(b.String())
+}
@@ -1,165 +1,285 @@
 package main
 
 import (
-	"bufio"
+	"context"
 	"fmt"
 	"log"
-	"net"
+	"log/slog"
+	"math"
 	"os"
-	"os/exec"
-	"runtime"
+	"slices"
 	"strings"
 	"sync"
 	"time"
+	"unicode"
 
 	"github.com/caarlos0/env/v11"
+	"github.com/zainokta/client-server-multiplayer/client/camera"
 	"github.com/zainokta/client-server-multiplayer/client/config"
+	"github.com/zainokta/client-server-multiplayer/client/gameclient"
+	"github.com/zainokta/client-server-multiplayer/client/input"
 	"github.com/zainokta/client-server-multiplayer/client/player"
+	"github.com/zainokta/client-server-multiplayer/client/render"
+	"github.com/zainokta/client-server-multiplayer/client/world"
+	"github.com/zainokta/client-server-multiplayer/shared/logging"
+	"github.com/zainokta/client-server-multiplayer/shared/protocol"
 
 	_ "github.com/joho/godotenv/autoload"
 )
 
-var sequenceNumber uint32 = 0
-
-const (
-	width  = 20
-	height = 10
-)
-
 func main() {
 	cfg, err := env.ParseAs[config.Config]()
 	if err != nil {
 		fmt.Printf("%+v\n", err)
 	}
+	if _, err := protocol.ParseColor(cfg.PlayerColor); err != nil {
+		log.Fatal(err)
+	}
 
-	addr := net.UDPAddr{Port: cfg.Port, IP: net.ParseIP("127.0.0.1")}
-	conn, err := net.DialUDP("udp", nil, &addr)
+	logger, err := newLogger(cfg)
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer conn.Close()
 
-	go player.GetPlayerUpdate(conn)
+	gameClient := gameclient.New(cfg, gameclient.WithLogger(logger))
+	if err := gameClient.Dial(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+	defer gameClient.Close()
 
-	gameBoard := make([][]rune, height)
-	for i := range gameBoard {
-		gameBoard[i] = make([]rune, width)
-		for j := range gameBoard[i] {
-			gameBoard[i][j] = ' '
+	// exitMessage is printed once the terminal is restored.
+	var exitMessage string
+	defer func() {
+		if exitMessage != "" {
+			fmt.Println(exitMessage)
 		}
+	}()
+
+	restoreTerminal, err := input.EnableRawMode(int(os.Stdin.Fd()))
+	if err != nil {
+		log.Fatal(err)
 	}
+	defer restoreTerminal()
+	defer input.RestoreOnPanic(restoreTerminal)
 
-	inputChan := make(chan rune, 10)
+	inputChan := make(chan input.KeyEvent, 10)
 	stopChan := make(chan struct{})
 
-	reader := bufio.NewReader(os.Stdin)
+	keyReader := input.NewReader(os.Stdin)
 
-	var wg sync.WaitGroup
-	wg.Add(1)
 	go func() {
-		defer wg.Done()
+		defer input.RestoreOnPanic(restoreTerminal)
 		for {
+			key, err := keyReader.ReadKey()
+			if err != nil {
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+
 			select {
+			case inputChan <- key:
 			case <-stopChan:
 				return
-			default:
-				input, err := reader.ReadString('\n')
-				if err != nil {
-					time.Sleep(100 * time.Millisecond)
-					continue
-				}
-
-				input = strings.TrimSpace(strings.ToLower(input))
-				if len(input) > 0 {
-					inputChan <- rune(input[0])
-				}
 			}
 		}
 	}()
 
-	fmt.Println("A simple 2D real-time environment")
-	fmt.Println("Controls: W = Up, A = Left, S = Down, D = Right, Q = Quit")
-	fmt.Println("Press Enter after each command")
-	fmt.Println("Game starting...")
-	time.Sleep(2 * time.Second)
+	var notice string
+	var noticeTime time.Time
+
+	if cfg.RoomID != 0 {
+		fmt.Print("A simple 2D real-time environment\r\n")
+		fmt.Print("Controls: W/Up = Up, A/Left = Left, S/Down = Down, D/Right = Right, Q = Quit\r\n")
+		fmt.Print("Hold a key to keep moving, press 1-9 to switch room, f to tag, t to chat\r\n")
+		fmt.Print("Game starting...\r\n")
+
+		if err := gameClient.JoinRoom(context.Background(), cfg.RoomID); err != nil {
+			restoreTerminal()
+			log.Fatal(err)
+		}
+		time.Sleep(2 * time.Second)
+	} else {
+		joinedNotice, joined := runLobby(gameClient, inputChan)
+		if !joined {
+			close(stopChan)
+			return
+		}
+		notice, noticeTime = joinedNotice, time.Now()
+	}
+
+	welcome := gameClient.Welcome()
+	gameWorld := gameClient.World()
+
+	screenWidth, screenHeight := screenSize(gameWorld)
+	screen := render.New(os.Stdout, screenWidth, screenHeight)
+	if err := screen.Start(); err != nil {
+		logger.Error("Failed to start renderer", "err", err)
+	}
+	defer screen.Close()
+
+	viewWidth, viewHeight := viewportSize(gameWorld, screenWidth, screenHeight)
+	gameBoard := newBoard(viewWidth, viewHeight)
+	view := camera.New(viewWidth, viewHeight)
+
+	resized := make(chan struct{}, 1)
+	stopResize := input.NotifyResize(resized)
+	defer stopResize()
 
 	gameTicker := time.NewTicker(time.Second / time.Duration(cfg.GameTickRate))
 	defer gameTicker.Stop()
 
-	networkTicker := time.NewTicker(time.Second / time.Duration(cfg.GameTickRate/2))
+	inputInterval := time.Second / time.Duration(max(cfg.InputRate, 1))
+	networkTicker := time.NewTicker(inputInterval)
 	defer networkTicker.Stop()
 
-	gamePlayer := player.New(width/2, height/2)
+	gamePlayer := player.Player{ID: gameClient.PlayerID(), X: welcome.SpawnX, Y: welcome.SpawnY}
+	repeater := input.NewRepeater(cfg.MoveRepeatInterval, input.KeyHoldTimeout)
 
 	lastUpdateTime := time.Now()
 	playing := true
+	chat := &chatPane{}
 
 	var playerMutex sync.Mutex
 
+	var wg sync.WaitGroup
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
+		defer input.RestoreOnPanic(restoreTerminal)
 		for playing {
 			select {
 			case <-stopChan:
 				return
 
-			case cmd := <-inputChan:
-				playerMutex.Lock()
-				moved := false
-				switch cmd {
-				case 'w':
-					if gamePlayer.Y > 1 {
-						gamePlayer.Y--
-						moved = true
-					}
-				case 'a':
-					if gamePlayer.X > 1 {
-						gamePlayer.X--
-						moved = true
-					}
-				case 's':
-					if gamePlayer.Y < height-2 {
-						gamePlayer.Y++
-						moved = true
+			case key := <-inputChan:
+				if chat.typing {
+					if text, ok := chat.key(key); ok {
+						channel, to, message := parseChat(text)
+						if err := gameClient.Say(channel, to, message); err != nil {
+							chat.add(fmt.Sprintf("Message not sent: %v", err))
+						}
 					}
-				case 'd':
-					if gamePlayer.X < width-2 {
-						gamePlayer.X++
-						moved = true
-					}
-				case 'q':
+					break
+				}
+
+				if key.Key == input.KeyEnter || (key.Key == input.KeyRune && unicode.ToLower(key.Rune) == 't') {
+					chat.typing = true
+					break
+				}
+
+				if isQuit(key) {
 					playing = false
 					close(stopChan)
+					break
 				}
-				playerMutex.Unlock()
 
-				if moved {
+				if roomID, ok := roomKey(key); ok {
 					playerMutex.Lock()
-					sequenceNumber++
-					gamePlayer.Timestamp = time.Now().UnixMilli()
-					gamePlayer.Sequence = sequenceNumber
-					player.SendPlayerUpdate(conn, gamePlayer)
+					if err := gameClient.JoinRoom(context.Background(), roomID); err != nil {
+						notice = fmt.Sprintf("Cannot join room %d: %v", roomID, err)
+					} else {
+						welcome = gameClient.Welcome()
+						gameWorld = gameClient.World()
+						gamePlayer.X, gamePlayer.Y = welcome.SpawnX, welcome.SpawnY
+
+						viewWidth, viewHeight = viewportSize(gameWorld, screenWidth, screenHeight)
+						gameBoard = newBoard(viewWidth, viewHeight)
+						view.Resize(viewWidth, viewHeight)
+						notice = fmt.Sprintf("Joined room %d", roomID)
+					}
+					noticeTime = time.Now()
+					playerMutex.Unlock()
+					break
+				}
+
+				if key.Key == input.KeyRune && unicode.ToLower(key.Rune) == 'f' {
+					playerMutex.Lock()
+					if target, ok := nearest(gameClient.Players(), gamePlayer, TagReach); !ok {
+						notice, noticeTime = "Nobody in reach", time.Now()
+					} else if err := gameClient.Tag(target.ID); err != nil {
+						notice, noticeTime = fmt.Sprintf("Cannot tag: %v", err), time.Now()
+					}
+					playerMutex.Unlock()
+					break
+				}
+
+				if _, _, ok := direction(key); !ok || !repeater.Press(key, time.Now()) {
+					break
+				}
+
+				playerMutex.Lock()
+				if movePlayer(gameWorld, &gamePlayer, key) {
+					sendPlayerUpdate(gameClient, &gamePlayer)
 					lastUpdateTime = time.Now()
+				}
+				playerMutex.Unlock()
+
+			case <-resized:
+				playerMutex.Lock()
+				screenWidth, screenHeight = screenSize(gameWorld)
+				screen.Resize(screenWidth, screenHeight)
+				if err := screen.Start(); err != nil {
+					logger.Error("Failed to start renderer", "err", err)
+				}
+
+				viewWidth, viewHeight = viewportSize(gameWorld, screenWidth, screenHeight)
+				gameBoard = newBoard(viewWidth, viewHeight)
+				view.Resize(viewWidth, viewHeight)
+				playerMutex.Unlock()
+
+			case event := <-gameClient.Events():
+				switch {
+				case event.Type == gameclient.EventCorrection && event.Player.ID == gamePlayer.ID:
+					playerMutex.Lock()
+					gamePlayer.X, gamePlayer.Y = event.Player.X, event.Player.Y
+					notice = fmt.Sprintf("Moved back: %s", event.Reason)
+					if event.Reason == protocol.ReasonTeleported {
+						notice = "Teleported by the server"
+					}
+					noticeTime = time.Now()
+					playerMutex.Unlock()
+				case event.Type == gameclient.EventActionResult:
+					playerMutex.Lock()
+					if result := event.Action; result.PlayerID == gamePlayer.ID && result.Hit {
+						notice = "Tagged " + playerName(gameClient, result.Target)
+					} else if result.PlayerID == gamePlayer.ID {
+						notice = "Missed " + playerName(gameClient, result.Target)
+					} else if result.Target == gamePlayer.ID {
+						notice = "Tagged by " + playerName(gameClient, result.PlayerID)
+					}
+					noticeTime = time.Now()
 					playerMutex.Unlock()
+				case event.Type == gameclient.EventChat:
+					chat.add(formatChat(event.Chat))
+				case event.Type == gameclient.EventChatRejected:
+					chat.add(fmt.Sprintf("Message not sent: %v", event.Err))
+				case event.Type == gameclient.EventDisconnected:
+					exitMessage = fmt.Sprintf("Disconnected: %v", event.Err)
+					playing = false
+					close(stopChan)
 				}
 
 			case <-gameTicker.C:
 				playerMutex.Lock()
-				updateBoard(gameBoard, gamePlayer)
-				clearScreen()
-				renderGame(gameBoard)
-				fmt.Printf("\nPlayer position: (%.2f, %.2f)\n", gamePlayer.X, gamePlayer.Y)
-				fmt.Println("Enter move (w/a/s/d) or q to quit:")
+				if key, ok := repeater.Tick(time.Now()); ok && movePlayer(gameWorld, &gamePlayer, key) {
+					sendPlayerUpdate(gameClient, &gamePlayer)
+					lastUpdateTime = time.Now()
+				}
+
+				updateBoard(gameBoard, view, gameWorld, gamePlayer, gameClient.Color(), gameClient.Players())
+				renderGame(screen, logger, gameBoard, append([]string{
+					fmt.Sprintf("Room: %d  Position: (%.0f, %.0f)  View: (%d, %d)  World: %dx%d",
+						gameClient.RoomID(), gamePlayer.X, gamePlayer.Y, view.X, view.Y, gameWorld.Width, gameWorld.Height),
+					legend(gameClient, gamePlayer.ID),
+					"Move with w/a/s/d or the arrow keys, f to tag, 1-9 to switch room, t or Enter to chat, q to quit",
+					currentNotice(notice, noticeTime),
+				}, chat.view()...)...)
 				playerMutex.Unlock()
 
 			case <-networkTicker.C:
-				if time.Since(lastUpdateTime) > time.Second/time.Duration(cfg.GameTickRate) {
+				if time.Since(lastUpdateTime) > inputInterval/2 {
 					playerMutex.Lock()
-					sequenceNumber++
-					gamePlayer.Timestamp = time.Now().UnixMilli()
-					gamePlayer.Sequence = sequenceNumber
-					player.SendPlayerUpdate(conn, gamePlayer)
+					sendPlayerUpdate(gameClient, &gamePlayer)
 					lastUpdateTime = time.Now()
 					playerMutex.Unlock()
 				}
@@ -170,62 +290,237 @@ func main() {
 	wg.Wait()
 }
 
-func updateBoard(board [][]rune, gamePlayer player.Player) {
-	for i := range board {
-		for j := range board[i] {
-			board[i][j] = ' '
+func isQuit(key input.KeyEvent) bool {
+	return key.Key == input.KeyCtrlC || (key.Key == input.KeyRune && unicode.ToLower(key.Rune) == 'q')
+}
+
+// roomKey maps the digit keys 1 to 9 to the room to switch to.
+func roomKey(key input.KeyEvent) (uint32, bool) {
+	if key.Key != input.KeyRune || key.Rune < '1' || key.Rune > '9' {
+		return 0, false
+	}
+	return uint32(key.Rune - '0'), true
+}
+
+func direction(key input.KeyEvent) (int, int, bool) {
+	switch key.Key {
+	case input.KeyUp:
+		return 0, -1, true
+	case input.KeyDown:
+		return 0, 1, true
+	case input.KeyLeft:
+		return -1, 0, true
+	case input.KeyRight:
+		return 1, 0, true
+	case input.KeyRune:
+		switch unicode.ToLower(key.Rune) {
+		case 'w':
+			return 0, -1, true
+		case 's':
+			return 0, 1, true
+		case 'a':
+			return -1, 0, true
+		case 'd':
+			return 1, 0, true
 		}
 	}
+	return 0, 0, false
+}
 
-	for i := range height {
-		for j := range width {
-			if i == 0 || i == height-1 || j == 0 || j == width-1 {
-				board[i][j] = '#'
-			}
+func movePlayer(gameWorld *world.World, gamePlayer *player.Player, key input.KeyEvent) bool {
+	dx, dy, ok := direction(key)
+	if !ok {
+		return false
+	}
+
+	x, y := gamePlayer.X+float32(dx), gamePlayer.Y+float32(dy)
+	if !gameWorld.Contains(x, y) {
+		return false
+	}
+
+	gamePlayer.X, gamePlayer.Y = x, y
+	return true
+}
+
+func sendPlayerUpdate(gameClient *gameclient.Client, gamePlayer *player.Player) {
+	sent, err := gameClient.Send(*gamePlayer)
+	if err != nil {
+		gameClient.Logger().Error("Failed to send update", "err", err)
+		return
+	}
+	*gamePlayer = sent
+}
+
+// statusLines is the space below the board used for a blank line, the status
+// line, the player legend, the controls help, notices from the server and the
+// chat pane with its input line.
+const statusLines = 5 + chatLines + 1
+
+// noticeDuration is how long a notice such as a rejected move stays visible.
+const noticeDuration = 2 * time.Second
+
+func currentNotice(notice string, since time.Time) string {
+	if time.Since(since) > noticeDuration {
+		return ""
+	}
+	return notice
+}
+
+// screenSize returns the terminal size in columns and rows, falling back to a
+// screen that fits the whole world when it cannot be queried.
+func screenSize(gameWorld *world.World) (int, int) {
+	width, height, err := input.TerminalSize(int(os.Stdout.Fd()))
+	if err != nil {
+		return gameWorld.Width * 2, gameWorld.Height + statusLines
+	}
+	return width, height
+}
+
+// viewportSize returns how many world cells fit on screen. Every cell is drawn
+// two columns wide and the viewport never exceeds the world.
+func viewportSize(gameWorld *world.World, screenWidth, screenHeight int) (int, int) {
+	width := min(max(screenWidth/2, 1), gameWorld.Width)
+	height := min(max(screenHeight-statusLines, 1), gameWorld.Height)
+	return width, height
+}
+
+// cell is a single board position as drawn on screen.
+type cell struct {
+	glyph rune
+	color render.Color
+}
+
+func newBoard(width, height int) [][]cell {
+	board := make([][]cell, height)
+	for i := range board {
+		board[i] = make([]cell, width)
+	}
+	return board
+}
+
+// glyph is how a remote player is drawn: the initial of its name in its
+// color, or an X until its profile arrives.
+func glyph(players *player.Store, id int32) cell {
+	profile, exists := players.Profile(id)
+	if !exists || profile.Name == "" {
+		return cell{glyph: 'X'}
+	}
+	return cell{glyph: unicode.ToUpper(rune(profile.Name[0])), color: render.Color(profile.Color)}
+}
+
+// legend names the local player and every remote player the client knows of.
+func legend(gameClient *gameclient.Client, localID int32) string {
+	var others []player.Player
+	gameClient.Players().Range(func(otherPlayer player.Player) bool {
+		if otherPlayer.ID != localID {
+			others = append(others, otherPlayer)
 		}
+		return true
+	})
+	slices.SortFunc(others, func(a, b player.Player) int {
+		return int(a.ID - b.ID)
+	})
+
+	entries := []string{fmt.Sprintf("o %s (you)", gameClient.Name())}
+	for _, otherPlayer := range others {
+		entries = append(entries, fmt.Sprintf("%c %s", glyph(gameClient.Players(), otherPlayer.ID).glyph, playerName(gameClient, otherPlayer.ID)))
 	}
+	return "Players: " + strings.Join(entries, "  ")
+}
 
-	px, py := int(gamePlayer.X), int(gamePlayer.Y)
-	if px >= 0 && px < width && py >= 0 && py < height {
-		board[py][px] = 'o'
+func playerName(gameClient *gameclient.Client, id int32) string {
+	if profile, exists := gameClient.Players().Profile(id); exists {
+		return profile.Name
 	}
+	return fmt.Sprintf("player %d", id)
+}
 
-	player.Players.Range(func(_, value any) bool {
-		otherPlayer, ok := value.(player.Player)
-		if !ok {
-			return true
+// TagReach is how far, in cells, the server lets a tag reach.
+const TagReach = 1.5
+
+// nearest finds the other player closest to the local one within reach.
+func nearest(players *player.Store, gamePlayer player.Player, reach float64) (player.Player, bool) {
+	var found player.Player
+	closest := reach
+	ok := false
+	players.Range(func(otherPlayer player.Player) bool {
+		distance := math.Hypot(float64(otherPlayer.X-gamePlayer.X), float64(otherPlayer.Y-gamePlayer.Y))
+		if otherPlayer.ID != gamePlayer.ID && distance <= closest {
+			found, closest, ok = otherPlayer, distance, true
 		}
+		return true
+	})
+	return found, ok
+}
 
-		predicted := player.PredictPosition(otherPlayer)
+// updateBoard draws the part of the world visible through the camera, which
+// follows the local player. Remote players outside of the view are shown as
+// arrows on the edge pointing towards them.
+func updateBoard(board [][]cell, view *camera.Camera, gameWorld *world.World, gamePlayer player.Player, localColor protocol.Color, players *player.Store) {
+	px, py := int(gamePlayer.X), int(gamePlayer.Y)
+	view.Follow(px, py, gameWorld.Width, gameWorld.Height)
+
+	for i := range board {
+		for j := range board[i] {
+			board[i][j] = cell{glyph: ' '}
+			if gameWorld.Tile(view.X+j, view.Y+i) == world.TileWall {
+				board[i][j] = cell{glyph: '#'}
+			}
+		}
+	}
+
+	players.Range(func(otherPlayer player.Player) bool {
+		predicted := players.PredictPosition(otherPlayer)
 		if otherPlayer.ID != gamePlayer.ID {
 			ox, oy := int(predicted.X), int(predicted.Y)
-			if ox >= 0 && ox < width && oy >= 0 && oy < height {
-				board[oy][ox] = 'X'
+			drawn := glyph(players, otherPlayer.ID)
+			if view.Visible(ox, oy) {
+				sx, sy := view.ToScreen(ox, oy)
+				board[sy][sx] = drawn
+			} else {
+				sx, sy, arrow := view.EdgeIndicator(ox, oy)
+				board[sy][sx] = cell{glyph: arrow, color: drawn.color}
 			}
 		}
 
-		player.Players.Store(otherPlayer.ID, predicted)
+		players.CompareAndSwap(otherPlayer, predicted)
 
 		return true
 	})
+
+	if view.Visible(px, py) {
+		sx, sy := view.ToScreen(px, py)
+		board[sy][sx] = cell{glyph: 'o', color: render.Color(localColor)}
+	}
 }
 
-func renderGame(board [][]rune) {
-	for _, row := range board {
-		for _, cell := range row {
-			fmt.Printf("%c ", cell)
+func renderGame(screen *render.Renderer, logger *slog.Logger, board [][]cell, status ...string) {
+	screen.Clear()
+	for y, row := range board {
+		for x, c := range row {
+			screen.SetColor(x*2, y, c.glyph, c.color)
 		}
-		fmt.Println()
+	}
+
+	for i, line := range status {
+		screen.SetString(0, len(board)+1+i, line)
+	}
+
+	if err := screen.Flush(); err != nil {
+		logger.Error("Failed to render", "err", err)
 	}
 }
 
-func clearScreen() {
-	var cmd *exec.Cmd
-	if runtime.GOOS == "windows" {
-		cmd = exec.Command("cmd", "/c", "cls")
-	} else {
-		cmd = exec.Command("clear")
+// newLogger writes to LOG_FILE when set, so that logs do not draw over the
+// game, and to stderr otherwise.
+func newLogger(cfg config.Config) (*slog.Logger, error) {
+	out := os.Stderr
+	if cfg.LogFile != "" {
+		file, err := os.OpenFile(cfg.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		out = file
 	}
-	cmd.Stdout = os.Stdout
-	cmd.Run()
+	return logging.New(out, cfg.LogLevel, cfg.LogFormat)
 }
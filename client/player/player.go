@@ -1,13 +1,12 @@
 package player
 
 import (
-	"bytes"
-	"encoding/binary"
-	"log"
 	"math/rand/v2"
-	"net"
 	"sync"
 	"time"
+
+	"github.com/zainokta/client-server-multiplayer/shared/clock"
+	"github.com/zainokta/client-server-multiplayer/shared/protocol"
 )
 
 const (
@@ -24,29 +23,30 @@ type Player struct {
 	Sequence  uint32
 }
 
-var Players sync.Map
+// MaxID bounds the random player IDs. The server rejects an ID that is
+// already used in the room, so the range is wide enough to make that rare.
+const MaxID = 1 << 16
+
+// NewID picks a random player ID.
+func NewID() int32 {
+	return rand.Int32N(MaxID)
+}
 
 func New(x float32, y float32) Player {
 	return Player{
-		ID: int32(rand.IntN(8)),
+		ID: NewID(),
 		X:  x,
 		Y:  y,
 	}
 }
 
-func serializePlayer(player Player) ([]byte, error) {
-	buf := new(bytes.Buffer)
-	err := binary.Write(buf, binary.LittleEndian, player)
-	if err != nil {
-		return nil, err
-	}
-	return buf.Bytes(), nil
+func SerializePlayer(player Player) ([]byte, error) {
+	return protocol.Encode(protocol.MsgPlayerUpdate, player)
 }
 
-func deserializePlayer(data []byte) (Player, error) {
+func DeserializePlayer(data []byte) (Player, error) {
 	var player Player
-	buf := bytes.NewReader(data)
-	err := binary.Read(buf, binary.LittleEndian, &player)
+	err := protocol.Decode(data, protocol.MsgPlayerUpdate, &player)
 	return player, err
 }
 
@@ -56,15 +56,96 @@ func interpolatePlayer(last, current Player) Player {
 	return current
 }
 
-func reconcilePlayerPosition(gamePlayer Player) {
-	lastVal, exists := Players.Load(gamePlayer.ID)
+// Profile is how a player presents itself to others.
+type Profile struct {
+	ID    int32
+	Name  string
+	Color protocol.Color
+}
+
+// Store holds the latest known state of every player seen by a client, and
+// the profiles of the players in its room.
+type Store struct {
+	players  sync.Map
+	profiles sync.Map
+	clock    clock.Clock
+}
+
+type StoreOption func(*Store)
+
+// WithClock sets the clock that stamps received updates. Defaults to
+// clock.Real.
+func WithClock(c clock.Clock) StoreOption {
+	return func(s *Store) {
+		s.clock = c
+	}
+}
+
+func NewStore(opts ...StoreOption) *Store {
+	s := &Store{clock: clock.Real}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *Store) Load(id int32) (Player, bool) {
+	value, exists := s.players.Load(id)
+	if !exists {
+		return Player{}, false
+	}
+	return value.(Player), true
+}
+
+func (s *Store) Store(p Player) {
+	s.players.Store(p.ID, p)
+}
+
+// CompareAndSwap replaces old with updated only if old is still the stored
+// state, so a player removed or updated concurrently is not overwritten.
+func (s *Store) CompareAndSwap(old, updated Player) bool {
+	return s.players.CompareAndSwap(old.ID, old, updated)
+}
+
+func (s *Store) Delete(id int32) {
+	s.players.Delete(id)
+}
+
+func (s *Store) SetProfile(p Profile) {
+	s.profiles.Store(p.ID, p)
+}
+
+func (s *Store) Profile(id int32) (Profile, bool) {
+	value, exists := s.profiles.Load(id)
+	if !exists {
+		return Profile{}, false
+	}
+	return value.(Profile), true
+}
+
+// Clear forgets every player. Profiles are kept, since the server sends the
+// profiles of a new room before the client has finished joining it.
+func (s *Store) Clear() {
+	s.players.Range(func(key, _ any) bool {
+		s.players.Delete(key)
+		return true
+	})
+}
+
+func (s *Store) Range(fn func(p Player) bool) {
+	s.players.Range(func(_, value any) bool {
+		return fn(value.(Player))
+	})
+}
+
+func (s *Store) reconcilePlayerPosition(gamePlayer Player) {
+	clientPlayer, exists := s.Load(gamePlayer.ID)
 	if exists {
-		clientPlayer := lastVal.(Player)
 		if gamePlayer.Sequence <= clientPlayer.Sequence {
 			return
 		}
 
-		if time.Now().UnixMilli()-gamePlayer.Timestamp > int64(MaxRewindTime) {
+		if s.clock.Now().UnixMilli()-gamePlayer.Timestamp > int64(MaxRewindTime) {
 			clientPlayer.X = gamePlayer.X
 			clientPlayer.Y = gamePlayer.Y
 		} else {
@@ -72,31 +153,27 @@ func reconcilePlayerPosition(gamePlayer Player) {
 		}
 
 		clientPlayer.Sequence = gamePlayer.Sequence
-		Players.Store(clientPlayer.ID, clientPlayer)
+		s.Store(clientPlayer)
 	}
 }
 
-func receiveUpdate(buf []byte, n int) error {
-	now := time.Now().UnixMilli()
-
-	updatedPlayer, err := deserializePlayer(buf[:n])
-	if err != nil {
-		log.Println("Failed to decode: ", err)
-		return err
-	}
+// Apply reconciles an update received from the server and stores it with the
+// local receive time. It reports whether the player was not known before.
+func (s *Store) Apply(updatedPlayer Player) bool {
+	now := s.clock.Now().UnixMilli()
 
-	reconcilePlayerPosition(updatedPlayer)
+	_, known := s.Load(updatedPlayer.ID)
+	s.reconcilePlayerPosition(updatedPlayer)
 
 	updatedPlayer.Timestamp = now
-	Players.Store(updatedPlayer.ID, updatedPlayer)
+	s.Store(updatedPlayer)
 
-	return nil
+	return !known
 }
 
-func PredictPosition(p Player) Player {
-	lastVal, exists := Players.Load(p.ID)
+func (s *Store) PredictPosition(p Player) Player {
+	lastPlayer, exists := s.Load(p.ID)
 	if exists {
-		lastPlayer := lastVal.(Player)
 		timeDiff := float32(p.Timestamp-lastPlayer.Timestamp) / 1000.0
 		if timeDiff < MinTimeDiff {
 			return lastPlayer
@@ -111,28 +188,3 @@ func PredictPosition(p Player) Player {
 
 	return p
 }
-
-func GetPlayerUpdate(conn *net.UDPConn) {
-	buf := make([]byte, 1024)
-	for {
-		n, err := conn.Read(buf)
-		if err != nil {
-			log.Println("Error receiving: ", err)
-			continue
-		}
-
-		receiveUpdate(buf, n)
-	}
-}
-
-func SendPlayerUpdate(conn *net.UDPConn, gamePlayer Player) {
-	data, err := serializePlayer(gamePlayer)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	_, err = conn.Write(data)
-	if err != nil {
-		log.Fatal(err)
-	}
-}
@@ -0,0 +1,155 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+const (
+	hideCursor  = "\x1b[?25l"
+	showCursor  = "\x1b[?25h"
+	clearScreen = "\x1b[2J"
+	resetStyle  = "\x1b[0m"
+)
+
+// Color is a terminal foreground color, in the order of the ANSI codes.
+type Color uint8
+
+const (
+	ColorDefault Color = iota
+	ColorRed
+	ColorGreen
+	ColorYellow
+	ColorBlue
+	ColorMagenta
+	ColorCyan
+	ColorWhite
+)
+
+// sgr returns the escape sequence that switches to the color.
+func (c Color) sgr() string {
+	if c == ColorDefault || c > ColorWhite {
+		return resetStyle
+	}
+	return fmt.Sprintf("\x1b[%dm", 30+int(c))
+}
+
+type cell struct {
+	ch    rune
+	color Color
+}
+
+// Renderer draws a fixed size grid of terminal cells using ANSI escape
+// sequences. Drawing happens into a back buffer; Flush compares it with what
+// is already on screen and writes only the cells that changed, in a single
+// write to the underlying writer.
+type Renderer struct {
+	w      io.Writer
+	width  int
+	height int
+	front  []cell
+	back   []cell
+	full   bool
+	out    bytes.Buffer
+}
+
+func New(w io.Writer, width, height int) *Renderer {
+	r := &Renderer{w: w}
+	r.Resize(width, height)
+	return r
+}
+
+// Resize changes the grid size and forces the next Flush to redraw everything.
+func (r *Renderer) Resize(width, height int) {
+	r.width = width
+	r.height = height
+	r.front = make([]cell, width*height)
+	r.back = make([]cell, width*height)
+	r.full = true
+	r.Clear()
+}
+
+func (r *Renderer) Size() (int, int) {
+	return r.width, r.height
+}
+
+// Clear blanks the back buffer.
+func (r *Renderer) Clear() {
+	for i := range r.back {
+		r.back[i] = cell{ch: ' '}
+	}
+}
+
+// Set draws a single rune into the back buffer. Out of range cells are ignored.
+func (r *Renderer) Set(x, y int, ch rune) {
+	r.SetColor(x, y, ch, ColorDefault)
+}
+
+// SetColor draws a single rune in the given color like Set.
+func (r *Renderer) SetColor(x, y int, ch rune, color Color) {
+	if x < 0 || x >= r.width || y < 0 || y >= r.height {
+		return
+	}
+	r.back[y*r.width+x] = cell{ch: ch, color: color}
+}
+
+// SetString draws s starting at (x, y), clipped to the grid width.
+func (r *Renderer) SetString(x, y int, s string) {
+	for _, ch := range s {
+		r.Set(x, y, ch)
+		x++
+	}
+}
+
+// Start hides the cursor and clears the terminal.
+func (r *Renderer) Start() error {
+	r.full = true
+	_, err := io.WriteString(r.w, hideCursor+clearScreen)
+	return err
+}
+
+// Close restores the cursor and moves it below the grid.
+func (r *Renderer) Close() error {
+	_, err := fmt.Fprintf(r.w, "%s\x1b[%d;1H%s\r\n", resetStyle, r.height, showCursor)
+	return err
+}
+
+// Flush writes the difference between the back buffer and the screen.
+func (r *Renderer) Flush() error {
+	r.out.Reset()
+
+	cursorX, cursorY := -1, -1
+	color := ColorDefault
+	for y := 0; y < r.height; y++ {
+		for x := 0; x < r.width; x++ {
+			i := y*r.width + x
+			if !r.full && r.front[i] == r.back[i] {
+				continue
+			}
+
+			if x != cursorX || y != cursorY {
+				fmt.Fprintf(&r.out, "\x1b[%d;%dH", y+1, x+1)
+			}
+			if r.back[i].color != color {
+				color = r.back[i].color
+				r.out.WriteString(color.sgr())
+			}
+			r.out.WriteRune(r.back[i].ch)
+			r.front[i] = r.back[i]
+			cursorX, cursorY = x+1, y
+		}
+	}
+	r.full = false
+
+	if color != ColorDefault {
+		r.out.WriteString(resetStyle)
+	}
+
+	if r.out.Len() == 0 {
+		return nil
+	}
+
+	_, err := r.w.Write(r.out.Bytes())
+	return err
+}
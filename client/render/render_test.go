@@ -0,0 +1,84 @@
+package render
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlushWritesOnlyChangedCells(t *testing.T) {
+	var out bytes.Buffer
+	r := New(&out, 3, 2)
+
+	r.SetString(0, 0, "ab")
+	assert.NoError(t, r.Flush())
+	assert.Equal(t, "\x1b[1;1Hab \x1b[2;1H   ", out.String(), "first frame draws every cell")
+
+	out.Reset()
+	r.Clear()
+	r.SetString(0, 0, "ab")
+	assert.NoError(t, r.Flush())
+	assert.Empty(t, out.String(), "unchanged frame writes nothing")
+
+	out.Reset()
+	r.Clear()
+	r.SetString(0, 0, "a")
+	r.Set(2, 1, '#')
+	assert.NoError(t, r.Flush())
+	assert.Equal(t, "\x1b[1;2H \x1b[2;3H#", out.String())
+}
+
+func TestSetIgnoresOutOfRange(t *testing.T) {
+	var out bytes.Buffer
+	r := New(&out, 2, 1)
+
+	r.Set(-1, 0, 'x')
+	r.Set(2, 0, 'x')
+	r.Set(0, 1, 'x')
+	r.SetString(1, 0, "xyz")
+	assert.NoError(t, r.Flush())
+	assert.Equal(t, "\x1b[1;1H x", out.String())
+}
+
+func TestStartAndResizeForceFullRedraw(t *testing.T) {
+	var out bytes.Buffer
+	r := New(&out, 1, 1)
+	assert.NoError(t, r.Flush())
+
+	out.Reset()
+	assert.NoError(t, r.Start())
+	assert.Equal(t, hideCursor+clearScreen, out.String())
+
+	out.Reset()
+	assert.NoError(t, r.Flush())
+	assert.Equal(t, "\x1b[1;1H ", out.String())
+
+	out.Reset()
+	r.Resize(2, 1)
+	assert.NoError(t, r.Flush())
+	assert.Equal(t, "\x1b[1;1H  ", out.String())
+}
+
+func TestFlushWritesColors(t *testing.T) {
+	var out bytes.Buffer
+	r := New(&out, 4, 1)
+
+	r.SetColor(0, 0, '@', ColorRed)
+	r.SetColor(1, 0, 'B', ColorRed)
+	r.SetColor(2, 0, 'C', ColorCyan)
+	assert.NoError(t, r.Flush())
+	assert.Equal(t, "\x1b[1;1H\x1b[31m@B\x1b[36mC\x1b[0m ", out.String())
+
+	out.Reset()
+	r.Clear()
+	r.SetColor(0, 0, '@', ColorBlue)
+	r.SetColor(1, 0, 'B', ColorRed)
+	assert.NoError(t, r.Flush())
+	assert.Equal(t, "\x1b[1;1H\x1b[34m@\x1b[1;3H\x1b[0m ", out.String(), "a color change alone redraws the cell")
+
+	out.Reset()
+	r.SetColor(3, 0, 'D', ColorGreen)
+	assert.NoError(t, r.Flush())
+	assert.Equal(t, "\x1b[1;4H\x1b[32mD\x1b[0m", out.String(), "the style is reset after the frame")
+}
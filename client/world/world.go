@@ -0,0 +1,74 @@
+package world
+
+import "errors"
+
+var ErrRowsOutOfRange = errors.New("map rows out of range")
+
+type Tile uint8
+
+const (
+	TileFloor Tile = iota
+	TileWall
+)
+
+// World is the tile map received from the server. It is filled row by row
+// while the map chunks arrive; anything outside the grid counts as wall.
+type World struct {
+	Width  int
+	Height int
+
+	tiles    []Tile
+	received []bool
+	missing  int
+}
+
+func New(width, height int) *World {
+	return &World{
+		Width:    width,
+		Height:   height,
+		tiles:    make([]Tile, width*height),
+		received: make([]bool, height),
+		missing:  height,
+	}
+}
+
+// SetRows stores rows starting at startRow. Rows that were already received
+// are overwritten, so duplicate chunks are harmless.
+func (w *World) SetRows(startRow, rows int, tiles []byte) error {
+	if startRow < 0 || startRow+rows > w.Height || len(tiles) != rows*w.Width {
+		return ErrRowsOutOfRange
+	}
+
+	for i, t := range tiles {
+		w.tiles[startRow*w.Width+i] = Tile(t)
+	}
+
+	for y := startRow; y < startRow+rows; y++ {
+		if !w.received[y] {
+			w.received[y] = true
+			w.missing--
+		}
+	}
+
+	return nil
+}
+
+// Complete reports whether every row of the map has been received.
+func (w *World) Complete() bool {
+	return w.missing == 0
+}
+
+func (w *World) Tile(x, y int) Tile {
+	if x < 0 || y < 0 || x >= w.Width || y >= w.Height {
+		return TileWall
+	}
+	return w.tiles[y*w.Width+x]
+}
+
+// Contains reports whether a player may stand at (x, y).
+func (w *World) Contains(x, y float32) bool {
+	if x < 0 || y < 0 {
+		return false
+	}
+	return w.Tile(int(x), int(y)) == TileFloor
+}
@@ -0,0 +1,27 @@
+package world
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetRows(t *testing.T) {
+	w := New(3, 3)
+	assert.False(t, w.Complete())
+
+	assert.NoError(t, w.SetRows(0, 2, []byte{1, 1, 1, 1, 0, 1}))
+	assert.NoError(t, w.SetRows(0, 1, []byte{1, 1, 1}), "duplicate rows are accepted")
+	assert.False(t, w.Complete())
+
+	assert.NoError(t, w.SetRows(2, 1, []byte{1, 1, 1}))
+	assert.True(t, w.Complete())
+
+	assert.True(t, w.Contains(1, 1))
+	assert.False(t, w.Contains(0, 1))
+	assert.False(t, w.Contains(5, 5))
+	assert.Equal(t, TileWall, w.Tile(-1, 0))
+
+	assert.ErrorIs(t, w.SetRows(2, 2, []byte{1, 1, 1, 1, 1, 1}), ErrRowsOutOfRange)
+	assert.ErrorIs(t, w.SetRows(0, 1, []byte{1}), ErrRowsOutOfRange)
+}
@@ -0,0 +1,83 @@
+package e2e
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zainokta/client-server-multiplayer/client/gameclient"
+	"github.com/zainokta/client-server-multiplayer/server/config"
+	"github.com/zainokta/client-server-multiplayer/server/game"
+)
+
+func TestBroadcast(t *testing.T) {
+	h := Start(t, config.Config{})
+	alice := h.Join("alice", 0)
+	bob := h.Join("bob", 0)
+
+	x, y := alice.Position()
+	h.Eventually(func() bool { return bob.SeesAt(alice, x, y) }, "bob sees alice spawn")
+
+	alice.Move(0, 1)
+	h.Eventually(func() bool { return bob.SeesAt(alice, x, y+1) }, "bob sees alice move")
+	h.Eventually(func() bool {
+		bx, by := bob.Position()
+		return alice.SeesAt(bob, bx, by)
+	}, "alice sees bob")
+}
+
+func TestJoinAndKick(t *testing.T) {
+	h := Start(t, config.Config{})
+	alice := h.Join("alice", 0)
+	bob := h.Join("bob", 0)
+
+	h.Eventually(func() bool { return alice.Saw(gameclient.EventPlayerJoined, bob) })
+
+	assert.NoError(t, h.Server.Rooms().Kick(bob.RoomID(), bob.PlayerID()))
+	h.Eventually(func() bool { return alice.Saw(gameclient.EventPlayerLeft, bob) }, "alice sees bob leave")
+	h.Eventually(func() bool { return len(bob.Events(gameclient.EventDisconnected)) == 1 }, "bob is told")
+}
+
+func TestRoomsAreSeparate(t *testing.T) {
+	h := Start(t, config.Config{Rooms: 2})
+	alice := h.Join("alice", 1)
+	bob := h.Join("bob", 2)
+	carol := h.Join("carol", 1)
+
+	x, y := alice.Position()
+	h.Eventually(func() bool { return carol.SeesAt(alice, x, y) })
+	h.Advance(5)
+	_, seen := carol.Sees(bob)
+	assert.False(t, seen, "players in other rooms are never replicated")
+}
+
+func TestOutOfBoundsMoveIsCorrected(t *testing.T) {
+	h := Start(t, config.Config{})
+	alice := h.Join("alice", 0)
+	x, y := alice.Position()
+
+	alice.MoveTo(-5, y)
+	h.Eventually(func() bool { return len(alice.Events(gameclient.EventCorrection)) > 0 })
+	h.Advance(2)
+
+	gotX, gotY := alice.Position()
+	assert.Equal(t, x, gotX)
+	assert.Equal(t, y, gotY)
+}
+
+func TestSilentClientIsRemoved(t *testing.T) {
+	h := Start(t, config.Config{})
+	alice := h.Join("alice", 0)
+	bob := h.Join("bob", 0)
+	// Alice stands on the shared spawn, so bob is sent next to it.
+	h.Eventually(func() bool {
+		x, y := bob.Position()
+		return alice.SeesAt(bob, x, y)
+	})
+
+	// Silent players are looked for every DisconnectTimer and removed once
+	// they have been silent for as long.
+	bob.Leave()
+	left := h.Clock.Now()
+	h.Eventually(func() bool { return alice.Saw(gameclient.EventPlayerLeft, bob) }, "alice sees bob time out")
+	assert.Greater(t, h.Clock.Since(left), game.DisconnectTimer, "bob was silent for the whole timer")
+}
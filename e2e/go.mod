@@ -0,0 +1,22 @@
+module github.com/zainokta/client-server-multiplayer/e2e
+
+go 1.22.0
+
+require (
+	github.com/stretchr/testify v1.10.0
+	github.com/zainokta/client-server-multiplayer/client v0.0.0
+	github.com/zainokta/client-server-multiplayer/server v0.0.0
+	github.com/zainokta/client-server-multiplayer/shared v0.0.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+replace (
+	github.com/zainokta/client-server-multiplayer/client => ../client
+	github.com/zainokta/client-server-multiplayer/server => ../server
+	github.com/zainokta/client-server-multiplayer/shared => ../shared
+)
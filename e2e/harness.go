@@ -0,0 +1,308 @@
+// Package e2e runs the real server and real clients in one process, so
+// tests can drive players over UDP and check what every client sees.
+package e2e
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientconfig "github.com/zainokta/client-server-multiplayer/client/config"
+	"github.com/zainokta/client-server-multiplayer/client/gameclient"
+	"github.com/zainokta/client-server-multiplayer/client/player"
+	"github.com/zainokta/client-server-multiplayer/server/config"
+	"github.com/zainokta/client-server-multiplayer/server/server"
+	"github.com/zainokta/client-server-multiplayer/shared/clock"
+)
+
+const (
+	// WaitTimeout is how long Eventually and Advance wait before failing,
+	// in wall time.
+	WaitTimeout = 3 * time.Second
+	// KeepAliveInterval is how often clients resend their position while
+	// idle, like the terminal client does, so the server keeps them.
+	KeepAliveInterval = 100 * time.Millisecond
+)
+
+// Harness is a running server along with the clients joined to it. The
+// server and the clients run on one fake clock that only moves in Eventually
+// and Advance, one tick at a time.
+type Harness struct {
+	t      testing.TB
+	Server *server.Server
+	// Clock is the clock of the server and of every client.
+	Clock *clock.Fake
+	tick  time.Duration
+
+	mu      sync.Mutex
+	nextID  int32
+	clients []*Client
+}
+
+// Start runs a server on an ephemeral loopback port for the rest of the
+// test. Zero settings get small test defaults: a 20x10 world, 30 ticks per
+// second and a snapshot every tick.
+func Start(t testing.TB, cfg config.Config) *Harness {
+	t.Helper()
+
+	cfg.Port = 0
+	if cfg.GameTickRate == 0 {
+		cfg.GameTickRate = 30
+	}
+	if cfg.WorldWidth == 0 || cfg.WorldHeight == 0 {
+		cfg.WorldWidth, cfg.WorldHeight = 20, 10
+	}
+	if cfg.GameMode == "" {
+		cfg.GameMode = "classic"
+	}
+
+	clk := clock.NewFake(time.Unix(1_700_000_000, 0))
+	srv := server.New(cfg,
+		server.WithListenIP(net.IPv4(127, 0, 0, 1)),
+		server.WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+		server.WithClock(clk),
+	)
+	require.NoError(t, srv.Start(context.Background()))
+
+	h := &Harness{
+		t:      t,
+		Server: srv,
+		Clock:  clk,
+		tick:   time.Second / time.Duration(cfg.GameTickRate),
+	}
+	t.Cleanup(h.stop)
+	return h
+}
+
+func (h *Harness) stop() {
+	h.mu.Lock()
+	clients := h.clients
+	h.clients = nil
+	h.mu.Unlock()
+
+	for _, c := range clients {
+		c.Leave()
+	}
+	h.Server.Stop()
+}
+
+// Join connects a new client named name to the room, or to any room with a
+// free seat when room is zero. Player IDs count up from 1.
+func (h *Harness) Join(name string, room uint32, opts ...gameclient.Option) *Client {
+	h.t.Helper()
+
+	h.mu.Lock()
+	h.nextID++
+	id := h.nextID
+	h.mu.Unlock()
+
+	c := &Client{t: h.t, done: make(chan struct{})}
+	opts = append([]gameclient.Option{
+		gameclient.WithServerAddr(h.Server.Addr().(*net.UDPAddr)),
+		gameclient.WithPlayerID(id),
+		gameclient.WithName(name),
+		gameclient.WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+		gameclient.WithOnEvent(c.record),
+		gameclient.WithClock(h.Clock),
+	}, opts...)
+	c.Client = gameclient.New(clientconfig.Config{RoomID: room}, opts...)
+
+	// The connection lives as long as the context of Connect. The handshake
+	// retries and times out on the client clock, so it moves while joining.
+	connected := make(chan error, 1)
+	go func() { connected <- c.Connect(context.Background()) }()
+	var err error
+	require.True(h.t, h.Eventually(func() bool {
+		select {
+		case err = <-connected:
+			return true
+		default:
+			return false
+		}
+	}), "join %s", name)
+	require.NoError(h.t, err, "join %s", name)
+
+	welcome := c.Welcome()
+	c.position = player.Player{ID: id, X: welcome.SpawnX, Y: welcome.SpawnY}
+	c.send()
+	// The ticker is armed before Join returns, so the next tick of the
+	// harness already keeps the client alive.
+	go c.keepAlive(h.Clock.NewTicker(KeepAliveInterval))
+
+	h.mu.Lock()
+	h.clients = append(h.clients, c)
+	h.mu.Unlock()
+	return c
+}
+
+// Advance moves the clock forward until every room has stepped at least
+// ticks more times.
+func (h *Harness) Advance(ticks int) {
+	h.t.Helper()
+
+	start := make(map[uint32]uint64)
+	for _, info := range h.Server.Rooms().Rooms() {
+		start[info.ID] = info.Tick
+	}
+
+	h.Eventually(func() bool {
+		for _, info := range h.Server.Rooms().Rooms() {
+			if info.Tick < start[info.ID]+uint64(ticks) {
+				return false
+			}
+		}
+		return true
+	}, "rooms did not advance %d ticks", ticks)
+}
+
+// Eventually moves the clock forward one tick at a time until condition
+// holds, and fails the test unless it does within WaitTimeout.
+func (h *Harness) Eventually(condition func() bool, msgAndArgs ...any) bool {
+	h.t.Helper()
+
+	deadline := time.Now().Add(WaitTimeout)
+	for !condition() {
+		if time.Now().After(deadline) {
+			return assert.Fail(h.t, "Condition never satisfied", msgAndArgs...)
+		}
+		h.Clock.Advance(h.tick)
+		// Let the server and the clients handle what the tick sent.
+		time.Sleep(time.Millisecond)
+	}
+	return true
+}
+
+// Client is a real client joined by the harness. It keeps the position of
+// its player, follows the corrections of the server and records every event.
+type Client struct {
+	*gameclient.Client
+	t testing.TB
+
+	mu       sync.Mutex
+	position player.Player
+	events   []gameclient.Event
+	left     bool
+	done     chan struct{}
+}
+
+// Move moves the player by dx, dy cells and sends the move right away.
+func (c *Client) Move(dx, dy float32) {
+	c.mu.Lock()
+	c.position.X += dx
+	c.position.Y += dy
+	c.mu.Unlock()
+
+	c.send()
+}
+
+// MoveTo moves the player to x, y and sends the move right away.
+func (c *Client) MoveTo(x, y float32) {
+	c.mu.Lock()
+	c.position.X, c.position.Y = x, y
+	c.mu.Unlock()
+
+	c.send()
+}
+
+// Position returns where the client believes its player is.
+func (c *Client) Position() (float32, float32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.position.X, c.position.Y
+}
+
+// Sees returns the player of other as this client last received it.
+func (c *Client) Sees(other *Client) (player.Player, bool) {
+	return c.Players().Load(other.PlayerID())
+}
+
+// SeesAt reports whether this client has other at x, y.
+func (c *Client) SeesAt(other *Client, x, y float32) bool {
+	p, seen := c.Sees(other)
+	return seen && p.X == x && p.Y == y
+}
+
+// Events returns the recorded events of the given type.
+func (c *Client) Events(eventType gameclient.EventType) []gameclient.Event {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var events []gameclient.Event
+	for _, e := range c.events {
+		if e.Type == eventType {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// Saw reports whether an event of the given type concerned other.
+func (c *Client) Saw(eventType gameclient.EventType, other *Client) bool {
+	for _, e := range c.Events(eventType) {
+		if e.Player.ID == other.PlayerID() {
+			return true
+		}
+	}
+	return false
+}
+
+// Leave closes the connection without telling the server, like a client
+// that crashed or lost its network. Leaving twice does nothing.
+func (c *Client) Leave() {
+	c.mu.Lock()
+	if c.left {
+		c.mu.Unlock()
+		return
+	}
+	c.left = true
+	close(c.done)
+	c.mu.Unlock()
+
+	// Close waits for the receive goroutine, which may be waiting for the
+	// lock in record.
+	c.Close()
+}
+
+func (c *Client) record(e gameclient.Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.events = append(c.events, e)
+	if e.Type == gameclient.EventCorrection && e.Player.ID == c.position.ID {
+		c.position.X, c.position.Y = e.Player.X, e.Player.Y
+	}
+}
+
+func (c *Client) send() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.left {
+		return
+	}
+	sent, err := c.Send(c.position)
+	if err != nil {
+		c.t.Errorf("send %v: %v", c.position, err)
+		return
+	}
+	c.position = sent
+}
+
+func (c *Client) keepAlive(ticker clock.Ticker) {
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C():
+			c.send()
+		case <-c.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,209 @@
+// Package admin serves a JSON API for operators and dashboards to inspect and
+// manage a running server. Every request needs the configured bearer token.
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/zainokta/client-server-multiplayer/server/room"
+)
+
+// Player is a connected player as listed by GET /players.
+type Player struct {
+	ID         int32     `json:"id"`
+	Name       string    `json:"name"`
+	Room       uint32    `json:"room"`
+	Addr       string    `json:"addr"`
+	X          float32   `json:"x"`
+	Y          float32   `json:"y"`
+	RTTMs      float64   `json:"rtt_ms"`
+	Loss       float64   `json:"packet_loss"`
+	SendRate   int       `json:"send_rate"`
+	Violations float64   `json:"violations"`
+	LastSeen   time.Time `json:"last_seen"`
+}
+
+// Room is a room as listed by GET /rooms.
+type Room struct {
+	ID             uint32  `json:"id"`
+	Name           string  `json:"name"`
+	Private        bool    `json:"private"`
+	Players        int     `json:"players"`
+	Capacity       int     `json:"capacity"`
+	Width          int     `json:"width"`
+	Height         int     `json:"height"`
+	TickRate       int     `json:"tick_rate"`
+	TickDurationMs float64 `json:"tick_duration_ms"`
+}
+
+// Tick is the answer of GET /tick.
+type Tick struct {
+	Rooms []RoomTick `json:"rooms"`
+}
+
+type RoomTick struct {
+	ID             uint32  `json:"id"`
+	TickRate       int     `json:"tick_rate"`
+	TickDurationMs float64 `json:"tick_duration_ms"`
+	// BudgetMs is the time available per tick at the tick rate.
+	BudgetMs float64 `json:"budget_ms"`
+	Tick     uint64  `json:"tick"`
+	Overruns uint64  `json:"overruns"`
+}
+
+// BanRequest is the body of POST /bans. Addr is an IP address, optionally
+// with a port.
+type BanRequest struct {
+	Addr string `json:"addr"`
+}
+
+type BanResponse struct {
+	IP     string `json:"ip"`
+	Kicked int    `json:"kicked"`
+}
+
+// New returns the API handler for rooms. Requests without
+// "Authorization: Bearer <token>" are rejected.
+func New(rooms *room.Manager, token string) http.Handler {
+	h := &handler{rooms: rooms}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /players", h.players)
+	mux.HandleFunc("POST /rooms/{room}/players/{id}/kick", h.kick)
+	mux.HandleFunc("GET /bans", h.bans)
+	mux.HandleFunc("POST /bans", h.ban)
+	mux.HandleFunc("GET /rooms", h.listRooms)
+	mux.HandleFunc("GET /tick", h.tick)
+
+	return authorize(token, mux)
+}
+
+func authorize(token string, next http.Handler) http.Handler {
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := []byte(r.Header.Get("Authorization"))
+		if token == "" || subtle.ConstantTimeCompare(got, expected) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, errors.New("missing or invalid token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type handler struct {
+	rooms *room.Manager
+}
+
+func (h *handler) players(w http.ResponseWriter, r *http.Request) {
+	players := []Player{}
+	for _, p := range h.rooms.Players() {
+		players = append(players, Player{
+			ID:         p.ID,
+			Name:       p.Name,
+			Room:       p.Room,
+			Addr:       p.Addr,
+			X:          p.X,
+			Y:          p.Y,
+			RTTMs:      milliseconds(p.RTT.Seconds()),
+			Loss:       p.Loss,
+			SendRate:   p.SendRate,
+			Violations: p.Violations,
+			LastSeen:   p.LastSeen,
+		})
+	}
+	writeJSON(w, http.StatusOK, players)
+}
+
+func (h *handler) kick(w http.ResponseWriter, r *http.Request) {
+	roomID, err := strconv.ParseUint(r.PathValue("room"), 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errors.New("invalid room id"))
+		return
+	}
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errors.New("invalid player id"))
+		return
+	}
+
+	if err := h.rooms.Kick(uint32(roomID), int32(id)); err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) bans(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.rooms.Bans())
+}
+
+func (h *handler) ban(w http.ResponseWriter, r *http.Request) {
+	var req BanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, errors.New("invalid request body"))
+		return
+	}
+
+	ip, err := room.ParseIP(req.Addr)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	kicked := h.rooms.Ban(ip)
+	writeJSON(w, http.StatusOK, BanResponse{IP: ip.String(), Kicked: kicked})
+}
+
+func (h *handler) listRooms(w http.ResponseWriter, r *http.Request) {
+	rooms := []Room{}
+	for _, info := range h.rooms.Rooms() {
+		rooms = append(rooms, Room{
+			ID:             info.ID,
+			Name:           info.Name,
+			Private:        info.Private,
+			Players:        info.Players,
+			Capacity:       info.Capacity,
+			Width:          info.Width,
+			Height:         info.Height,
+			TickRate:       info.TickRate,
+			TickDurationMs: milliseconds(info.TickDuration.Seconds()),
+		})
+	}
+	writeJSON(w, http.StatusOK, rooms)
+}
+
+func (h *handler) tick(w http.ResponseWriter, r *http.Request) {
+	tick := Tick{Rooms: []RoomTick{}}
+	for _, info := range h.rooms.Rooms() {
+		tick.Rooms = append(tick.Rooms, RoomTick{
+			ID:             info.ID,
+			TickRate:       info.TickRate,
+			TickDurationMs: milliseconds(info.TickDuration.Seconds()),
+			BudgetMs:       milliseconds(1 / float64(max(info.TickRate, 1))),
+			Tick:           info.Tick,
+			Overruns:       info.Overruns,
+		})
+	}
+	writeJSON(w, http.StatusOK, tick)
+}
+
+// milliseconds converts seconds to milliseconds rounded to microseconds.
+func milliseconds(seconds float64) float64 {
+	return float64(int64(seconds*1e6)) / 1e3
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,126 @@
+package admin
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zainokta/client-server-multiplayer/server/player"
+	"github.com/zainokta/client-server-multiplayer/server/room"
+	"github.com/zainokta/client-server-multiplayer/server/world"
+	"github.com/zainokta/client-server-multiplayer/shared/protocol"
+)
+
+type discardConn struct{}
+
+func (discardConn) WriteToUDP(b []byte, addr *net.UDPAddr) (int, error) {
+	return len(b), nil
+}
+
+func newAPI(t *testing.T) (*httptest.Server, *room.Manager) {
+	m := room.NewManager(discardConn{}, func(id uint32) room.Settings {
+		return room.Settings{Name: "arena", TickRate: 20, World: world.New(20, 10)}
+	})
+	r := m.Create()
+
+	addr := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 9001}
+	name, _ := protocol.ParseName("alice")
+	_, err := m.Join(addr, protocol.Hello{PlayerID: 7, Name: name})
+	assert.NoError(t, err)
+
+	data, err := player.SerializePlayer(player.Player{ID: 7, X: 3, Y: 4, Sequence: 1, Timestamp: time.Now().UnixMilli()})
+	assert.NoError(t, err)
+	m.Handle(addr, data)
+	r.State.Step(discardConn{})
+
+	srv := httptest.NewServer(New(m, "secret"))
+	t.Cleanup(srv.Close)
+	return srv, m
+}
+
+func request(t *testing.T, srv *httptest.Server, method, path, token, body string, out any) int {
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	assert.NoError(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		return 0
+	}
+	defer resp.Body.Close()
+
+	if out != nil {
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(out))
+	}
+	return resp.StatusCode
+}
+
+func TestRequiresToken(t *testing.T) {
+	srv, _ := newAPI(t)
+
+	assert.Equal(t, http.StatusUnauthorized, request(t, srv, "GET", "/players", "", "", nil))
+	assert.Equal(t, http.StatusUnauthorized, request(t, srv, "GET", "/players", "wrong", "", nil))
+	assert.Equal(t, http.StatusOK, request(t, srv, "GET", "/players", "secret", "", nil))
+
+	open := httptest.NewServer(New(nil, ""))
+	defer open.Close()
+	assert.Equal(t, http.StatusUnauthorized, request(t, open, "GET", "/players", "", "", nil), "an empty token never matches")
+}
+
+func TestPlayersAndRooms(t *testing.T) {
+	srv, _ := newAPI(t)
+
+	var players []Player
+	assert.Equal(t, http.StatusOK, request(t, srv, "GET", "/players", "secret", "", &players))
+	assert.Len(t, players, 1)
+	assert.Equal(t, "alice", players[0].Name)
+	assert.Equal(t, float32(3), players[0].X)
+	assert.Equal(t, "127.0.0.1:9001", players[0].Addr)
+
+	var rooms []Room
+	assert.Equal(t, http.StatusOK, request(t, srv, "GET", "/rooms", "secret", "", &rooms))
+	assert.Equal(t, []Room{{ID: 1, Name: "arena", Players: 1, Width: 20, Height: 10, TickRate: 20}}, rooms)
+
+	var tick Tick
+	assert.Equal(t, http.StatusOK, request(t, srv, "GET", "/tick", "secret", "", &tick))
+	assert.Equal(t, []RoomTick{{ID: 1, TickRate: 20, BudgetMs: 50, Tick: 1}}, tick.Rooms)
+}
+
+func TestKickAndBan(t *testing.T) {
+	srv, m := newAPI(t)
+
+	var failure map[string]string
+	assert.Equal(t, http.StatusNotFound, request(t, srv, "POST", "/rooms/1/players/8/kick", "secret", "", &failure))
+	assert.Equal(t, "no such player", failure["error"])
+	assert.Equal(t, http.StatusBadRequest, request(t, srv, "POST", "/rooms/1/players/x/kick", "secret", "", nil))
+	assert.Equal(t, http.StatusBadRequest, request(t, srv, "POST", "/rooms/x/players/7/kick", "secret", "", nil))
+	assert.Equal(t, http.StatusNotFound, request(t, srv, "POST", "/rooms/2/players/7/kick", "secret", "", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, request(t, srv, "GET", "/rooms/1/players/7/kick", "secret", "", nil))
+
+	var banned BanResponse
+	assert.Equal(t, http.StatusOK, request(t, srv, "POST", "/bans", "secret", `{"addr": "127.0.0.1:9001"}`, &banned))
+	assert.Equal(t, BanResponse{IP: "127.0.0.1", Kicked: 1}, banned)
+	assert.Empty(t, m.Players())
+
+	var bans []string
+	assert.Equal(t, http.StatusOK, request(t, srv, "GET", "/bans", "secret", "", &bans))
+	assert.Equal(t, []string{"127.0.0.1"}, bans)
+
+	assert.Equal(t, http.StatusBadRequest, request(t, srv, "POST", "/bans", "secret", `{"addr": "nowhere"}`, nil))
+	assert.Equal(t, http.StatusBadRequest, request(t, srv, "POST", "/bans", "secret", `not json`, nil))
+}
+
+func TestKick(t *testing.T) {
+	srv, m := newAPI(t)
+
+	assert.Equal(t, http.StatusNoContent, request(t, srv, "POST", "/rooms/1/players/7/kick", "secret", "", nil))
+	assert.Empty(t, m.Players())
+}
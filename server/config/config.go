@@ -1,6 +1,100 @@
 package config
 
+import (
+	"time"
+
+	"github.com/zainokta/client-server-multiplayer/shared/netsim"
+)
+
 type Config struct {
-	Port         int `env:"PORT" envDefault:"8000"`
+	Port int `env:"PORT" envDefault:"8000"`
+	// GameTickRate is how many times per second rooms simulate.
 	GameTickRate int `env:"GAME_TICK_RATE" envDefault:"30"`
+	// SnapshotRate is how many snapshots per second clients are sent,
+	// independently of the tick rate. Zero sends one every tick.
+	SnapshotRate int `env:"SNAPSHOT_RATE" envDefault:"20"`
+	// AdaptiveSendRate lowers the snapshot rate of clients with packet loss,
+	// or that would receive more than ClientBandwidth bytes per second, down
+	// to MinSendRate. A ClientBandwidth of zero is unlimited.
+	AdaptiveSendRate bool `env:"ADAPTIVE_SEND_RATE" envDefault:"false"`
+	MinSendRate      int  `env:"MIN_SEND_RATE" envDefault:"5"`
+	ClientBandwidth  int  `env:"CLIENT_BANDWIDTH" envDefault:"0"`
+	// LagCompensationWindow is how far back in time actions may be judged,
+	// making up for the latency of the acting client. Zero judges actions
+	// against the current positions.
+	LagCompensationWindow time.Duration `env:"LAG_COMPENSATION_WINDOW" envDefault:"200ms"`
+	// MaxSpeed is how many cells per second players may move, with bursts of
+	// up to MoveBurst cells. Zero disables the speed check. Moves that are
+	// too fast or out of bounds add a point to the violation score of the
+	// player, which drops by ViolationDecay points per second. Players are
+	// warned about at ViolationWarnScore and kicked at ViolationKickScore.
+	MaxSpeed           float64 `env:"MAX_SPEED" envDefault:"15"`
+	MoveBurst          float64 `env:"MOVE_BURST" envDefault:"3"`
+	ViolationWarnScore float64 `env:"VIOLATION_WARN_SCORE" envDefault:"5"`
+	ViolationKickScore float64 `env:"VIOLATION_KICK_SCORE" envDefault:"10"`
+	ViolationDecay     float64 `env:"VIOLATION_DECAY" envDefault:"0.5"`
+	WorldWidth         int     `env:"WORLD_WIDTH" envDefault:"20"`
+	WorldHeight        int     `env:"WORLD_HEIGHT" envDefault:"10"`
+	// MapPath points to a .txt or .json tile map. When empty an open world of
+	// WorldWidth x WorldHeight enclosed by a wall is used instead, which must be
+	// at least 3x3 and at most world.MaxWidth wide.
+	MapPath string `env:"MAP_PATH"`
+	// GameMode selects the rule set: ghost, classic, sumo or shuffle.
+	GameMode string `env:"GAME_MODE" envDefault:"classic"`
+	// CollisionRule overrides the collision rule of the game mode: none,
+	// block, push or swap.
+	CollisionRule string `env:"COLLISION_RULE"`
+	// InterestRadius is how many cells around a player are replicated to its
+	// client. Zero replicates the whole world.
+	InterestRadius int `env:"INTEREST_RADIUS" envDefault:"16"`
+	GridCellSize   int `env:"GRID_CELL_SIZE" envDefault:"8"`
+	// Rooms is how many rooms are open at start. More are opened on demand
+	// while every room is full, and for private rooms and matched parties, up
+	// to MaxRooms. A RoomCapacity or MaxRooms of zero means unlimited.
+	Rooms        int `env:"ROOMS" envDefault:"1"`
+	RoomCapacity int `env:"ROOM_CAPACITY" envDefault:"8"`
+	MaxRooms     int `env:"MAX_ROOMS" envDefault:"16"`
+	// RoomMaps lists the maps rooms cycle through by ID. When empty every
+	// room uses MapPath.
+	RoomMaps []string `env:"ROOM_MAPS" envSeparator:","`
+	// PartySize is how many players the matchmaking queue puts in a room
+	// when the client does not ask for a size.
+	PartySize int `env:"PARTY_SIZE" envDefault:"2"`
+	// Console reads admin commands from stdin.
+	Console bool `env:"CONSOLE" envDefault:"true"`
+	// AdminAddr enables the HTTP admin API on a loopback address such as
+	// 127.0.0.1:8081. It is disabled when empty.
+	AdminAddr string `env:"ADMIN_ADDR"`
+	// AdminToken is the bearer token the admin API requires. It must be set
+	// when the API is enabled.
+	AdminToken string `env:"ADMIN_TOKEN"`
+	// MetricsAddr enables Prometheus metrics on /metrics at an address such
+	// as :9100. It is disabled when empty.
+	MetricsAddr string `env:"METRICS_ADDR"`
+	// LogLevel is the lowest level logged: debug, info, warn or error. Every
+	// packet is logged at debug.
+	LogLevel string `env:"LOG_LEVEL" envDefault:"info"`
+	// LogFormat is text or json.
+	LogFormat string `env:"LOG_FORMAT" envDefault:"text"`
+	// The NetSim settings degrade every packet sent to clients to test bad
+	// networks locally. Loss, duplicate and reorder are shares from 0 to 1,
+	// and the same seed makes the same decisions.
+	NetSimLatency   time.Duration `env:"NETSIM_LATENCY" envDefault:"0"`
+	NetSimJitter    time.Duration `env:"NETSIM_JITTER" envDefault:"0"`
+	NetSimLoss      float64       `env:"NETSIM_LOSS" envDefault:"0"`
+	NetSimDuplicate float64       `env:"NETSIM_DUPLICATE" envDefault:"0"`
+	NetSimReorder   float64       `env:"NETSIM_REORDER" envDefault:"0"`
+	NetSimSeed      uint64        `env:"NETSIM_SEED" envDefault:"1"`
+}
+
+// NetworkConditions returns the simulated network conditions.
+func (c Config) NetworkConditions() netsim.Conditions {
+	return netsim.Conditions{
+		Latency:   c.NetSimLatency,
+		Jitter:    c.NetSimJitter,
+		Loss:      c.NetSimLoss,
+		Duplicate: c.NetSimDuplicate,
+		Reorder:   c.NetSimReorder,
+		Seed:      c.NetSimSeed,
+	}
 }
@@ -0,0 +1,254 @@
+// Package console is an interactive admin console. It reads commands line by
+// line, typically from the terminal of the server process, and runs them
+// against the rooms of the running server.
+package console
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/zainokta/client-server-multiplayer/server/room"
+)
+
+var ErrUnknownCommand = errors.New("unknown command, type help for the list of commands")
+
+const help = `Commands:
+  list                 list connected players
+  rooms                list rooms
+  kick <room> <id>     remove a player from its room
+  ban <addr>           kick every player from an IP address and drop its packets
+  tp <room> <id> <x> <y>
+                       teleport a player
+  say <message>        send a message to every player
+  tickrate <n>         change the tick rate of every room
+  stats                show server statistics
+  help                 show this help
+`
+
+type Console struct {
+	rooms *room.Manager
+	in    io.Reader
+	out   io.Writer
+}
+
+func New(rooms *room.Manager, in io.Reader, out io.Writer) *Console {
+	return &Console{rooms: rooms, in: in, out: out}
+}
+
+// Run executes commands until the input ends or ctx is cancelled. Errors are
+// printed and do not stop the console.
+func (c *Console) Run(ctx context.Context) {
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(c.in)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case line, ok := <-lines:
+			if !ok {
+				return
+			}
+			if err := c.Execute(line); err != nil {
+				fmt.Fprintf(c.out, "Error: %v\n", err)
+			}
+		}
+	}
+}
+
+// Execute runs a single command line.
+func (c *Console) Execute(line string) error {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	command, args := strings.ToLower(fields[0]), fields[1:]
+	switch command {
+	case "help":
+		fmt.Fprint(c.out, help)
+		return nil
+	case "list":
+		return c.list()
+	case "rooms":
+		return c.listRooms()
+	case "kick":
+		return c.kick(args)
+	case "ban":
+		return c.ban(args)
+	case "tp":
+		return c.teleport(args)
+	case "say":
+		_, message, _ := strings.Cut(strings.TrimSpace(line), " ")
+		return c.say(strings.TrimSpace(message))
+	case "tickrate":
+		return c.tickRate(args)
+	case "stats":
+		return c.stats()
+	}
+	return ErrUnknownCommand
+}
+
+func (c *Console) list() error {
+	players := c.rooms.Players()
+	if len(players) == 0 {
+		fmt.Fprintln(c.out, "No players")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tROOM\tADDRESS\tPOSITION\tLAST SEEN")
+	for _, p := range players {
+		fmt.Fprintf(w, "%d\t%s\t%d\t%s\t(%.0f, %.0f)\t%s ago\n",
+			p.ID, p.Name, p.Room, p.Addr, p.X, p.Y, p.Silent.Round(time.Second))
+	}
+	return w.Flush()
+}
+
+func (c *Console) listRooms() error {
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tPRIVATE\tPLAYERS\tCAPACITY\tSIZE\tTICK RATE")
+	for _, r := range c.rooms.Rooms() {
+		fmt.Fprintf(w, "%d\t%s\t%t\t%d\t%d\t%dx%d\t%d\n",
+			r.ID, r.Name, r.Private, r.Players, r.Capacity, r.Width, r.Height, r.TickRate)
+	}
+	return w.Flush()
+}
+
+func (c *Console) kick(args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: kick <room> <id>")
+	}
+	roomID, err := parseRoom(args[0])
+	if err != nil {
+		return err
+	}
+	id, err := parseID(args[1])
+	if err != nil {
+		return err
+	}
+
+	if err := c.rooms.Kick(roomID, id); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "Kicked player %d from room %d\n", id, roomID)
+	return nil
+}
+
+func (c *Console) ban(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: ban <addr>")
+	}
+
+	ip, err := room.ParseIP(args[0])
+	if err != nil {
+		return err
+	}
+
+	kicked := c.rooms.Ban(ip)
+	fmt.Fprintf(c.out, "Banned %s, kicked %d player(s)\n", ip, kicked)
+	return nil
+}
+
+func (c *Console) teleport(args []string) error {
+	if len(args) != 4 {
+		return errors.New("usage: tp <room> <id> <x> <y>")
+	}
+	roomID, err := parseRoom(args[0])
+	if err != nil {
+		return err
+	}
+	id, err := parseID(args[1])
+	if err != nil {
+		return err
+	}
+	x, err := strconv.ParseFloat(args[2], 32)
+	if err != nil {
+		return fmt.Errorf("invalid x %q", args[2])
+	}
+	y, err := strconv.ParseFloat(args[3], 32)
+	if err != nil {
+		return fmt.Errorf("invalid y %q", args[3])
+	}
+
+	if err := c.rooms.Teleport(roomID, id, float32(x), float32(y)); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "Teleporting player %d of room %d to (%.0f, %.0f)\n", id, roomID, x, y)
+	return nil
+}
+
+func (c *Console) say(message string) error {
+	if message == "" {
+		return errors.New("usage: say <message>")
+	}
+	return c.rooms.Announce(message)
+}
+
+func (c *Console) tickRate(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: tickrate <n>")
+	}
+	rate, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid tick rate %q", args[0])
+	}
+
+	if err := c.rooms.SetTickRate(rate); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "Tick rate set to %d\n", rate)
+	return nil
+}
+
+func (c *Console) stats() error {
+	stats := c.rooms.Stats()
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Uptime\t%s\n", stats.Uptime.Round(time.Second))
+	fmt.Fprintf(w, "Rooms\t%d\n", stats.Rooms)
+	fmt.Fprintf(w, "Players\t%d\n", stats.Players)
+	fmt.Fprintf(w, "Queued\t%d\n", stats.Queued)
+	fmt.Fprintf(w, "Banned\t%d\n", stats.Banned)
+	fmt.Fprintf(w, "Unacknowledged\t%d\n", stats.PendingReliable)
+	fmt.Fprintf(w, "Goroutines\t%d\n", runtime.NumGoroutine())
+	fmt.Fprintf(w, "Heap\t%.1f MiB\n", float64(mem.HeapAlloc)/(1<<20))
+	return w.Flush()
+}
+
+func parseID(s string) (int32, error) {
+	id, err := strconv.ParseInt(s, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid player id %q", s)
+	}
+	return int32(id), nil
+}
+
+func parseRoom(s string) (uint32, error) {
+	id, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid room id %q", s)
+	}
+	return uint32(id), nil
+}
@@ -0,0 +1,100 @@
+package console
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zainokta/client-server-multiplayer/server/player"
+	"github.com/zainokta/client-server-multiplayer/server/room"
+	"github.com/zainokta/client-server-multiplayer/server/world"
+	"github.com/zainokta/client-server-multiplayer/shared/protocol"
+)
+
+type discardConn struct{}
+
+func (discardConn) WriteToUDP(b []byte, addr *net.UDPAddr) (int, error) {
+	return len(b), nil
+}
+
+func newConsole(t *testing.T) (*Console, *room.Manager, *bytes.Buffer) {
+	m := room.NewManager(discardConn{}, func(id uint32) room.Settings {
+		return room.Settings{Name: "arena", TickRate: 10, World: world.New(20, 10)}
+	})
+	r := m.Create()
+
+	addr := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 9001}
+	name, _ := protocol.ParseName("alice")
+	_, err := m.Join(addr, protocol.Hello{PlayerID: 7, Name: name})
+	assert.NoError(t, err)
+
+	data, err := player.SerializePlayer(player.Player{ID: 7, X: 3, Y: 4, Sequence: 1, Timestamp: time.Now().UnixMilli()})
+	assert.NoError(t, err)
+	m.Handle(addr, data)
+	r.State.Step(discardConn{})
+
+	var out bytes.Buffer
+	return New(m, nil, &out), m, &out
+}
+
+func TestExecute(t *testing.T) {
+	c, m, out := newConsole(t)
+
+	assert.NoError(t, c.Execute("list"))
+	assert.Contains(t, out.String(), "alice")
+	assert.Contains(t, out.String(), "(3, 4)")
+
+	out.Reset()
+	assert.NoError(t, c.Execute("  rooms "))
+	assert.Contains(t, out.String(), "arena")
+
+	assert.NoError(t, c.Execute("tp 1 7 5 6"))
+	r, _ := m.Get(1)
+	r.State.Step(discardConn{})
+	assert.Equal(t, float32(5), m.Players()[0].X)
+
+	assert.NoError(t, c.Execute("tickrate 20"))
+	assert.Equal(t, 20, m.Rooms()[0].TickRate)
+
+	assert.NoError(t, c.Execute("say   hello everyone"))
+
+	out.Reset()
+	assert.NoError(t, c.Execute("stats"))
+	assert.Contains(t, out.String(), "Players         1")
+
+	assert.NoError(t, c.Execute("kick 1 7"))
+	assert.Empty(t, m.Players())
+
+	assert.NoError(t, c.Execute(""))
+}
+
+func TestExecuteErrors(t *testing.T) {
+	c, _, _ := newConsole(t)
+
+	assert.ErrorIs(t, c.Execute("fly"), ErrUnknownCommand)
+	assert.ErrorIs(t, c.Execute("kick 1 8"), room.ErrNoSuchPlayer)
+	assert.ErrorIs(t, c.Execute("kick 2 7"), room.ErrNoSuchPlayer)
+	assert.ErrorContains(t, c.Execute("kick"), "usage")
+	assert.ErrorContains(t, c.Execute("kick 1 seven"), "invalid player id")
+	assert.ErrorContains(t, c.Execute("kick one 7"), "invalid room id")
+	assert.ErrorContains(t, c.Execute("tp 1 7 x 1"), "invalid x")
+	assert.ErrorIs(t, c.Execute("tickrate 0"), room.ErrTickRate)
+	assert.ErrorContains(t, c.Execute("ban nowhere"), "invalid address")
+	assert.ErrorContains(t, c.Execute("say"), "usage")
+}
+
+func TestBanAndRun(t *testing.T) {
+	_, m, _ := newConsole(t)
+
+	var out bytes.Buffer
+	c := New(m, strings.NewReader("ban 127.0.0.1:9001\nfly\n"), &out)
+	c.Run(context.Background())
+
+	assert.Empty(t, m.Players())
+	assert.Contains(t, out.String(), "Banned 127.0.0.1, kicked 1 player(s)")
+	assert.Contains(t, out.String(), "Error: unknown command")
+}
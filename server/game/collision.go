@@ -0,0 +1,361 @@
+package game
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zainokta/client-server-multiplayer/server/player"
+	"github.com/zainokta/client-server-multiplayer/shared/protocol"
+)
+
+// CollisionRule decides what happens when a player moves into a cell that
+// another player occupies.
+type CollisionRule int
+
+const (
+	// CollisionNone lets players share cells.
+	CollisionNone CollisionRule = iota
+	// CollisionBlock rejects the move.
+	CollisionBlock
+	// CollisionPush moves the occupant one cell further in the same
+	// direction, or rejects the move if that cell is not free.
+	CollisionPush
+	// CollisionSwap moves the occupant into the mover's previous cell.
+	CollisionSwap
+)
+
+var collisionRuleNames = map[string]CollisionRule{
+	"none":  CollisionNone,
+	"block": CollisionBlock,
+	"push":  CollisionPush,
+	"swap":  CollisionSwap,
+}
+
+func ParseCollisionRule(name string) (CollisionRule, error) {
+	rule, ok := collisionRuleNames[strings.ToLower(name)]
+	if !ok {
+		return CollisionNone, fmt.Errorf("unknown collision rule %q", name)
+	}
+	return rule, nil
+}
+
+// Mode is a named set of game rules.
+type Mode struct {
+	Name      string
+	Collision CollisionRule
+}
+
+var modes = map[string]Mode{
+	"ghost":   {Name: "ghost", Collision: CollisionNone},
+	"classic": {Name: "classic", Collision: CollisionBlock},
+	"sumo":    {Name: "sumo", Collision: CollisionPush},
+	"shuffle": {Name: "shuffle", Collision: CollisionSwap},
+}
+
+func LookupMode(name string) (Mode, error) {
+	mode, ok := modes[strings.ToLower(name)]
+	if !ok {
+		return Mode{}, fmt.Errorf("unknown game mode %q", name)
+	}
+	return mode, nil
+}
+
+type cell struct {
+	x, y int
+}
+
+func cellOf(x, y float32) cell {
+	return cell{x: int(math.Floor(float64(x))), y: int(math.Floor(float64(y)))}
+}
+
+type queuedMove struct {
+	player player.Player
+	addr   *net.UDPAddr
+}
+
+type teleport struct {
+	id   int32
+	x, y float32
+}
+
+// queueMove keeps the newest move of each player until the next Step.
+func (g *GameState) queueMove(gamePlayer player.Player, addr *net.UDPAddr) {
+	g.movesMu.Lock()
+	defer g.movesMu.Unlock()
+
+	if g.moves == nil {
+		g.moves = make(map[int32]queuedMove)
+	}
+	g.moves[gamePlayer.ID] = queuedMove{player: gamePlayer, addr: addr}
+}
+
+// drainMoves returns the queued moves ordered by player ID, which makes the
+// outcome of simultaneous moves independent of packet arrival order.
+func (g *GameState) drainMoves() []queuedMove {
+	g.movesMu.Lock()
+	moves := make([]queuedMove, 0, len(g.moves))
+	for _, m := range g.moves {
+		moves = append(moves, m)
+	}
+	clear(g.moves)
+	g.movesMu.Unlock()
+
+	sort.Slice(moves, func(i, j int) bool {
+		return moves[i].player.ID < moves[j].player.ID
+	})
+	return moves
+}
+
+func (g *GameState) drainTeleports() []teleport {
+	g.movesMu.Lock()
+	defer g.movesMu.Unlock()
+
+	teleports := g.teleports
+	g.teleports = nil
+	return teleports
+}
+
+// Step applies every queued move. Moves are resolved one after the other in
+// player ID order against the occupancy left by the moves before them, so
+// when two players move into the same free cell in one tick the lower ID
+// gets it. Teleports are applied last and override the moves. Every Step
+// advances the tick counter. Actions are judged first, against the positions
+// of earlier ticks, and the positions after the Step are kept for the
+// actions of later ticks. Moves that are too fast or out of bounds count
+// against the movement rules, and players breaking them too often are
+// kicked once the Step is done.
+func (g *GameState) Step(conn UDPConn) {
+	g.tick.Add(1)
+
+	for _, a := range g.drainActions() {
+		g.judge(conn, a)
+	}
+	now := g.clock.Now()
+	for _, addr := range g.applyMoves(conn, now) {
+		if g.kick != nil {
+			g.kick(addr)
+		}
+	}
+	g.record(now)
+}
+
+// applyMoves returns the addresses of the clients to kick.
+func (g *GameState) applyMoves(conn UDPConn, now time.Time) []*net.UDPAddr {
+	moves := g.drainMoves()
+	teleports := g.drainTeleports()
+	if len(moves) == 0 && len(teleports) == 0 {
+		return nil
+	}
+
+	occupancy := make(map[cell]int32)
+	g.Players.Range(func(_, value any) bool {
+		p := value.(player.Player)
+		occupancy[cellOf(p.X, p.Y)] = p.ID
+		return true
+	})
+
+	var kicks []*net.UDPAddr
+	moved := make(map[int32]bool)
+	for _, m := range moves {
+		reason := g.resolveMove(conn, m, occupancy, moved, now)
+		if reason == 0 {
+			continue
+		}
+
+		g.reject(conn, m.addr, m.player, reason)
+		if (reason == protocol.ReasonOutOfBounds || reason == protocol.ReasonTooFast) && g.violate(m.addr, m.player, reason, now) {
+			kicks = append(kicks, m.addr)
+		}
+	}
+
+	for _, t := range teleports {
+		g.relocate(conn, t.id, t.x, t.y, occupancy, moved, protocol.ReasonTeleported)
+	}
+	return kicks
+}
+
+func (g *GameState) resolveMove(conn UDPConn, m queuedMove, occupancy map[cell]int32, moved map[int32]bool, now time.Time) protocol.CorrectionReason {
+	gamePlayer := m.player
+
+	if g.world != nil && !g.world.Contains(gamePlayer.X, gamePlayer.Y) {
+		if gamePlayer.X < 0 || gamePlayer.Y < 0 || gamePlayer.X >= float32(g.world.Width) || gamePlayer.Y >= float32(g.world.Height) {
+			return protocol.ReasonOutOfBounds
+		}
+		return protocol.ReasonWall
+	}
+
+	target := cellOf(gamePlayer.X, gamePlayer.Y)
+
+	// The first move starts from the spawn of the player. Without a world
+	// there is none and the first move goes anywhere.
+	var origin player.Player
+	last, known := g.Players.Load(gamePlayer.ID)
+	hasOrigin := known
+	if known {
+		origin = last.(player.Player)
+	} else if g.world != nil {
+		origin.X, origin.Y = g.spawnOf(gamePlayer.ID)
+		hasOrigin = true
+	}
+	if hasOrigin && g.tooFast(m.addr, origin, gamePlayer, now) {
+		return protocol.ReasonTooFast
+	}
+	from := cellOf(origin.X, origin.Y)
+
+	occupantID, occupied := occupancy[target]
+	if occupied && occupantID != gamePlayer.ID && g.mode.Collision != CollisionNone {
+		if reason := g.collide(conn, gamePlayer, origin, hasOrigin, occupantID, target, occupancy, moved); reason != 0 {
+			if !known && g.world != nil {
+				g.respawnIfTaken(gamePlayer.ID, occupancy)
+			}
+			return reason
+		}
+	}
+
+	if known && occupancy[from] == gamePlayer.ID {
+		delete(occupancy, from)
+	}
+	if hasOrigin {
+		g.spend(m.addr, origin, gamePlayer, now)
+	}
+
+	occupancy[target] = gamePlayer.ID
+	moved[gamePlayer.ID] = true
+	g.spawns.Delete(gamePlayer.ID)
+	g.Players.Store(gamePlayer.ID, gamePlayer)
+
+	g.log.Debug("Player moved", "player", gamePlayer.ID, "addr", m.addr.String(), "x", gamePlayer.X, "y", gamePlayer.Y)
+	return 0
+}
+
+// collide applies the collision rule to a move into the cell of another
+// player, coming from origin if the mover has one. It returns why the move is
+// rejected, or zero once the occupant made way.
+func (g *GameState) collide(conn UDPConn, gamePlayer, origin player.Player, hasOrigin bool, occupantID int32, target cell, occupancy map[cell]int32, moved map[int32]bool) protocol.CorrectionReason {
+	if moved[occupantID] {
+		return protocol.ReasonContested
+	}
+
+	from := cellOf(origin.X, origin.Y)
+	switch g.mode.Collision {
+	case CollisionBlock:
+		return protocol.ReasonOccupied
+	case CollisionPush:
+		if !hasOrigin || !g.push(conn, occupantID, from, target, occupancy, moved) {
+			return protocol.ReasonBlocked
+		}
+	case CollisionSwap:
+		// A newcomer can only swap into its spawn while nobody stands there.
+		if other, taken := occupancy[from]; !hasOrigin || taken && other != gamePlayer.ID {
+			return protocol.ReasonOccupied
+		}
+		g.relocate(conn, occupantID, origin.X, origin.Y, occupancy, moved, protocol.ReasonSwapped)
+	}
+	return 0
+}
+
+// spawnOf returns where the player starts: the spawn sent in Welcome, or the
+// free cell it was moved to because another player stood there.
+func (g *GameState) spawnOf(id int32) (float32, float32) {
+	if value, exists := g.spawns.Load(id); exists {
+		c := value.(cell)
+		return float32(c.x), float32(c.y)
+	}
+	return g.world.Spawn(id)
+}
+
+// respawnIfTaken moves the spawn of a player that has not entered yet to the
+// nearest free cell when another player stands on it, as spawns are shared.
+// The correction of the rejected move then sends the client there.
+func (g *GameState) respawnIfTaken(id int32, occupancy map[cell]int32) {
+	x, y := g.spawnOf(id)
+	start := cellOf(x, y)
+	if _, taken := occupancy[start]; !taken {
+		return
+	}
+
+	pending := make(map[cell]bool)
+	g.spawns.Range(func(_, value any) bool {
+		pending[value.(cell)] = true
+		return true
+	})
+
+	seen := map[cell]bool{start: true}
+	queue := []cell{start}
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+		if _, taken := occupancy[c]; !taken && !pending[c] {
+			g.spawns.Store(id, c)
+			return
+		}
+
+		for _, next := range []cell{{c.x + 1, c.y}, {c.x - 1, c.y}, {c.x, c.y + 1}, {c.x, c.y - 1}} {
+			if !seen[next] && g.world.Walkable(next.x, next.y) {
+				seen[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+}
+
+// push moves the occupant of target one cell further away from the mover.
+func (g *GameState) push(conn UDPConn, occupantID int32, from, target cell, occupancy map[cell]int32, moved map[int32]bool) bool {
+	dx, dy := sign(target.x-from.x), sign(target.y-from.y)
+	if dx != 0 && dy != 0 {
+		return false
+	}
+
+	dest := cell{x: target.x + dx, y: target.y + dy}
+	if _, taken := occupancy[dest]; taken {
+		return false
+	}
+	if g.world != nil && !g.world.Walkable(dest.x, dest.y) {
+		return false
+	}
+
+	g.relocate(conn, occupantID, float32(dest.x), float32(dest.y), occupancy, moved, protocol.ReasonPushed)
+	return true
+}
+
+// relocate moves another player as a side effect of a collision and tells its
+// client where it ended up.
+func (g *GameState) relocate(conn UDPConn, id int32, x, y float32, occupancy map[cell]int32, moved map[int32]bool, reason protocol.CorrectionReason) {
+	value, exists := g.Players.Load(id)
+	if !exists {
+		return
+	}
+
+	occupant := value.(player.Player)
+	occupant.X, occupant.Y = x, y
+	g.Players.Store(id, occupant)
+	occupancy[cellOf(x, y)] = id
+	moved[id] = true
+
+	addr, exists := g.Clients.Load(id)
+	if !exists {
+		return
+	}
+
+	g.send(conn, addr.(*net.UDPAddr), protocol.MsgCorrection, protocol.Correction{
+		PlayerID: id,
+		X:        x,
+		Y:        y,
+		Sequence: occupant.Sequence,
+		Reason:   reason,
+	})
+}
+
+func sign(v int) int {
+	switch {
+	case v < 0:
+		return -1
+	case v > 0:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,213 @@
+package game
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zainokta/client-server-multiplayer/server/player"
+	"github.com/zainokta/client-server-multiplayer/server/world"
+	"github.com/zainokta/client-server-multiplayer/shared/protocol"
+)
+
+func newCollisionState(rule CollisionRule) *GameState {
+	return New(WithWorld(world.New(10, 10)), WithMode(Mode{Collision: rule}))
+}
+
+func addrFor(id int32) *net.UDPAddr {
+	return &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 9000 + int(id)}
+}
+
+// place puts a player on the board as if it had already been simulated.
+func place(gs *GameState, id int32, x, y float32) {
+	gs.Players.Store(id, player.Player{ID: id, X: x, Y: y, Sequence: 1})
+	gs.Clients.Store(id, addrFor(id))
+	gs.SequenceNumbers.Store(id, uint32(1))
+}
+
+func move(gs *GameState, conn UDPConn, id int32, x, y float32, seq uint32) {
+	data, _ := player.SerializePlayer(player.Player{ID: id, X: x, Y: y, Sequence: seq})
+	gs.HandleClient(conn, addrFor(id), data)
+}
+
+func position(gs *GameState, id int32) (float32, float32) {
+	value, _ := gs.Players.Load(id)
+	p := value.(player.Player)
+	return p.X, p.Y
+}
+
+func corrections(t *testing.T, conn *mockUDPConn) []protocol.Correction {
+	var result []protocol.Correction
+	for _, data := range conn.written {
+		var c protocol.Correction
+		if protocol.Decode(data, protocol.MsgCorrection, &c) == nil {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+func TestCollisionBlock(t *testing.T) {
+	gs := newCollisionState(CollisionBlock)
+	conn := &mockUDPConn{}
+	place(gs, 1, 2, 2)
+	place(gs, 2, 3, 2)
+
+	move(gs, conn, 1, 3, 2, 2)
+	gs.Step(conn)
+
+	x, _ := position(gs, 1)
+	assert.Equal(t, float32(2), x)
+	assert.Equal(t, []protocol.Correction{{PlayerID: 1, X: 2, Y: 2, Sequence: 2, Reason: protocol.ReasonOccupied}}, corrections(t, conn))
+}
+
+func TestCollisionSimultaneousMovesAreDeterministic(t *testing.T) {
+	for _, order := range [][]int32{{1, 2}, {2, 1}} {
+		gs := newCollisionState(CollisionBlock)
+		conn := &mockUDPConn{}
+		place(gs, 1, 2, 2)
+		place(gs, 2, 4, 2)
+
+		for _, id := range order {
+			move(gs, conn, id, 3, 2, 2)
+		}
+		gs.Step(conn)
+
+		x1, _ := position(gs, 1)
+		x2, _ := position(gs, 2)
+		assert.Equal(t, float32(3), x1, "lower ID wins the contested cell")
+		assert.Equal(t, float32(4), x2)
+
+		got := corrections(t, conn)
+		assert.Len(t, got, 1)
+		assert.Equal(t, int32(2), got[0].PlayerID)
+		assert.Equal(t, protocol.ReasonContested, got[0].Reason)
+	}
+}
+
+func TestCollisionFollowIntoVacatedCell(t *testing.T) {
+	gs := newCollisionState(CollisionBlock)
+	conn := &mockUDPConn{}
+	place(gs, 1, 3, 2)
+	place(gs, 2, 2, 2)
+
+	move(gs, conn, 1, 4, 2, 2)
+	move(gs, conn, 2, 3, 2, 2)
+	gs.Step(conn)
+
+	x1, _ := position(gs, 1)
+	x2, _ := position(gs, 2)
+	assert.Equal(t, float32(4), x1)
+	assert.Equal(t, float32(3), x2)
+	assert.Empty(t, corrections(t, conn))
+}
+
+func TestCollisionPush(t *testing.T) {
+	gs := newCollisionState(CollisionPush)
+	conn := &mockUDPConn{}
+	place(gs, 1, 2, 2)
+	place(gs, 2, 3, 2)
+
+	move(gs, conn, 1, 3, 2, 2)
+	gs.Step(conn)
+
+	x1, _ := position(gs, 1)
+	x2, _ := position(gs, 2)
+	assert.Equal(t, float32(3), x1)
+	assert.Equal(t, float32(4), x2)
+	assert.Equal(t, []protocol.Correction{{PlayerID: 2, X: 4, Y: 2, Sequence: 1, Reason: protocol.ReasonPushed}}, corrections(t, conn))
+}
+
+func TestCollisionPushAgainstWall(t *testing.T) {
+	gs := newCollisionState(CollisionPush)
+	conn := &mockUDPConn{}
+	place(gs, 1, 7, 2)
+	place(gs, 2, 8, 2)
+
+	move(gs, conn, 1, 8, 2, 2)
+	gs.Step(conn)
+
+	x1, _ := position(gs, 1)
+	x2, _ := position(gs, 2)
+	assert.Equal(t, float32(7), x1)
+	assert.Equal(t, float32(8), x2)
+
+	got := corrections(t, conn)
+	assert.Len(t, got, 1)
+	assert.Equal(t, protocol.ReasonBlocked, got[0].Reason)
+}
+
+func TestCollisionSwap(t *testing.T) {
+	gs := newCollisionState(CollisionSwap)
+	conn := &mockUDPConn{}
+	place(gs, 1, 2, 2)
+	place(gs, 2, 2, 3)
+
+	move(gs, conn, 1, 2, 3, 2)
+	gs.Step(conn)
+
+	_, y1 := position(gs, 1)
+	_, y2 := position(gs, 2)
+	assert.Equal(t, float32(3), y1)
+	assert.Equal(t, float32(2), y2)
+
+	got := corrections(t, conn)
+	assert.Len(t, got, 1)
+	assert.Equal(t, protocol.ReasonSwapped, got[0].Reason)
+}
+
+func TestCollisionAppliesToFirstMoves(t *testing.T) {
+	gs := newCollisionState(CollisionBlock)
+	conn := &mockUDPConn{}
+	place(gs, 1, 5, 5)
+
+	// Every player spawns at 5,5, where player 1 stands.
+	move(gs, conn, 2, 5, 5, 1)
+	move(gs, conn, 3, 5, 5, 1)
+	gs.Step(conn)
+
+	_, exists := gs.Players.Load(int32(2))
+	assert.False(t, exists, "players cannot enter on top of another")
+	assert.Equal(t, []protocol.Correction{
+		{PlayerID: 2, X: 6, Y: 5, Sequence: 1, Reason: protocol.ReasonOccupied},
+		{PlayerID: 3, X: 4, Y: 5, Sequence: 1, Reason: protocol.ReasonOccupied},
+	}, corrections(t, conn), "newcomers are sent to the nearest free cells")
+
+	move(gs, conn, 2, 6, 5, 2)
+	move(gs, conn, 3, 4, 5, 2)
+	gs.Step(conn)
+	x2, _ := position(gs, 2)
+	x3, _ := position(gs, 3)
+	assert.Equal(t, float32(6), x2)
+	assert.Equal(t, float32(4), x3)
+}
+
+func TestCollisionNone(t *testing.T) {
+	gs := newCollisionState(CollisionNone)
+	conn := &mockUDPConn{}
+	place(gs, 1, 2, 2)
+	place(gs, 2, 3, 2)
+
+	move(gs, conn, 1, 3, 2, 2)
+	gs.Step(conn)
+
+	x, _ := position(gs, 1)
+	assert.Equal(t, float32(3), x)
+	assert.Empty(t, corrections(t, conn))
+}
+
+func TestLookupMode(t *testing.T) {
+	mode, err := LookupMode("Sumo")
+	assert.NoError(t, err)
+	assert.Equal(t, CollisionPush, mode.Collision)
+
+	_, err = LookupMode("unknown")
+	assert.Error(t, err)
+
+	rule, err := ParseCollisionRule("swap")
+	assert.NoError(t, err)
+	assert.Equal(t, CollisionSwap, rule)
+
+	_, err = ParseCollisionRule("bounce")
+	assert.Error(t, err)
+}
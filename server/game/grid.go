@@ -0,0 +1,88 @@
+package game
+
+// Grid is a uniform spatial hash that buckets entities by the cell of size
+// CellSize they stand in, so range queries only look at nearby buckets.
+type Grid struct {
+	cellSize  int
+	buckets   map[cell]map[int32]struct{}
+	positions map[int32]cell
+}
+
+func NewGrid(cellSize int) *Grid {
+	return &Grid{
+		cellSize:  max(cellSize, 1),
+		buckets:   make(map[cell]map[int32]struct{}),
+		positions: make(map[int32]cell),
+	}
+}
+
+func (g *Grid) bucketOf(x, y float32) cell {
+	c := cellOf(x, y)
+	return cell{x: floorDiv(c.x, g.cellSize), y: floorDiv(c.y, g.cellSize)}
+}
+
+// Update moves id into the bucket for (x, y).
+func (g *Grid) Update(id int32, x, y float32) {
+	bucket := g.bucketOf(x, y)
+	if current, exists := g.positions[id]; exists {
+		if current == bucket {
+			return
+		}
+		g.removeFromBucket(id, current)
+	}
+
+	if g.buckets[bucket] == nil {
+		g.buckets[bucket] = make(map[int32]struct{})
+	}
+	g.buckets[bucket][id] = struct{}{}
+	g.positions[id] = bucket
+}
+
+func (g *Grid) Remove(id int32) {
+	if current, exists := g.positions[id]; exists {
+		g.removeFromBucket(id, current)
+		delete(g.positions, id)
+	}
+}
+
+func (g *Grid) removeFromBucket(id int32, bucket cell) {
+	delete(g.buckets[bucket], id)
+	if len(g.buckets[bucket]) == 0 {
+		delete(g.buckets, bucket)
+	}
+}
+
+// IDs returns every tracked entity.
+func (g *Grid) IDs() []int32 {
+	ids := make([]int32, 0, len(g.positions))
+	for id := range g.positions {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Query returns the entities in every bucket overlapping the square of
+// radius cells around (x, y). Callers filter by exact distance if needed.
+func (g *Grid) Query(x, y float32, radius int) []int32 {
+	center := cellOf(x, y)
+	minBucket := cell{x: floorDiv(center.x-radius, g.cellSize), y: floorDiv(center.y-radius, g.cellSize)}
+	maxBucket := cell{x: floorDiv(center.x+radius, g.cellSize), y: floorDiv(center.y+radius, g.cellSize)}
+
+	var ids []int32
+	for by := minBucket.y; by <= maxBucket.y; by++ {
+		for bx := minBucket.x; bx <= maxBucket.x; bx++ {
+			for id := range g.buckets[cell{x: bx, y: by}] {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+func floorDiv(a, b int) int {
+	q := a / b
+	if (a%b != 0) && ((a < 0) != (b < 0)) {
+		q--
+	}
+	return q
+}
@@ -0,0 +1,119 @@
+package game
+
+import (
+	"net"
+
+	"github.com/zainokta/client-server-multiplayer/server/player"
+	"github.com/zainokta/client-server-multiplayer/shared/protocol"
+)
+
+const DefaultGridCellSize = 8
+
+// WithInterest limits replication to players within radius cells of each
+// client, tracked in a spatial grid with buckets of cellSize cells. A radius
+// of zero replicates every player to every client.
+func WithInterest(radius, cellSize int) Option {
+	return func(g *GameState) {
+		g.interestRadius = radius
+		g.grid = NewGrid(cellSize)
+	}
+}
+
+type view map[int32]struct{}
+
+// visibleTo returns the players the observer should receive this tick. The
+// observer always sees itself.
+func (g *GameState) visibleTo(observer int32, snapshot map[int32]player.Player) view {
+	visible := make(view)
+
+	if g.interestRadius <= 0 {
+		for id := range snapshot {
+			visible[id] = struct{}{}
+		}
+		return visible
+	}
+
+	self, exists := snapshot[observer]
+	if !exists {
+		return visible
+	}
+
+	origin := cellOf(self.X, self.Y)
+	for _, id := range g.grid.Query(self.X, self.Y, g.interestRadius) {
+		p := snapshot[id]
+		c := cellOf(p.X, p.Y)
+		if abs(c.x-origin.x) <= g.interestRadius && abs(c.y-origin.y) <= g.interestRadius {
+			visible[id] = struct{}{}
+		}
+	}
+	visible[observer] = struct{}{}
+
+	return visible
+}
+
+// syncGrid brings the grid in line with the current players.
+func (g *GameState) syncGrid(snapshot map[int32]player.Player) {
+	for id, p := range snapshot {
+		g.grid.Update(id, p.X, p.Y)
+	}
+	for _, id := range g.grid.IDs() {
+		if _, exists := snapshot[id]; !exists {
+			g.grid.Remove(id)
+		}
+	}
+}
+
+// replicate sends the observer enter events for players that came into view,
+// updates for players that stayed in view and leave events for players that
+// went out of view. It returns the bytes written and whether every write
+// succeeded.
+func (g *GameState) replicate(conn UDPConn, addr *net.UDPAddr, previous, visible view, snapshot map[int32]player.Player) (int, bool) {
+	total := 0
+	for id := range previous {
+		if _, stillVisible := visible[id]; stillVisible {
+			continue
+		}
+		n, ok := g.write(conn, addr, protocol.MsgEntityLeave, protocol.EntityLeave{PlayerID: id})
+		if !ok {
+			return total, false
+		}
+		total += n
+	}
+
+	for id := range visible {
+		msgType := protocol.MsgPlayerUpdate
+		if _, seen := previous[id]; !seen {
+			msgType = protocol.MsgEntityEnter
+		}
+		n, ok := g.write(conn, addr, msgType, snapshot[id])
+		if !ok {
+			return total, false
+		}
+		total += n
+	}
+
+	return total, true
+}
+
+// write sends one message and returns its size. It reports false only when
+// the client cannot be written to.
+func (g *GameState) write(conn UDPConn, addr *net.UDPAddr, msgType protocol.MsgType, payload any) (int, bool) {
+	data, err := protocol.Encode(msgType, payload)
+	if err != nil {
+		g.log.Error("Failed to encode message", "type", msgType.String(), "err", err)
+		return 0, true
+	}
+
+	if _, err := conn.WriteToUDP(data, addr); err != nil {
+		g.log.Warn("Failed to broadcast", "addr", addr.String(), "err", err)
+		return 0, false
+	}
+	return len(data), true
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
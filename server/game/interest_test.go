@@ -0,0 +1,121 @@
+package game
+
+import (
+	"net"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zainokta/client-server-multiplayer/server/player"
+	"github.com/zainokta/client-server-multiplayer/shared/protocol"
+)
+
+func TestGridQuery(t *testing.T) {
+	grid := NewGrid(4)
+	grid.Update(1, 1, 1)
+	grid.Update(2, 6, 1)
+	grid.Update(3, 30, 30)
+	grid.Update(4, -3, -3)
+
+	ids := grid.Query(2, 2, 3)
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	assert.Equal(t, []int32{1, 2, 4}, ids)
+
+	grid.Update(2, 29, 29)
+	ids = grid.Query(30, 30, 1)
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	assert.Equal(t, []int32{2, 3}, ids)
+
+	grid.Remove(3)
+	assert.Equal(t, []int32{2}, grid.Query(30, 30, 1))
+	assert.Len(t, grid.IDs(), 3)
+}
+
+// recordingConn keeps the packets written to each port.
+type recordingConn struct {
+	sent map[int][][]byte
+}
+
+func (r *recordingConn) WriteToUDP(b []byte, addr *net.UDPAddr) (int, error) {
+	if r.sent == nil {
+		r.sent = make(map[int][][]byte)
+	}
+	r.sent[addr.Port] = append(r.sent[addr.Port], append([]byte(nil), b...))
+	return len(b), nil
+}
+
+func (r *recordingConn) idsOf(port int, msgType protocol.MsgType) []int32 {
+	var ids []int32
+	for _, data := range r.sent[port] {
+		if t, _ := protocol.Type(data); t != msgType {
+			continue
+		}
+		var id int32
+		if msgType == protocol.MsgEntityLeave {
+			var leave protocol.EntityLeave
+			protocol.Decode(data, msgType, &leave)
+			id = leave.PlayerID
+		} else {
+			var p player.Player
+			protocol.Decode(data, msgType, &p)
+			id = p.ID
+		}
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func TestBroadcastAreaOfInterest(t *testing.T) {
+	gs := New(WithInterest(5, 4))
+	place(gs, 1, 10, 10)
+	place(gs, 2, 14, 10)
+	place(gs, 3, 40, 40)
+
+	conn := &recordingConn{}
+	gs.Broadcast(conn)
+
+	assert.Equal(t, []int32{1, 2}, conn.idsOf(addrFor(1).Port, protocol.MsgEntityEnter))
+	assert.Equal(t, []int32{1, 2}, conn.idsOf(addrFor(2).Port, protocol.MsgEntityEnter))
+	assert.Equal(t, []int32{3}, conn.idsOf(addrFor(3).Port, protocol.MsgEntityEnter))
+
+	conn = &recordingConn{}
+	gs.Broadcast(conn)
+	assert.Equal(t, []int32{1, 2}, conn.idsOf(addrFor(1).Port, protocol.MsgPlayerUpdate), "players in view get plain updates")
+	assert.Empty(t, conn.idsOf(addrFor(1).Port, protocol.MsgEntityEnter))
+
+	gs.Players.Store(int32(2), player.Player{ID: 2, X: 38, Y: 40, Sequence: 2})
+	conn = &recordingConn{}
+	gs.Broadcast(conn)
+
+	assert.Equal(t, []int32{2}, conn.idsOf(addrFor(1).Port, protocol.MsgEntityLeave))
+	assert.Equal(t, []int32{1}, conn.idsOf(addrFor(2).Port, protocol.MsgEntityLeave))
+	assert.Equal(t, []int32{3}, conn.idsOf(addrFor(2).Port, protocol.MsgEntityEnter))
+	assert.Equal(t, []int32{2}, conn.idsOf(addrFor(3).Port, protocol.MsgEntityEnter))
+}
+
+func TestBroadcastLeaveOnDisconnect(t *testing.T) {
+	gs := New(WithInterest(5, 4))
+	place(gs, 1, 10, 10)
+	place(gs, 2, 11, 10)
+
+	gs.Broadcast(&recordingConn{})
+
+	gs.Players.Delete(int32(2))
+	gs.Clients.Delete(int32(2))
+
+	conn := &recordingConn{}
+	gs.Broadcast(conn)
+	assert.Equal(t, []int32{2}, conn.idsOf(addrFor(1).Port, protocol.MsgEntityLeave))
+	assert.Empty(t, conn.sent[addrFor(2).Port])
+}
+
+func TestBroadcastWithoutInterestRadiusReachesEveryone(t *testing.T) {
+	gs := New()
+	place(gs, 1, 0, 0)
+	place(gs, 2, 1000, 1000)
+
+	conn := &recordingConn{}
+	gs.Broadcast(conn)
+	assert.Equal(t, []int32{1, 2}, conn.idsOf(addrFor(1).Port, protocol.MsgEntityEnter))
+}
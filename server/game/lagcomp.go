@@ -0,0 +1,164 @@
+package game
+
+import (
+	"math"
+	"net"
+	"time"
+
+	"github.com/zainokta/client-server-multiplayer/server/player"
+	"github.com/zainokta/client-server-multiplayer/shared/protocol"
+)
+
+// TagReach is how far, in cells, a player reaches when tagging.
+const TagReach = 1.5
+
+// frame is the position of every player after one tick.
+type frame struct {
+	tick    uint64
+	at      time.Time
+	players map[int32]player.Player
+}
+
+// history holds the latest frames, oldest first. It spans the rewind window
+// whatever the tick rate, so a rewind is never cut short by a full buffer.
+type history struct {
+	frames []frame
+}
+
+// record adds f and forgets the frames older than keep before it, except the
+// newest of them, which a rewind to the edge of the window lands on.
+func (h *history) record(f frame, keep time.Duration) {
+	h.frames = append(h.frames, f)
+
+	oldest := f.at.Add(-keep)
+	drop := 0
+	for drop+1 < len(h.frames) && !h.frames[drop+1].at.After(oldest) {
+		drop++
+	}
+	h.frames = h.frames[drop:]
+}
+
+// at returns the newest frame taken at or before t, or the oldest frame kept
+// when t is older than all of them.
+func (h *history) at(t time.Time) (frame, bool) {
+	if len(h.frames) == 0 {
+		return frame{}, false
+	}
+
+	found := h.frames[0]
+	for i := len(h.frames) - 1; i >= 0; i-- {
+		if !h.frames[i].at.After(t) {
+			found = h.frames[i]
+			break
+		}
+	}
+	return found, true
+}
+
+type queuedAction struct {
+	action   protocol.Action
+	addr     *net.UDPAddr
+	received time.Time
+}
+
+// WithLagCompensation judges actions against where other players were when
+// the actor saw them, up to window in the past. Without it actions are judged
+// against the current positions.
+func WithLagCompensation(window time.Duration) Option {
+	return func(g *GameState) {
+		g.rewindWindow = max(window, 0)
+	}
+}
+
+// SetRTT records the measured round trip time of the player's client, which
+// tells how late its actions arrive.
+func (g *GameState) SetRTT(id int32, rtt time.Duration) {
+	g.rtts.Store(id, rtt)
+}
+
+func (g *GameState) handleAction(addr *net.UDPAddr, data []byte) {
+	var action protocol.Action
+	if err := protocol.Decode(data, protocol.MsgAction, &action); err != nil {
+		g.log.Warn("Failed to decode action", "addr", addr.String(), "err", err)
+		g.metrics.DecodeFailed(protocol.MsgAction)
+		return
+	}
+
+	g.movesMu.Lock()
+	defer g.movesMu.Unlock()
+
+	g.actions = append(g.actions, queuedAction{action: action, addr: addr, received: g.clock.Now()})
+}
+
+func (g *GameState) drainActions() []queuedAction {
+	g.movesMu.Lock()
+	defer g.movesMu.Unlock()
+
+	actions := g.actions
+	g.actions = nil
+	return actions
+}
+
+// rewind returns how far back the actor saw the other players when it acted:
+// half its round trip for the action to arrive, plus the age of the state it
+// was shown. It is capped by the rewind window.
+func (g *GameState) rewind(a queuedAction) time.Duration {
+	var rtt time.Duration
+	if value, exists := g.rtts.Load(a.action.PlayerID); exists {
+		rtt = value.(time.Duration)
+	}
+	delay := rtt/2 + time.Duration(a.action.ViewDelayMs)*time.Millisecond
+	return min(delay, g.rewindWindow)
+}
+
+// judge evaluates an action against the other players rewound to the time
+// the actor saw them. The rewound positions are read from the history, so the
+// live state is never touched and needs no restoring. The actor and, on a hit,
+// the target are told the outcome.
+func (g *GameState) judge(conn UDPConn, a queuedAction) {
+	action := a.action
+	if known, exists := g.Clients.Load(action.PlayerID); !exists || known.(*net.UDPAddr).String() != a.addr.String() {
+		return
+	}
+	value, exists := g.Players.Load(action.PlayerID)
+	if !exists || action.Kind != protocol.ActionTag || action.Target == action.PlayerID {
+		return
+	}
+	actor := value.(player.Player)
+
+	rewind := g.rewind(a)
+	var target player.Player
+	var found bool
+	if past, recorded := g.history.at(a.received.Add(-rewind)); recorded && rewind > 0 {
+		target, found = past.players[action.Target]
+	} else if value, exists := g.Players.Load(action.Target); exists {
+		target, found = value.(player.Player), true
+	}
+
+	hit := found && math.Hypot(float64(target.X-actor.X), float64(target.Y-actor.Y)) <= TagReach
+	result := protocol.ActionResult{
+		PlayerID: action.PlayerID,
+		Kind:     action.Kind,
+		Target:   action.Target,
+		Sequence: action.Sequence,
+		Hit:      hit,
+		RewindMs: uint16(rewind.Milliseconds()),
+	}
+	g.log.Debug("Judged action", "player", action.PlayerID, "target", action.Target, "hit", hit, "rewind", rewind)
+
+	g.send(conn, a.addr, protocol.MsgActionResult, result)
+	if addr, exists := g.Clients.Load(action.Target); exists && hit {
+		g.send(conn, addr.(*net.UDPAddr), protocol.MsgActionResult, result)
+	}
+}
+
+// record keeps the positions after a tick for later rewinds.
+func (g *GameState) record(now time.Time) {
+	players := make(map[int32]player.Player)
+	g.Players.Range(func(_, value any) bool {
+		p := value.(player.Player)
+		players[p.ID] = p
+		return true
+	})
+	g.history.record(frame{tick: g.Tick(), at: now, players: players}, g.rewindWindow)
+}
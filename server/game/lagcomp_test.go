@@ -0,0 +1,116 @@
+package game
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zainokta/client-server-multiplayer/server/player"
+	"github.com/zainokta/client-server-multiplayer/shared/clock"
+	"github.com/zainokta/client-server-multiplayer/shared/protocol"
+)
+
+// fleeing returns a game where player 2 was next to player 1 150ms ago and
+// has been far away since 100ms ago.
+func fleeing(opts ...Option) *GameState {
+	clk := clock.NewFake(time.Unix(1000, 0))
+	gs := New(append(opts, WithClock(clk))...)
+	place(gs, 1, 5, 5)
+	place(gs, 2, 6, 5)
+	gs.record(clk.Now())
+	clk.Advance(50 * time.Millisecond)
+	gs.Players.Store(int32(2), player.Player{ID: 2, X: 12, Y: 5, Sequence: 1})
+	gs.record(clk.Now())
+	clk.Advance(90 * time.Millisecond)
+	gs.record(clk.Now())
+	clk.Advance(10 * time.Millisecond)
+	return gs
+}
+
+func tag(gs *GameState, conn UDPConn, addr *net.UDPAddr, viewDelay uint16) []protocol.ActionResult {
+	data, _ := protocol.Encode(protocol.MsgAction, protocol.Action{PlayerID: 1, Kind: protocol.ActionTag, Target: 2, Sequence: 7, ViewDelayMs: viewDelay})
+	gs.HandleClient(conn, addr, data)
+	gs.Step(conn)
+
+	var results []protocol.ActionResult
+	for _, data := range conn.(*mockUDPConn).written {
+		var result protocol.ActionResult
+		if protocol.Decode(data, protocol.MsgActionResult, &result) == nil {
+			results = append(results, result)
+		}
+	}
+	return results
+}
+
+func TestLagCompensatedTag(t *testing.T) {
+	gs := fleeing(WithLagCompensation(200 * time.Millisecond))
+	gs.SetRTT(1, 100*time.Millisecond)
+
+	results := tag(gs, &mockUDPConn{}, addrFor(1), 100)
+	require.Len(t, results, 2, "the actor and the target are told about a hit")
+	assert.Equal(t, protocol.ActionResult{PlayerID: 1, Kind: protocol.ActionTag, Target: 2, Sequence: 7, Hit: true, RewindMs: 150}, results[0])
+
+	x, _ := position(gs, 2)
+	assert.Equal(t, float32(12), x, "rewinding leaves the live state alone")
+}
+
+func TestTagWithoutLagCompensation(t *testing.T) {
+	gs := fleeing()
+	gs.SetRTT(1, 100*time.Millisecond)
+
+	results := tag(gs, &mockUDPConn{}, addrFor(1), 100)
+	require.Len(t, results, 1)
+	assert.False(t, results[0].Hit)
+	assert.Zero(t, results[0].RewindMs)
+}
+
+func TestRewindIsCapped(t *testing.T) {
+	gs := fleeing(WithLagCompensation(50 * time.Millisecond))
+	gs.SetRTT(1, 100*time.Millisecond)
+
+	results := tag(gs, &mockUDPConn{}, addrFor(1), 100)
+	require.Len(t, results, 1)
+	assert.False(t, results[0].Hit)
+	assert.Equal(t, uint16(50), results[0].RewindMs)
+}
+
+func TestActionFromAnotherAddressIsDropped(t *testing.T) {
+	gs := fleeing(WithLagCompensation(200 * time.Millisecond))
+
+	assert.Empty(t, tag(gs, &mockUDPConn{}, addrFor(2), 0))
+}
+
+func TestHistoryKeepsLatestFrames(t *testing.T) {
+	var h history
+	_, found := h.at(time.Now())
+	assert.False(t, found)
+
+	start := time.Now()
+	for i := range 100 {
+		h.record(frame{tick: uint64(i), at: start.Add(time.Duration(i) * time.Millisecond)}, 50*time.Millisecond)
+	}
+
+	f, _ := h.at(start.Add(60*time.Millisecond + time.Microsecond))
+	assert.Equal(t, uint64(60), f.tick)
+	f, _ = h.at(start.Add(49 * time.Millisecond))
+	assert.Equal(t, uint64(49), f.tick, "the whole window is kept")
+	f, _ = h.at(start)
+	assert.Equal(t, uint64(49), f.tick, "older times get the oldest frame kept")
+	f, _ = h.at(start.Add(time.Hour))
+	assert.Equal(t, uint64(99), f.tick)
+	assert.Len(t, h.frames, 51)
+}
+
+func TestHistorySpansTheWindowAtHighTickRates(t *testing.T) {
+	var h history
+	start := time.Now()
+	// A millisecond per tick, as at the highest tick rate.
+	for i := range 1000 {
+		h.record(frame{tick: uint64(i), at: start.Add(time.Duration(i) * time.Millisecond)}, 200*time.Millisecond)
+	}
+
+	f, _ := h.at(start.Add(999*time.Millisecond - 200*time.Millisecond))
+	assert.Equal(t, uint64(799), f.tick, "a rewind over the whole window is not clamped")
+}
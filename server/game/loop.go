@@ -0,0 +1,111 @@
+package game
+
+import (
+	"context"
+	"time"
+)
+
+// MaxCatchUp bounds how many ticks Run steps back to back after falling
+// behind. Older missed ticks are skipped rather than simulated, so a long
+// stall does not turn into a burst of moves.
+const MaxCatchUp = 5
+
+// TickStats describe one iteration of the tick loop.
+type TickStats struct {
+	// Tick is the tick counter after the iteration.
+	Tick uint64
+	// Steps is how many ticks were simulated, more than one when catching up.
+	Steps int
+	// Skipped is how many missed ticks were dropped.
+	Skipped int
+	// Duration is how long the iteration took to step and broadcast, of
+	// which Broadcast was spent replicating.
+	Duration  time.Duration
+	Broadcast time.Duration
+	// Budget is the time one tick may take at the current tick rate.
+	Budget  time.Duration
+	Overrun bool
+}
+
+// Tick returns how many ticks the game has simulated.
+func (g *GameState) Tick() uint64 {
+	return g.tick.Load()
+}
+
+// Overruns returns how many ticks took longer than their budget.
+func (g *GameState) Overruns() uint64 {
+	return g.overruns.Load()
+}
+
+// Run is the authoritative fixed-timestep loop of the game. Every tick it
+// drains the queued inputs and advances the simulation with Step, then
+// replicates the result with Broadcast. rate is read every tick, so the tick
+// rate can change while the loop runs. Ticks are scheduled on a fixed grid:
+// when a tick overruns its budget the missed ticks are stepped right away, up
+// to MaxCatchUp, before a single broadcast. onTick, if not nil, is called
+// after every iteration.
+func (g *GameState) Run(ctx context.Context, conn UDPConn, rate func() int, onTick func(TickStats)) {
+	current := max(rate(), 1)
+	budget := time.Second / time.Duration(current)
+	next := g.clock.Now().Add(budget)
+	lastCheck := g.clock.Now()
+
+	timer := g.clock.NewTimer(budget)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C():
+		}
+
+		start := g.clock.Now()
+		steps := 1 + int(start.Sub(next)/budget)
+		skipped := 0
+		if steps > MaxCatchUp {
+			skipped = steps - MaxCatchUp
+			steps = MaxCatchUp
+		}
+
+		for range steps {
+			g.Step(conn)
+		}
+		broadcast := g.clock.Now()
+		g.Broadcast(conn)
+		end := g.clock.Now()
+
+		if end.Sub(lastCheck) >= DisconnectTimer {
+			g.removeDisconnected(end)
+			lastCheck = end
+		}
+
+		stats := TickStats{
+			Tick:      g.Tick(),
+			Steps:     steps,
+			Skipped:   skipped,
+			Duration:  end.Sub(start),
+			Broadcast: end.Sub(broadcast),
+			Budget:    budget,
+			Overrun:   end.Sub(start) > budget,
+		}
+		if stats.Overrun {
+			g.overruns.Add(1)
+			g.log.Warn("Tick overran its budget", "tick", stats.Tick, "duration", stats.Duration, "budget", budget)
+		}
+		if skipped > 0 {
+			g.log.Warn("Skipped ticks to catch up", "tick", stats.Tick, "skipped", skipped)
+		}
+		if onTick != nil {
+			onTick(stats)
+		}
+
+		next = next.Add(time.Duration(steps+skipped) * budget)
+		if r := max(rate(), 1); r != current {
+			current = r
+			budget = time.Second / time.Duration(current)
+			next = end.Add(budget)
+		}
+		timer.Reset(max(next.Sub(g.clock.Now()), 0))
+	}
+}
@@ -0,0 +1,117 @@
+package game
+
+import (
+	"context"
+	"net"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zainokta/client-server-multiplayer/server/world"
+	"github.com/zainokta/client-server-multiplayer/shared/clock"
+)
+
+// stallingConn takes stall on the first write, as a slow broadcast would.
+type stallingConn struct {
+	clock *clock.Fake
+	stall time.Duration
+	once  sync.Once
+}
+
+func (c *stallingConn) WriteToUDP(b []byte, addr *net.UDPAddr) (int, error) {
+	c.once.Do(func() { c.clock.Advance(c.stall) })
+	return len(b), nil
+}
+
+// runLoop runs the loop until it reported n iterations and returns them. The
+// clock advances a millisecond at a time whenever the loop waits for it.
+func runLoop(gs *GameState, clk *clock.Fake, conn UDPConn, rate func() int, n int) []TickStats {
+	ctx, cancel := context.WithCancel(context.Background())
+	iterations := make(chan TickStats, n)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		gs.Run(ctx, conn, rate, func(stats TickStats) {
+			select {
+			case iterations <- stats:
+			default:
+			}
+		})
+	}()
+
+	var stats []TickStats
+	for len(stats) < n {
+		select {
+		case s := <-iterations:
+			stats = append(stats, s)
+		default:
+			if clk.Waiting() > 0 {
+				clk.Advance(time.Millisecond)
+			} else {
+				runtime.Gosched()
+			}
+		}
+	}
+	cancel()
+	<-done
+	return stats
+}
+
+func TestRunStepsEveryTick(t *testing.T) {
+	clk := clock.NewFake(time.Unix(1000, 0))
+	gs := New(WithWorld(world.New(20, 10)), WithClock(clk))
+	place(gs, 1, 5, 5)
+	move(gs, &mockUDPConn{}, 1, 6, 5, 2)
+
+	start := clk.Now()
+	stats := runLoop(gs, clk, &mockUDPConn{}, func() int { return 100 }, 3)
+
+	for i, s := range stats {
+		assert.Equal(t, 1, s.Steps)
+		assert.Equal(t, uint64(i+1), s.Tick, "the counter counts every step")
+		assert.Equal(t, 10*time.Millisecond, s.Budget)
+		assert.False(t, s.Overrun)
+	}
+	assert.Equal(t, uint64(3), gs.Tick())
+	assert.Equal(t, 30*time.Millisecond, clk.Since(start), "ticks follow the budget")
+
+	x, y := position(gs, 1)
+	assert.Equal(t, float32(6), x, "the loop drains queued moves")
+	assert.Equal(t, float32(5), y)
+}
+
+func TestRunCatchesUpAfterOverrun(t *testing.T) {
+	clk := clock.NewFake(time.Unix(1000, 0))
+	gs := New(WithWorld(world.New(20, 10)), WithClock(clk))
+	place(gs, 1, 5, 5)
+
+	conn := &stallingConn{clock: clk, stall: 100 * time.Millisecond}
+	stats := runLoop(gs, clk, conn, func() int { return 100 }, 2)
+
+	assert.True(t, stats[0].Overrun)
+	assert.Equal(t, 100*time.Millisecond, stats[0].Duration)
+	assert.Equal(t, uint64(1), gs.Overruns())
+
+	assert.Equal(t, MaxCatchUp, stats[1].Steps, "missed ticks are stepped back to back")
+	assert.Equal(t, 5, stats[1].Skipped, "ticks beyond MaxCatchUp are skipped")
+	assert.Equal(t, uint64(1+MaxCatchUp), stats[1].Tick)
+}
+
+func TestRunFollowsTickRate(t *testing.T) {
+	clk := clock.NewFake(time.Unix(1000, 0))
+	gs := New(WithClock(clk))
+	var mu sync.Mutex
+	rate := 200
+	stats := runLoop(gs, clk, &mockUDPConn{}, func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		current := rate
+		rate = 50
+		return current
+	}, 2)
+
+	assert.Equal(t, 5*time.Millisecond, stats[0].Budget)
+	assert.Equal(t, 20*time.Millisecond, stats[1].Budget)
+}
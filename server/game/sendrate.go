@@ -0,0 +1,121 @@
+package game
+
+import "time"
+
+const (
+	// LossThreshold is the packet loss above which an adaptive send rate is
+	// halved. Below it the rate grows by one snapshot per second each time it
+	// is adapted.
+	LossThreshold = 0.05
+	// sendTolerance lets a client that is due within the jitter of the tick
+	// loop be sent to on this tick rather than the next.
+	sendTolerance = 2 * time.Millisecond
+)
+
+// sendState paces the snapshots sent to one client.
+type sendState struct {
+	rate int
+	next time.Time
+	// bytes is the smoothed size of one snapshot of the client.
+	bytes float64
+}
+
+// WithSnapshotRate limits how many snapshots per second each client is sent,
+// independently of the tick rate. Zero sends one every Broadcast.
+func WithSnapshotRate(rate int) Option {
+	return func(g *GameState) {
+		g.snapshotRate = max(rate, 0)
+	}
+}
+
+// WithAdaptiveSendRate lets AdaptSendRate lower the snapshot rate of clients
+// that lose packets or would exceed bandwidth bytes per second, down to
+// minRate. A bandwidth of zero is unlimited. It needs a snapshot rate, which
+// is the highest rate a client gets.
+func WithAdaptiveSendRate(minRate, bandwidth int) Option {
+	return func(g *GameState) {
+		g.adaptive = true
+		g.minSendRate = max(minRate, 1)
+		g.bandwidth = bandwidth
+	}
+}
+
+// SendRate returns the snapshots per second the client of the player is
+// sent, or 0 when it is sent one every Broadcast.
+func (g *GameState) SendRate(id int32) int {
+	g.viewsMu.Lock()
+	defer g.viewsMu.Unlock()
+
+	if g.snapshotRate == 0 {
+		return 0
+	}
+	if state, exists := g.sends[id]; exists {
+		return state.rate
+	}
+	return g.snapshotRate
+}
+
+// AdaptSendRate adjusts the snapshot rate of the player's client to the
+// packet loss measured for it: the rate is halved while loss is above
+// LossThreshold and otherwise grows back by one towards the snapshot rate. It
+// never exceeds what the bandwidth allows for the client's snapshot size.
+func (g *GameState) AdaptSendRate(id int32, loss float64) {
+	if !g.adaptive || g.snapshotRate == 0 {
+		return
+	}
+
+	g.viewsMu.Lock()
+	defer g.viewsMu.Unlock()
+
+	state, exists := g.sends[id]
+	if !exists {
+		return
+	}
+
+	rate := state.rate + 1
+	if loss > LossThreshold {
+		rate = state.rate / 2
+	}
+	if g.bandwidth > 0 && state.bytes > 0 {
+		rate = min(rate, int(float64(g.bandwidth)/state.bytes))
+	}
+	state.rate = min(max(rate, g.minSendRate), g.snapshotRate)
+}
+
+// due reports whether the client of the player should be sent a snapshot at
+// now, and schedules the next one if so. The caller holds viewsMu.
+func (g *GameState) due(id int32, now time.Time) bool {
+	if g.snapshotRate == 0 {
+		return true
+	}
+
+	state, exists := g.sends[id]
+	if !exists {
+		state = &sendState{rate: g.snapshotRate, next: now}
+		g.sends[id] = state
+	}
+	if now.Add(sendTolerance).Before(state.next) {
+		return false
+	}
+
+	interval := time.Second / time.Duration(state.rate)
+	state.next = state.next.Add(interval)
+	if state.next.Before(now) {
+		state.next = now.Add(interval)
+	}
+	return true
+}
+
+// sent records the size of a snapshot sent to the client of the player. The
+// caller holds viewsMu.
+func (g *GameState) sent(id int32, bytes int) {
+	state, exists := g.sends[id]
+	if !exists {
+		return
+	}
+	if state.bytes == 0 {
+		state.bytes = float64(bytes)
+		return
+	}
+	state.bytes += (float64(bytes) - state.bytes) / 8
+}
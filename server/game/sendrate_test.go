@@ -0,0 +1,85 @@
+package game
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotRatePacesClients(t *testing.T) {
+	gs := New(WithSnapshotRate(10))
+	place(gs, 1, 5, 5)
+	conn := &mockUDPConn{}
+
+	gs.Broadcast(conn)
+	sent := len(conn.written)
+	assert.Positive(t, sent)
+
+	gs.Broadcast(conn)
+	assert.Len(t, conn.written, sent, "the client is not due again within 100ms")
+
+	gs.viewsMu.Lock()
+	defer gs.viewsMu.Unlock()
+
+	now := time.Now()
+	state := gs.sends[1]
+	state.next = now
+	assert.True(t, gs.due(1, now.Add(-time.Millisecond)), "small tick jitter is tolerated")
+	assert.Equal(t, now.Add(100*time.Millisecond), state.next)
+	assert.False(t, gs.due(1, now.Add(50*time.Millisecond)))
+	assert.True(t, gs.due(1, now.Add(time.Second)))
+	assert.Equal(t, now.Add(1100*time.Millisecond), state.next, "a late client is not sent a burst")
+}
+
+func TestUnpacedBroadcast(t *testing.T) {
+	gs := New()
+	place(gs, 1, 5, 5)
+	conn := &mockUDPConn{}
+
+	gs.Broadcast(conn)
+	sent := len(conn.written)
+	gs.Broadcast(conn)
+	assert.Len(t, conn.written, 2*sent)
+	assert.Zero(t, gs.SendRate(1))
+}
+
+func TestAdaptSendRate(t *testing.T) {
+	gs := New(WithSnapshotRate(20), WithAdaptiveSendRate(4, 0))
+	place(gs, 1, 5, 5)
+	gs.Broadcast(&mockUDPConn{})
+	assert.Equal(t, 20, gs.SendRate(1))
+
+	gs.AdaptSendRate(1, 0.2)
+	assert.Equal(t, 10, gs.SendRate(1))
+	gs.AdaptSendRate(1, 0.2)
+	gs.AdaptSendRate(1, 0.2)
+	assert.Equal(t, 4, gs.SendRate(1), "the rate never drops below the minimum")
+
+	gs.AdaptSendRate(1, 0)
+	assert.Equal(t, 5, gs.SendRate(1))
+	for range 30 {
+		gs.AdaptSendRate(1, 0.01)
+	}
+	assert.Equal(t, 20, gs.SendRate(1), "the rate grows back to the snapshot rate")
+}
+
+func TestAdaptSendRateToBandwidth(t *testing.T) {
+	gs := New(WithSnapshotRate(30), WithAdaptiveSendRate(2, 200))
+	place(gs, 1, 5, 5)
+	conn := &mockUDPConn{}
+	gs.Broadcast(conn)
+
+	size := len(conn.written[0])
+	gs.AdaptSendRate(1, 0)
+	assert.Equal(t, max(200/size, 2), gs.SendRate(1))
+}
+
+func TestAdaptSendRateDisabled(t *testing.T) {
+	gs := New(WithSnapshotRate(20))
+	place(gs, 1, 5, 5)
+	gs.Broadcast(&mockUDPConn{})
+
+	gs.AdaptSendRate(1, 1)
+	assert.Equal(t, 20, gs.SendRate(1))
+}
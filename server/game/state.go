@@ -1,27 +1,138 @@
 package game
 
 import (
-	"fmt"
-	"log"
+	"errors"
+	"log/slog"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/zainokta/client-server-multiplayer/server/metrics"
 	"github.com/zainokta/client-server-multiplayer/server/player"
+	"github.com/zainokta/client-server-multiplayer/server/world"
+	"github.com/zainokta/client-server-multiplayer/shared/clock"
+	"github.com/zainokta/client-server-multiplayer/shared/protocol"
 )
 
 const (
 	DisconnectTimer = 5 * time.Second
 )
 
+var (
+	ErrUnknownPlayer = errors.New("player is not in the game")
+	ErrNotWalkable   = errors.New("position is outside of the world or in a wall")
+)
+
 type GameState struct {
 	Players         sync.Map
 	Clients         sync.Map
 	SequenceNumbers sync.Map
+
+	roomID  uint32
+	world   *world.World
+	mode    Mode
+	metrics *metrics.Metrics
+	log     *slog.Logger
+	clock   clock.Clock
+
+	// tick counts the Steps taken so far.
+	tick     atomic.Uint64
+	overruns atomic.Uint64
+
+	movesMu   sync.Mutex
+	moves     map[int32]queuedMove
+	teleports []teleport
+	actions   []queuedAction
+
+	// history is only touched by Step.
+	history      history
+	rewindWindow time.Duration
+	rtts         sync.Map
+
+	rules       MovementRules
+	kick        func(addr *net.UDPAddr)
+	movementsMu sync.Mutex
+	// movements is keyed by client address.
+	movements map[string]*movement
+
+	// lastSeen holds when the server last heard from each player.
+	lastSeen sync.Map
+	// spawns holds the cells players that have not entered yet were moved
+	// to because their spawn was taken.
+	spawns sync.Map
+
+	interestRadius int
+	viewsMu        sync.Mutex
+	grid           *Grid
+	views          map[int32]view
+
+	snapshotRate int
+	adaptive     bool
+	minSendRate  int
+	bandwidth    int
+	// sends is guarded by viewsMu.
+	sends map[int32]*sendState
+}
+
+type Option func(*GameState)
+
+// WithWorld bounds player movement to w. Without it the world is unbounded.
+func WithWorld(w world.World) Option {
+	return func(g *GameState) {
+		g.world = &w
+	}
+}
+
+// WithMode sets the game rules. Without it players never collide.
+func WithMode(m Mode) Option {
+	return func(g *GameState) {
+		g.mode = m
+	}
+}
+
+// WithRoomID sets the room reported to clients in Welcome.
+func WithRoomID(id uint32) Option {
+	return func(g *GameState) {
+		g.roomID = id
+	}
+}
+
+// WithMetrics records dropped packets and decode failures in m.
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(g *GameState) {
+		g.metrics = m
+	}
+}
+
+// WithLogger sets the logger of the game. Defaults to slog.Default.
+func WithLogger(l *slog.Logger) Option {
+	return func(g *GameState) {
+		g.log = l
+	}
+}
+
+// WithClock sets the clock that times ticks, moves and disconnections.
+// Defaults to clock.Real.
+func WithClock(c clock.Clock) Option {
+	return func(g *GameState) {
+		g.clock = c
+	}
 }
 
-func New() GameState {
-	return GameState{}
+func New(opts ...Option) *GameState {
+	g := &GameState{
+		grid:      NewGrid(DefaultGridCellSize),
+		views:     make(map[int32]view),
+		sends:     make(map[int32]*sendState),
+		movements: make(map[string]*movement),
+		log:       slog.Default(),
+		clock:     clock.Real,
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
 }
 
 type UDPConn interface {
@@ -29,64 +140,247 @@ type UDPConn interface {
 }
 
 func (g *GameState) HandleClient(conn UDPConn, addr *net.UDPAddr, data []byte) {
+	msgType, err := protocol.Type(data)
+	if err != nil {
+		g.log.Warn("Failed to decode message", "addr", addr.String(), "err", err)
+		g.metrics.Dropped(metrics.DropUnknownType)
+		return
+	}
+
+	switch msgType {
+	case protocol.MsgHello:
+		g.handleHello(conn, addr, data)
+	case protocol.MsgPlayerUpdate:
+		g.handlePlayerUpdate(addr, data)
+	case protocol.MsgAction:
+		g.handleAction(addr, data)
+	default:
+		g.log.Debug("Unknown message type", "type", uint8(msgType), "addr", addr.String())
+		g.metrics.Dropped(metrics.DropUnknownType)
+	}
+}
+
+func (g *GameState) handleHello(conn UDPConn, addr *net.UDPAddr, data []byte) {
+	var hello protocol.Hello
+	if err := protocol.Decode(data, protocol.MsgHello, &hello); err != nil {
+		g.log.Warn("Failed to decode hello", "addr", addr.String(), "err", err)
+		g.metrics.DecodeFailed(protocol.MsgHello)
+		return
+	}
+
+	if g.bound(hello.PlayerID, addr) {
+		g.Clients.Store(hello.PlayerID, addr)
+		g.lastSeen.Store(hello.PlayerID, g.clock.Now())
+	}
+
+	welcome := protocol.Welcome{PlayerID: hello.PlayerID, RoomID: g.roomID}
+	if g.world != nil {
+		welcome.Width = uint16(g.world.Width)
+		welcome.Height = uint16(g.world.Height)
+		welcome.SpawnX, welcome.SpawnY = g.world.Spawn(hello.PlayerID)
+	}
+
+	g.send(conn, addr, protocol.MsgWelcome, welcome)
+	g.sendMap(conn, addr)
+}
+
+// sendMap streams the tile map in chunks of whole rows. Clients resend Hello
+// until they have every row, so lost chunks are simply sent again.
+func (g *GameState) sendMap(conn UDPConn, addr *net.UDPAddr) {
+	if g.world == nil {
+		return
+	}
+
+	rowsPerChunk := max(1, protocol.MaxChunkTiles/g.world.Width)
+	for start := 0; start < g.world.Height; start += rowsPerChunk {
+		rows := min(rowsPerChunk, g.world.Height-start)
+
+		tiles := make([]byte, 0, rows*g.world.Width)
+		for y := start; y < start+rows; y++ {
+			for _, tile := range g.world.Row(y) {
+				tiles = append(tiles, byte(tile))
+			}
+		}
+
+		data, err := protocol.EncodeMapChunk(protocol.MapChunk{
+			StartRow: uint16(start),
+			Rows:     uint16(rows),
+			Width:    uint16(g.world.Width),
+		}, tiles)
+		if err != nil {
+			g.log.Error("Failed to encode map chunk", "err", err)
+			return
+		}
+
+		if _, err := conn.WriteToUDP(data, addr); err != nil {
+			g.log.Warn("Failed to send map chunk", "addr", addr.String(), "err", err)
+			return
+		}
+	}
+}
+
+// handlePlayerUpdate queues a move for the next Step. Outdated packets and
+// packets for a player of another client are dropped right away.
+func (g *GameState) handlePlayerUpdate(addr *net.UDPAddr, data []byte) {
 	gamePlayer, err := player.DeserializePlayer(data)
 	if err != nil {
-		log.Println("Failed to decode player data:", err)
+		g.log.Warn("Failed to decode player data", "addr", addr.String(), "err", err)
+		g.metrics.DecodeFailed(protocol.MsgPlayerUpdate)
+		return
+	}
+
+	if !g.bound(gamePlayer.ID, addr) {
+		g.log.Warn("Dropping update for the player of another client", "player", gamePlayer.ID, "addr", addr.String())
+		g.metrics.Dropped(metrics.DropWrongPlayer)
 		return
 	}
 
 	if lastSeq, exists := g.SequenceNumbers.Load(gamePlayer.ID); exists {
 		if gamePlayer.Sequence <= lastSeq.(uint32) {
-			fmt.Printf("[Server] Ignoring outdated packet from Player %d\n", gamePlayer.ID)
+			g.log.Debug("Ignoring outdated packet", "player", gamePlayer.ID, "addr", addr.String(), "seq", gamePlayer.Sequence)
+			g.metrics.Dropped(metrics.DropOutdated)
 			return
 		}
 	}
 
 	g.SequenceNumbers.Store(gamePlayer.ID, gamePlayer.Sequence)
-
-	g.Players.Store(gamePlayer.ID, gamePlayer)
 	g.Clients.Store(gamePlayer.ID, addr)
+	g.lastSeen.Store(gamePlayer.ID, g.clock.Now())
+	g.queueMove(gamePlayer, addr)
+}
 
-	fmt.Printf("Player %d updated: X=%.2f, Y=%.2f (from %s)\n", gamePlayer.ID, gamePlayer.X, gamePlayer.Y, addr)
+// bound reports whether the player is free or already played from addr.
+func (g *GameState) bound(id int32, addr *net.UDPAddr) bool {
+	client, exists := g.Clients.Load(id)
+	return !exists || client.(*net.UDPAddr).String() == addr.String()
+}
+
+// reject keeps the player at its last accepted position and tells the client why.
+func (g *GameState) reject(conn UDPConn, addr *net.UDPAddr, gamePlayer player.Player, reason protocol.CorrectionReason) {
+	correction := protocol.Correction{
+		PlayerID: gamePlayer.ID,
+		Sequence: gamePlayer.Sequence,
+		Reason:   reason,
+	}
+
+	if last, exists := g.Players.Load(gamePlayer.ID); exists {
+		lastPlayer := last.(player.Player)
+		correction.X, correction.Y = lastPlayer.X, lastPlayer.Y
+	} else if g.world != nil {
+		correction.X, correction.Y = g.spawnOf(gamePlayer.ID)
+	}
+
+	g.log.Debug("Rejected move", "player", gamePlayer.ID, "addr", addr.String(), "reason", reason.String())
+	g.send(conn, addr, protocol.MsgCorrection, correction)
+}
+
+func (g *GameState) send(conn UDPConn, addr *net.UDPAddr, msgType protocol.MsgType, payload any) {
+	data, err := protocol.Encode(msgType, payload)
+	if err != nil {
+		g.log.Error("Failed to encode message", "type", msgType.String(), "err", err)
+		return
+	}
+
+	if _, err := conn.WriteToUDP(data, addr); err != nil {
+		g.log.Warn("Failed to send message", "type", msgType.String(), "addr", addr.String(), "err", err)
+	}
 }
 
+// Broadcast replicates the players each client is interested in. Clients are
+// told when players enter or leave their view, so they can create and remove
+// them. With a snapshot rate, clients that are not due yet are skipped and
+// catch up with every change on their next snapshot.
 func (g *GameState) Broadcast(conn UDPConn) {
+	now := g.clock.Now()
+	snapshot := make(map[int32]player.Player)
 	g.Players.Range(func(key, value interface{}) bool {
 		p := value.(player.Player)
+		snapshot[p.ID] = p
+		return true
+	})
 
-		data, err := player.SerializePlayer(p)
-		if err != nil {
-			log.Println("Error serializing player:", err)
+	g.viewsMu.Lock()
+	defer g.viewsMu.Unlock()
+
+	g.syncGrid(snapshot)
+
+	connected := make(map[int32]struct{})
+	g.Clients.Range(func(key, addr interface{}) bool {
+		id := key.(int32)
+		if !g.due(id, now) {
+			connected[id] = struct{}{}
 			return true
 		}
 
-		g.Clients.Range(func(key, addr interface{}) bool {
-			udpAddr := addr.(*net.UDPAddr)
-			_, err := conn.WriteToUDP(data, udpAddr)
-			if err != nil {
-				log.Println("Error broadcasting:", err)
-				g.Clients.Delete(key)
-			}
+		visible := g.visibleTo(id, snapshot)
+		bytes, ok := g.replicate(conn, addr.(*net.UDPAddr), g.views[id], visible, snapshot)
+		if !ok {
+			g.Clients.Delete(key)
 			return true
-		})
+		}
 
+		g.views[id] = visible
+		g.sent(id, bytes)
+		connected[id] = struct{}{}
 		return true
 	})
+
+	for id := range g.views {
+		if _, exists := connected[id]; !exists {
+			delete(g.views, id)
+		}
+	}
+	for id := range g.sends {
+		if _, exists := connected[id]; !exists {
+			delete(g.sends, id)
+		}
+	}
 }
 
-func (g *GameState) MonitorDisconnections() {
-	ticker := time.NewTicker(DisconnectTimer)
-	for range ticker.C {
-		now := time.Now().UnixMilli()
-		g.Players.Range(func(key, value interface{}) bool {
-			gamePlayer := value.(player.Player)
-			if now-gamePlayer.Timestamp > DisconnectTimer.Milliseconds() {
-				fmt.Printf("[Server] Player %d disconnected.\n", gamePlayer.ID)
-				g.Players.Delete(key)
-				g.SequenceNumbers.Delete(key)
-				g.Clients.Delete(key)
-			}
-			return true
-		})
+// RemovePlayer drops a player and its client, e.g. when it leaves for another
+// room. Clients still watching it are told on the next Broadcast.
+func (g *GameState) RemovePlayer(id int32) {
+	g.rtts.Delete(id)
+	g.lastSeen.Delete(id)
+	g.spawns.Delete(id)
+	g.movementsMu.Lock()
+	for addr, m := range g.movements {
+		if m.player == id {
+			delete(g.movements, addr)
+		}
 	}
+	g.movementsMu.Unlock()
+	g.Players.Delete(id)
+	g.SequenceNumbers.Delete(id)
+	g.Clients.Delete(id)
+}
+
+// Teleport moves a player to (x, y) on the next Step, regardless of other
+// players, and tells its client.
+func (g *GameState) Teleport(id int32, x, y float32) error {
+	if _, exists := g.Players.Load(id); !exists {
+		return ErrUnknownPlayer
+	}
+	if g.world != nil && !g.world.Contains(x, y) {
+		return ErrNotWalkable
+	}
+
+	g.movesMu.Lock()
+	defer g.movesMu.Unlock()
+
+	g.teleports = append(g.teleports, teleport{id: id, x: x, y: y})
+	return nil
+}
+
+// removeDisconnected removes the players the server heard nothing from for
+// longer than DisconnectTimer. The time the server received their packets is
+// used rather than the timestamps in them, which come from the client clock.
+func (g *GameState) removeDisconnected(now time.Time) {
+	g.lastSeen.Range(func(key, value interface{}) bool {
+		if now.Sub(value.(time.Time)) > DisconnectTimer {
+			g.log.Info("Player disconnected", "player", key.(int32))
+			g.RemovePlayer(key.(int32))
+		}
+		return true
+	})
 }
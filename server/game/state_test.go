@@ -1,12 +1,17 @@
 package game
 
 import (
+	"context"
 	"net"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/zainokta/client-server-multiplayer/server/player"
+	"github.com/zainokta/client-server-multiplayer/server/world"
+	"github.com/zainokta/client-server-multiplayer/shared/clock"
+	"github.com/zainokta/client-server-multiplayer/shared/protocol"
 )
 
 func TestNewGameState(t *testing.T) {
@@ -53,56 +58,74 @@ func TestHandleClientWithInvalidData(t *testing.T) {
 	assert.Equal(t, 0, count, "No player should be added with invalid data")
 }
 
-func TestMonitorDisconnections(t *testing.T) {
-	gs := New()
-	conn := &mockUDPConn{}
-	addr := &net.UDPAddr{
-		IP:   net.ParseIP("127.0.0.1"),
-		Port: 9000,
-	}
+// waitForClock waits until n timers or tickers of clk are armed, so the
+// goroutines using them are ready for the clock to advance.
+func waitForClock(t *testing.T, clk *clock.Fake, n int) {
+	t.Helper()
+	assert.Eventually(t, func() bool { return clk.Waiting() == n }, time.Second, time.Millisecond)
+}
 
-	now := time.Now().UnixMilli()
+func TestRunRemovesDisconnectedPlayers(t *testing.T) {
+	clk := clock.NewFake(time.Unix(1000, 0))
+	gs := New(WithClock(clk))
+	conn := &mockUDPConn{}
 
-	activePlayer := player.Player{
-		ID:        1,
-		X:         100,
-		Y:         200,
-		Timestamp: now,
-		Sequence:  1,
+	// The clock of the active client runs an hour behind and the clock of
+	// the inactive one an hour ahead; only the time the server received
+	// their packets counts.
+	sequence := map[int32]uint32{}
+	send := func(id int32, skew time.Duration) {
+		sequence[id]++
+		data, err := player.SerializePlayer(player.Player{
+			ID:        id,
+			X:         float32(100 * id),
+			Y:         200,
+			Timestamp: clk.Now().Add(skew).UnixMilli(),
+			Sequence:  sequence[id],
+		})
+		assert.NoError(t, err)
+		gs.HandleClient(conn, addrFor(id), data)
 	}
-
-	inactivePlayer := player.Player{
-		ID:        2,
-		X:         300,
-		Y:         400,
-		Timestamp: now - (DisconnectTimer.Milliseconds() + 1000),
-		Sequence:  1,
+	exists := func(id int32) bool {
+		_, exists := gs.Players.Load(id)
+		return exists
 	}
 
-	gs.Players.Store(activePlayer.ID, activePlayer)
-	gs.Players.Store(inactivePlayer.ID, inactivePlayer)
-	gs.Clients.Store(activePlayer.ID, &net.UDPAddr{})
-	gs.Clients.Store(inactivePlayer.ID, &net.UDPAddr{})
-	gs.SequenceNumbers.Store(activePlayer.ID, activePlayer.Sequence)
-	gs.SequenceNumbers.Store(inactivePlayer.ID, inactivePlayer.Sequence)
+	send(1, -time.Hour)
+	send(2, time.Hour)
 
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
 	go func() {
-		gs.MonitorDisconnections()
-		time.Sleep(DisconnectTimer + 100*time.Millisecond)
+		defer close(done)
+		gs.Run(ctx, conn, func() int { return 1 }, nil)
+	}()
+	defer func() {
+		cancel()
+		<-done
 	}()
 
-	activePlayer.Timestamp = time.Now().UnixMilli()
-	bytePlayer, err := player.SerializePlayer(activePlayer)
-	assert.NoError(t, err)
-	gs.HandleClient(conn, addr, bytePlayer)
-
-	time.Sleep(DisconnectTimer + 200*time.Millisecond)
-
-	_, activeExists := gs.Players.Load(activePlayer.ID)
-	assert.True(t, activeExists, "Active player should still exist")
+	// The loop ticks every second and looks for silent players every
+	// DisconnectTimer, at 5s and 10s.
+	waitForClock(t, clk, 1)
+	for second := 1; second <= 10; second++ {
+		clk.Advance(time.Second)
+		waitForClock(t, clk, 1)
+		if second == 1 {
+			assert.True(t, exists(1) && exists(2))
+		}
+		if second == 3 || second == 8 {
+			send(1, -time.Hour)
+		}
+	}
+	assert.False(t, exists(2), "Inactive player should be removed")
+	assert.True(t, exists(1), "Active player should still exist")
 
-	_, inactiveExists := gs.Players.Load(inactivePlayer.ID)
-	assert.False(t, inactiveExists, "Inactive player should be removed")
+	for range 5 {
+		clk.Advance(time.Second)
+		waitForClock(t, clk, 1)
+	}
+	assert.False(t, exists(1), "Player should be removed once it goes silent")
 }
 
 func TestBroadcastWithFailedWrite(t *testing.T) {
@@ -150,6 +173,7 @@ func TestHandleClientSequenceNumberHandling(t *testing.T) {
 
 	initialData, _ := player.SerializePlayer(initialPlayer)
 	gs.HandleClient(conn, addr, initialData)
+	gs.Step(conn)
 
 	storedSeq, _ := gs.SequenceNumbers.Load(initialPlayer.ID)
 	assert.Equal(t, uint32(5), storedSeq.(uint32))
@@ -160,6 +184,7 @@ func TestHandleClientSequenceNumberHandling(t *testing.T) {
 
 	oldData, _ := player.SerializePlayer(oldPlayer)
 	gs.HandleClient(conn, addr, oldData)
+	gs.Step(conn)
 
 	storedSeq, _ = gs.SequenceNumbers.Load(initialPlayer.ID)
 	assert.Equal(t, uint32(5), storedSeq.(uint32))
@@ -174,6 +199,7 @@ func TestHandleClientSequenceNumberHandling(t *testing.T) {
 
 	newData, _ := player.SerializePlayer(newPlayer)
 	gs.HandleClient(conn, addr, newData)
+	gs.Step(conn)
 
 	storedSeq, _ = gs.SequenceNumbers.Load(initialPlayer.ID)
 	assert.Equal(t, uint32(7), storedSeq.(uint32))
@@ -186,11 +212,135 @@ func TestHandleClientSequenceNumberHandling(t *testing.T) {
 // Mock UDP connection for testing
 type mockUDPConn struct {
 	shouldFailWrite bool
+	written         [][]byte
 }
 
 func (m *mockUDPConn) WriteToUDP(b []byte, addr *net.UDPAddr) (int, error) {
 	if m.shouldFailWrite {
 		return 0, net.ErrClosed
 	}
+	m.written = append(m.written, append([]byte(nil), b...))
 	return len(b), nil
 }
+
+func TestHandleClientHello(t *testing.T) {
+	gs := New(WithWorld(world.New(20, 10)))
+	conn := &mockUDPConn{}
+	addr := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 9000}
+
+	hello, err := protocol.Encode(protocol.MsgHello, protocol.Hello{PlayerID: 4})
+	assert.NoError(t, err)
+
+	gs.HandleClient(conn, addr, hello)
+
+	assert.Len(t, conn.written, 2)
+	var welcome protocol.Welcome
+	assert.NoError(t, protocol.Decode(conn.written[0], protocol.MsgWelcome, &welcome))
+	assert.Equal(t, protocol.Welcome{PlayerID: 4, Width: 20, Height: 10, SpawnX: 10, SpawnY: 5}, welcome)
+
+	chunk, tiles, err := protocol.DecodeMapChunk(conn.written[1])
+	assert.NoError(t, err)
+	assert.Equal(t, protocol.MapChunk{StartRow: 0, Rows: 10, Width: 20}, chunk)
+	assert.Equal(t, byte(world.TileWall), tiles[0])
+	assert.Equal(t, byte(world.TileFloor), tiles[20+1])
+}
+
+func TestHandleClientHelloSplitsLargeMaps(t *testing.T) {
+	gs := New(WithWorld(world.New(400, 7)))
+	conn := &mockUDPConn{}
+	addr := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 9000}
+
+	hello, _ := protocol.Encode(protocol.MsgHello, protocol.Hello{PlayerID: 1})
+	gs.HandleClient(conn, addr, hello)
+
+	var rows []uint16
+	for _, data := range conn.written[1:] {
+		chunk, tiles, err := protocol.DecodeMapChunk(data)
+		assert.NoError(t, err)
+		assert.LessOrEqual(t, len(tiles), protocol.MaxChunkTiles)
+		rows = append(rows, chunk.StartRow, chunk.Rows)
+	}
+	assert.Equal(t, []uint16{0, 2, 2, 2, 4, 2, 6, 1}, rows)
+}
+
+func TestHandleClientRejectsWalls(t *testing.T) {
+	m, err := world.ParseText(strings.NewReader("#####\n#S#.#\n#####"))
+	assert.NoError(t, err)
+
+	gs := New(WithWorld(m))
+	conn := &mockUDPConn{}
+	addr := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 9000}
+
+	wall := player.Player{ID: 1, X: 2, Y: 1, Timestamp: time.Now().UnixMilli(), Sequence: 1}
+	data, _ := player.SerializePlayer(wall)
+	gs.HandleClient(conn, addr, data)
+	gs.Step(conn)
+
+	_, exists := gs.Players.Load(wall.ID)
+	assert.False(t, exists)
+
+	assert.Len(t, conn.written, 1)
+	var correction protocol.Correction
+	assert.NoError(t, protocol.Decode(conn.written[0], protocol.MsgCorrection, &correction))
+	assert.Equal(t, protocol.ReasonWall, correction.Reason)
+	assert.Equal(t, float32(1), correction.X, "unknown players are corrected to their spawn")
+}
+
+func TestHandleClientRejectsOutOfBounds(t *testing.T) {
+	gs := New(WithWorld(world.New(20, 10)))
+	conn := &mockUDPConn{}
+	addr := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 9000}
+
+	inside := player.Player{ID: 1, X: 3, Y: 3, Timestamp: time.Now().UnixMilli(), Sequence: 1}
+	data, _ := player.SerializePlayer(inside)
+	gs.HandleClient(conn, addr, data)
+	gs.Step(conn)
+
+	outside := inside
+	outside.X = 25
+	outside.Sequence = 2
+	data, _ = player.SerializePlayer(outside)
+	gs.HandleClient(conn, addr, data)
+	gs.Step(conn)
+
+	stored, _ := gs.Players.Load(inside.ID)
+	assert.Equal(t, inside.X, stored.(player.Player).X, "out of bounds move must not be applied")
+
+	assert.Len(t, conn.written, 1)
+	var correction protocol.Correction
+	assert.NoError(t, protocol.Decode(conn.written[0], protocol.MsgCorrection, &correction))
+	assert.Equal(t, protocol.ReasonOutOfBounds, correction.Reason)
+	assert.Equal(t, inside.X, correction.X)
+	assert.Equal(t, uint32(2), correction.Sequence)
+}
+
+func TestTeleport(t *testing.T) {
+	gs := New(WithWorld(world.New(20, 10)))
+	conn := &mockUDPConn{}
+	addr := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 9000}
+
+	assert.ErrorIs(t, gs.Teleport(1, 5, 5), ErrUnknownPlayer)
+
+	p := player.Player{ID: 1, X: 3, Y: 3, Timestamp: time.Now().UnixMilli(), Sequence: 1}
+	data, _ := player.SerializePlayer(p)
+	gs.HandleClient(conn, addr, data)
+	gs.Step(conn)
+
+	assert.ErrorIs(t, gs.Teleport(1, 0, 0), ErrNotWalkable, "the border is a wall")
+	assert.NoError(t, gs.Teleport(1, 7, 4))
+
+	p.X, p.Sequence = 4, 2
+	data, _ = player.SerializePlayer(p)
+	gs.HandleClient(conn, addr, data)
+	gs.Step(conn)
+
+	stored, _ := gs.Players.Load(p.ID)
+	assert.Equal(t, float32(7), stored.(player.Player).X, "teleports override moves of the same tick")
+	assert.Equal(t, float32(4), stored.(player.Player).Y)
+
+	assert.Len(t, conn.written, 1)
+	var correction protocol.Correction
+	assert.NoError(t, protocol.Decode(conn.written[0], protocol.MsgCorrection, &correction))
+	assert.Equal(t, protocol.ReasonTeleported, correction.Reason)
+	assert.Equal(t, float32(7), correction.X)
+}
@@ -0,0 +1,135 @@
+package game
+
+import (
+	"math"
+	"net"
+	"time"
+
+	"github.com/zainokta/client-server-multiplayer/server/player"
+	"github.com/zainokta/client-server-multiplayer/shared/protocol"
+)
+
+// MovementRules bounds how far players may move and what happens to players
+// that keep breaking the bounds.
+type MovementRules struct {
+	// MaxSpeed is how many cells per second a player may move on average.
+	// Zero disables the speed check.
+	MaxSpeed float64
+	// Burst is how many cells a player may move at once after standing still.
+	Burst float64
+	// Every move that is too fast or out of bounds adds a point to the
+	// violation score of the player, which drops by Decay points per second.
+	// Reaching WarnScore is logged as a warning and reaching KickScore kicks
+	// the player. A score of zero disables the warning or the kick.
+	WarnScore float64
+	KickScore float64
+	Decay     float64
+}
+
+// WithMovementRules validates moves against rules. The addresses of clients
+// reaching the kick score are passed to kick after the Step.
+func WithMovementRules(rules MovementRules, kick func(addr *net.UDPAddr)) Option {
+	return func(g *GameState) {
+		g.rules = rules
+		g.kick = kick
+	}
+}
+
+// movement tracks how far the player of a client may still move and the
+// violation score of the client. Both refill or drain over time like a token
+// bucket. Movements are kept per client address, so a client can only spend
+// its own allowance and raise its own score.
+type movement struct {
+	player    int32
+	allowance float64
+	score     float64
+	at        time.Time
+	warned    bool
+}
+
+// movementOf returns the movement of the client at addr brought up to now.
+// The caller holds movementsMu.
+func (g *GameState) movementOf(addr *net.UDPAddr, id int32, now time.Time) *movement {
+	m, exists := g.movements[addr.String()]
+	if !exists {
+		m = &movement{player: id, allowance: g.rules.Burst, at: now}
+		g.movements[addr.String()] = m
+	}
+
+	elapsed := now.Sub(m.at).Seconds()
+	m.allowance = min(g.rules.Burst, m.allowance+elapsed*g.rules.MaxSpeed)
+	m.score = max(0, m.score-elapsed*g.rules.Decay)
+	m.at = now
+	if g.rules.WarnScore <= 0 || m.score < g.rules.WarnScore {
+		m.warned = false
+	}
+	return m
+}
+
+// tooFast reports whether moving from last to gamePlayer exceeds the
+// allowance of the client at addr. The allowance is only spent by spend, once
+// the move is accepted.
+func (g *GameState) tooFast(addr *net.UDPAddr, last, gamePlayer player.Player, now time.Time) bool {
+	if g.rules.MaxSpeed <= 0 {
+		return false
+	}
+
+	g.movementsMu.Lock()
+	defer g.movementsMu.Unlock()
+
+	m := g.movementOf(addr, gamePlayer.ID, now)
+	return distance(last, gamePlayer) > m.allowance
+}
+
+// spend takes an accepted move from last to gamePlayer off the allowance of
+// the client at addr.
+func (g *GameState) spend(addr *net.UDPAddr, last, gamePlayer player.Player, now time.Time) {
+	if g.rules.MaxSpeed <= 0 {
+		return
+	}
+
+	g.movementsMu.Lock()
+	defer g.movementsMu.Unlock()
+
+	m := g.movementOf(addr, gamePlayer.ID, now)
+	m.allowance = max(0, m.allowance-distance(last, gamePlayer))
+}
+
+func distance(a, b player.Player) float64 {
+	return math.Hypot(float64(b.X-a.X), float64(b.Y-a.Y))
+}
+
+// violate adds a violation to the score of the client at addr. It reports
+// whether the client is to be kicked.
+func (g *GameState) violate(addr *net.UDPAddr, gamePlayer player.Player, reason protocol.CorrectionReason, now time.Time) bool {
+	g.movementsMu.Lock()
+	defer g.movementsMu.Unlock()
+
+	m := g.movementOf(addr, gamePlayer.ID, now)
+	m.score++
+
+	log := g.log.With("player", gamePlayer.ID, "addr", addr.String(), "reason", reason.String(), "score", m.score)
+	log.Info("Invalid movement", "x", gamePlayer.X, "y", gamePlayer.Y)
+
+	if g.rules.KickScore > 0 && m.score >= g.rules.KickScore {
+		log.Warn("Kicking player for invalid movement")
+		return true
+	}
+	if g.rules.WarnScore > 0 && m.score >= g.rules.WarnScore && !m.warned {
+		m.warned = true
+		log.Warn("Player keeps moving invalidly")
+	}
+	return false
+}
+
+// ViolationScore returns the current violation score of the client at addr.
+func (g *GameState) ViolationScore(addr *net.UDPAddr) float64 {
+	g.movementsMu.Lock()
+	defer g.movementsMu.Unlock()
+
+	m, exists := g.movements[addr.String()]
+	if !exists {
+		return 0
+	}
+	return max(0, m.score-g.clock.Since(m.at).Seconds()*g.rules.Decay)
+}
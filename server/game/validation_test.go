@@ -0,0 +1,154 @@
+package game
+
+import (
+	"bytes"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zainokta/client-server-multiplayer/server/player"
+	"github.com/zainokta/client-server-multiplayer/server/world"
+	"github.com/zainokta/client-server-multiplayer/shared/clock"
+	"github.com/zainokta/client-server-multiplayer/shared/protocol"
+)
+
+func TestMoveTooFast(t *testing.T) {
+	clk := clock.NewFake(time.Unix(1000, 0))
+	gs := New(WithMovementRules(MovementRules{MaxSpeed: 10, Burst: 2}, nil), WithClock(clk))
+	conn := &mockUDPConn{}
+	place(gs, 1, 5, 5)
+
+	move(gs, conn, 1, 9, 5, 2)
+	gs.Step(conn)
+
+	x, _ := position(gs, 1)
+	assert.Equal(t, float32(5), x)
+	assert.Equal(t, []protocol.Correction{{PlayerID: 1, X: 5, Y: 5, Sequence: 2, Reason: protocol.ReasonTooFast}}, corrections(t, conn))
+	assert.InDelta(t, 1, gs.ViolationScore(addrFor(1)), 0.01)
+
+	move(gs, conn, 1, 6, 5, 3)
+	gs.Step(conn)
+	move(gs, conn, 1, 7, 5, 4)
+	gs.Step(conn)
+	x, _ = position(gs, 1)
+	assert.Equal(t, float32(7), x, "moves within the burst are accepted")
+
+	move(gs, conn, 1, 8, 5, 5)
+	gs.Step(conn)
+	x, _ = position(gs, 1)
+	assert.Equal(t, float32(7), x, "the burst is spent")
+
+	clk.Advance(200 * time.Millisecond)
+	move(gs, conn, 1, 8, 5, 6)
+	gs.Step(conn)
+	x, _ = position(gs, 1)
+	assert.Equal(t, float32(8), x, "the allowance refills over time")
+}
+
+func TestViolationsWarnAndKick(t *testing.T) {
+	var out bytes.Buffer
+	var kicked []string
+	gs := New(
+		WithWorld(world.New(10, 10)),
+		WithMovementRules(MovementRules{WarnScore: 2, KickScore: 4}, func(addr *net.UDPAddr) { kicked = append(kicked, addr.String()) }),
+		WithLogger(slog.New(slog.NewTextHandler(&out, nil))),
+	)
+	conn := &mockUDPConn{}
+	place(gs, 1, 5, 5)
+
+	for seq := uint32(2); seq <= 4; seq++ {
+		move(gs, conn, 1, 20, 5, seq)
+		gs.Step(conn)
+	}
+	assert.Len(t, corrections(t, conn), 3)
+	assert.Empty(t, kicked)
+	assert.Equal(t, 3, strings.Count(out.String(), "Invalid movement"))
+	assert.Equal(t, 1, strings.Count(out.String(), "Player keeps moving invalidly"), "the warning is logged once")
+
+	move(gs, conn, 1, -1, 5, 5)
+	gs.Step(conn)
+	assert.Equal(t, []string{addrFor(1).String()}, kicked)
+
+	gs.RemovePlayer(1)
+	assert.Zero(t, gs.ViolationScore(addrFor(1)))
+}
+
+func TestViolationScoreDecays(t *testing.T) {
+	clk := clock.NewFake(time.Unix(1000, 0))
+	gs := New(WithWorld(world.New(10, 10)), WithMovementRules(MovementRules{Decay: 1}, nil), WithClock(clk))
+	conn := &mockUDPConn{}
+	place(gs, 1, 5, 5)
+
+	move(gs, conn, 1, 20, 5, 2)
+	gs.Step(conn)
+	assert.InDelta(t, 1, gs.ViolationScore(addrFor(1)), 0.01)
+
+	clk.Advance(500 * time.Millisecond)
+	assert.Equal(t, 0.5, gs.ViolationScore(addrFor(1)))
+	clk.Advance(time.Second)
+	assert.Zero(t, gs.ViolationScore(addrFor(1)))
+}
+
+func TestFirstMoveStartsFromSpawn(t *testing.T) {
+	gs := New(WithWorld(world.New(20, 10)), WithMovementRules(MovementRules{MaxSpeed: 10, Burst: 2}, nil))
+	conn := &mockUDPConn{}
+
+	move(gs, conn, 1, 2, 2, 1)
+	gs.Step(conn)
+
+	_, exists := gs.Players.Load(int32(1))
+	assert.False(t, exists, "players cannot appear far from their spawn")
+	assert.Equal(t, []protocol.Correction{{PlayerID: 1, X: 10, Y: 5, Sequence: 1, Reason: protocol.ReasonTooFast}}, corrections(t, conn))
+
+	move(gs, conn, 1, 11, 5, 2)
+	gs.Step(conn)
+	x, y := position(gs, 1)
+	assert.Equal(t, float32(11), x)
+	assert.Equal(t, float32(5), y)
+}
+
+func TestRejectedMovesKeepTheAllowance(t *testing.T) {
+	clk := clock.NewFake(time.Unix(1000, 0))
+	gs := New(
+		WithWorld(world.New(20, 10)),
+		WithMode(Mode{Collision: CollisionBlock}),
+		WithMovementRules(MovementRules{MaxSpeed: 1, Burst: 2}, nil),
+		WithClock(clk),
+	)
+	conn := &mockUDPConn{}
+	place(gs, 1, 5, 5)
+	place(gs, 2, 7, 5)
+
+	move(gs, conn, 1, 7, 5, 2)
+	gs.Step(conn)
+	assert.Equal(t, protocol.ReasonOccupied, corrections(t, conn)[0].Reason)
+
+	move(gs, conn, 1, 5, 7, 3)
+	gs.Step(conn)
+	_, y := position(gs, 1)
+	assert.Equal(t, float32(7), y, "the blocked move did not use up the burst")
+	assert.Len(t, corrections(t, conn), 1)
+}
+
+func TestViolationsBelongToTheSender(t *testing.T) {
+	var kicked []string
+	gs := New(
+		WithWorld(world.New(10, 10)),
+		WithMovementRules(MovementRules{KickScore: 1}, func(addr *net.UDPAddr) { kicked = append(kicked, addr.String()) }),
+	)
+	conn := &mockUDPConn{}
+	place(gs, 2, 5, 5)
+
+	data, _ := player.SerializePlayer(player.Player{ID: 2, X: 40, Y: 5, Sequence: 2})
+	gs.HandleClient(conn, addrFor(1), data)
+	gs.Step(conn)
+
+	assert.Empty(t, kicked, "clients cannot move the player of another client")
+	assert.Empty(t, corrections(t, conn))
+	assert.Zero(t, gs.ViolationScore(addrFor(2)))
+	x, _ := position(gs, 2)
+	assert.Equal(t, float32(5), x)
+}
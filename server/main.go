@@ -1,56 +1,59 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
-	"net"
-	"time"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/caarlos0/env/v11"
 	_ "github.com/joho/godotenv/autoload"
 	"github.com/zainokta/client-server-multiplayer/server/config"
-	"github.com/zainokta/client-server-multiplayer/server/game"
+	"github.com/zainokta/client-server-multiplayer/server/console"
+	"github.com/zainokta/client-server-multiplayer/server/server"
+	"github.com/zainokta/client-server-multiplayer/shared/logging"
 )
 
-func startUDPServer(cfg config.Config) {
-	addr := net.UDPAddr{Port: cfg.Port, IP: net.ParseIP("127.0.0.1")}
-	conn, err := net.ListenUDP("udp", &addr)
+func main() {
+	cfg, err := env.ParseAs[config.Config]()
 	if err != nil {
-		log.Fatal(err)
+		fmt.Printf("%+v\n", err)
 	}
-	defer conn.Close()
-
-	fmt.Printf("UDP Server listening on port %d\n", cfg.Port)
 
-	gameState := game.New()
-
-	go gameState.MonitorDisconnections()
-
-	ticker := time.NewTicker(time.Second / time.Duration(cfg.GameTickRate))
+	logger, err := logging.New(os.Stderr, cfg.LogLevel, cfg.LogFormat)
+	if err != nil {
+		log.Fatal(err)
+	}
+	slog.SetDefault(logger)
 
-	go func() {
-		for range ticker.C {
-			gameState.Broadcast(conn)
-		}
-	}()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	for {
-		buf := make([]byte, 1024)
-		n, addr, err := conn.ReadFromUDP(buf)
-		if err != nil {
-			log.Println("Error reading:", err)
-			continue
-		}
+	srv := server.New(cfg, server.WithLogger(logger))
+	if err := srv.Start(ctx); err != nil {
+		logger.Error("Failed to start server", "err", err)
+		os.Exit(1)
+	}
 
-		go gameState.HandleClient(conn, addr, buf[:n])
+	logger.Info("UDP server listening", "addr", srv.Addr().String())
+	if addr := srv.AdminAddr(); addr != nil {
+		logger.Info("Admin API listening", "url", "http://"+addr.String())
+	}
+	if addr := srv.MetricsAddr(); addr != nil {
+		logger.Info("Metrics available", "url", "http://"+addr.String()+"/metrics")
 	}
-}
 
-func main() {
-	cfg, err := env.ParseAs[config.Config]()
-	if err != nil {
-		fmt.Printf("%+v\n", err)
+	if cfg.Console {
+		fmt.Println("Admin console ready, type help for the list of commands")
+		go console.New(srv.Rooms(), os.Stdin, os.Stdout).Run(ctx)
 	}
 
-	startUDPServer(cfg)
+	<-ctx.Done()
+
+	if err := srv.Stop(); err != nil {
+		logger.Error("Failed to stop server", "err", err)
+	}
 }
@@ -1,6 +1,7 @@
 package main_test
 
 import (
+	"context"
 	"net"
 	"testing"
 	"time"
@@ -9,10 +10,14 @@ import (
 	"github.com/zainokta/client-server-multiplayer/server/config"
 	"github.com/zainokta/client-server-multiplayer/server/game"
 	"github.com/zainokta/client-server-multiplayer/server/player"
+	"github.com/zainokta/client-server-multiplayer/server/server"
+	"github.com/zainokta/client-server-multiplayer/shared/clock"
+	"github.com/zainokta/client-server-multiplayer/shared/protocol"
 )
 
 func TestGameStateHandleClient(t *testing.T) {
-	gameState := game.New()
+	clk := clock.NewFake(time.Unix(1_700_000_000, 0))
+	gameState := game.New(game.WithClock(clk))
 
 	conn := &mockUDPConn{}
 	addr := &net.UDPAddr{
@@ -24,7 +29,7 @@ func TestGameStateHandleClient(t *testing.T) {
 		ID:        1,
 		X:         100.5,
 		Y:         200.75,
-		Timestamp: time.Now().UnixMilli(),
+		Timestamp: clk.Now().UnixMilli(),
 		Sequence:  1,
 	}
 
@@ -32,6 +37,7 @@ func TestGameStateHandleClient(t *testing.T) {
 	assert.NoError(t, err)
 
 	gameState.HandleClient(conn, addr, data)
+	gameState.Step(conn)
 
 	storedPlayerInterface, exists := gameState.Players.Load(testPlayer.ID)
 	assert.True(t, exists)
@@ -55,6 +61,7 @@ func TestGameStateHandleClient(t *testing.T) {
 	assert.NoError(t, err)
 
 	gameState.HandleClient(conn, addr, oldData)
+	gameState.Step(conn)
 
 	storedSeqInterface, _ = gameState.SequenceNumbers.Load(testPlayer.ID)
 	assert.Equal(t, uint32(1), storedSeqInterface)
@@ -66,6 +73,7 @@ func TestGameStateHandleClient(t *testing.T) {
 	assert.NoError(t, err)
 
 	gameState.HandleClient(conn, addr, newData)
+	gameState.Step(conn)
 
 	storedSeqInterface, _ = gameState.SequenceNumbers.Load(testPlayer.ID)
 	assert.Equal(t, uint32(2), storedSeqInterface)
@@ -76,12 +84,13 @@ func TestGameStateHandleClient(t *testing.T) {
 }
 
 func TestGameStateBroadcast(t *testing.T) {
-	gameState := game.New()
+	clk := clock.NewFake(time.Unix(1_700_000_000, 0))
+	gameState := game.New(game.WithClock(clk))
 
 	mockConn := &mockUDPConn{}
 
-	player1 := player.Player{ID: 1, X: 100, Y: 200, Timestamp: time.Now().UnixMilli(), Sequence: 1}
-	player2 := player.Player{ID: 2, X: 300, Y: 400, Timestamp: time.Now().UnixMilli(), Sequence: 1}
+	player1 := player.Player{ID: 1, X: 100, Y: 200, Timestamp: clk.Now().UnixMilli(), Sequence: 1}
+	player2 := player.Player{ID: 2, X: 300, Y: 400, Timestamp: clk.Now().UnixMilli(), Sequence: 1}
 
 	addr1 := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 9001}
 	addr2 := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 9002}
@@ -98,43 +107,109 @@ func TestGameStateBroadcast(t *testing.T) {
 
 func TestUDPServerSetup(t *testing.T) {
 	cfg := config.Config{
-		Port:         9000,
+		Port:         0,
 		GameTickRate: 10,
+		WorldWidth:   20,
+		WorldHeight:  10,
 	}
 
-	ready := make(chan struct{})
+	clk := clock.NewFake(time.Unix(1_700_000_000, 0))
+	srv := server.New(cfg, server.WithClock(clk))
+	err := srv.Start(context.Background())
+	assert.NoError(t, err)
+	defer srv.Stop()
 
-	go func() {
-		addr := net.UDPAddr{Port: cfg.Port, IP: net.ParseIP("127.0.0.1")}
-		conn, err := net.ListenUDP("udp", &addr)
+	clientConn, err := net.DialUDP("udp", nil, srv.Addr().(*net.UDPAddr))
+	assert.NoError(t, err)
+	defer clientConn.Close()
 
-		if err != nil {
-			t.Errorf("Failed to start UDP server: %v", err)
-			return
-		}
+	clientConn.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 1024)
 
-		close(ready)
+	name, err := protocol.ParseName("tester")
+	assert.NoError(t, err)
+	hello, err := protocol.Encode(protocol.MsgHello, protocol.Hello{PlayerID: 1, Name: name})
+	assert.NoError(t, err)
+	_, err = clientConn.Write(hello)
+	assert.NoError(t, err)
 
-		time.Sleep(100 * time.Millisecond)
-		conn.Close()
-	}()
+	n := readMessage(t, clientConn, buf, protocol.MsgReliable)
+	seq, inner, err := protocol.DecodeReliable(buf[:n])
+	assert.NoError(t, err)
 
-	select {
-	case <-ready:
-	case <-time.After(200 * time.Millisecond):
-		t.Fatal("Server failed to start in time")
-	}
+	var info protocol.PlayerInfo
+	assert.NoError(t, protocol.Decode(inner, protocol.MsgPlayerInfo, &info))
+	assert.Equal(t, name, info.Name, "the player is introduced to itself")
 
-	clientAddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:9000")
+	ack, err := protocol.Encode(protocol.MsgAck, protocol.Ack{Seq: seq})
 	assert.NoError(t, err)
+	_, err = clientConn.Write(ack)
+	assert.NoError(t, err)
+
+	n = readMessage(t, clientConn, buf, protocol.MsgWelcome)
+
+	var welcome protocol.Welcome
+	assert.NoError(t, protocol.Decode(buf[:n], protocol.MsgWelcome, &welcome))
+	assert.Equal(t, uint16(cfg.WorldWidth), welcome.Width)
+	assert.Equal(t, uint16(cfg.WorldHeight), welcome.Height)
+
+	n = readMessage(t, clientConn, buf, protocol.MsgMapChunk)
 
-	clientConn, err := net.DialUDP("udp", nil, clientAddr)
-	if err != nil {
-		// If this fails, it might be because the server already closed the connection
-		// This is expected in a test environment, so we don't fail the test
-		return
+	chunk, _, err := protocol.DecodeMapChunk(buf[:n])
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(cfg.WorldHeight), chunk.Rows)
+
+	testPlayer := player.Player{ID: 1, X: welcome.SpawnX, Y: welcome.SpawnY, Timestamp: clk.Now().UnixMilli(), Sequence: 1}
+	data, err := player.SerializePlayer(testPlayer)
+	assert.NoError(t, err)
+
+	_, err = clientConn.Write(data)
+	assert.NoError(t, err)
+
+	n = readTicking(t, clientConn, clk, buf, protocol.MsgEntityEnter)
+
+	var entered player.Player
+	assert.NoError(t, protocol.Decode(buf[:n], protocol.MsgEntityEnter, &entered), "the player first enters its own view")
+	assert.Equal(t, testPlayer.ID, entered.ID)
+
+	n = readTicking(t, clientConn, clk, buf, protocol.MsgPlayerUpdate)
+
+	received, err := player.DeserializePlayer(buf[:n])
+	assert.NoError(t, err)
+	assert.Equal(t, testPlayer.ID, received.ID)
+}
+
+// readMessage reads packets until one of msgType arrives.
+func readMessage(t *testing.T, conn *net.UDPConn, buf []byte, msgType protocol.MsgType) int {
+	for {
+		n, err := conn.Read(buf)
+		if !assert.NoError(t, err) {
+			return 0
+		}
+		if got, _ := protocol.Type(buf[:n]); got == msgType {
+			return n
+		}
 	}
-	defer clientConn.Close()
+}
+
+// readTicking advances clk one tick at a time until a packet of msgType
+// arrives, as the game loops only step when their clock moves.
+func readTicking(t *testing.T, conn *net.UDPConn, clk *clock.Fake, buf []byte, msgType protocol.MsgType) int {
+	for range 50 {
+		clk.Advance(100 * time.Millisecond)
+		conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				break
+			}
+			if got, _ := protocol.Type(buf[:n]); got == msgType {
+				return n
+			}
+		}
+	}
+	t.Fatalf("no %s arrived", msgType)
+	return 0
 }
 
 type mockUDPConn struct {
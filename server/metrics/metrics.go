@@ -0,0 +1,269 @@
+// Package metrics counts what the server does and renders the counts in the
+// Prometheus text exposition format. It is small on purpose: counters with a
+// single label, gauges read on every scrape and fixed-bucket histograms.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/zainokta/client-server-multiplayer/shared/protocol"
+)
+
+// Drop reasons label the packets the server ignores.
+const (
+	DropEmpty       = "empty"
+	DropOutdated    = "outdated"
+	DropNoSession   = "no_session"
+	DropBanned      = "banned"
+	DropUnknownType = "unknown_type"
+	DropWrongPlayer = "wrong_player"
+)
+
+// TickBuckets are the upper bounds in seconds of the tick and broadcast
+// duration histograms.
+var TickBuckets = []float64{0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1}
+
+type collector interface {
+	write(w io.Writer)
+}
+
+// Metrics holds every server metric. A nil *Metrics records nothing, so
+// packages can be used without metrics, for instance in tests.
+type Metrics struct {
+	packetsIn         *counterVec
+	bytesIn           *counterVec
+	packetsOut        *counterVec
+	bytesOut          *counterVec
+	dropped           *counterVec
+	decodeFailures    *counterVec
+	tickDuration      *histogram
+	broadcastDuration *histogram
+
+	mu         sync.Mutex
+	collectors []collector
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		packetsIn:         newCounterVec("game_packets_received_total", "Packets received by message type.", "type"),
+		bytesIn:           newCounterVec("game_bytes_received_total", "Bytes received by message type.", "type"),
+		packetsOut:        newCounterVec("game_packets_sent_total", "Packets sent by message type.", "type"),
+		bytesOut:          newCounterVec("game_bytes_sent_total", "Bytes sent by message type.", "type"),
+		dropped:           newCounterVec("game_packets_dropped_total", "Packets ignored by the server by reason.", "reason"),
+		decodeFailures:    newCounterVec("game_decode_failures_total", "Packets that failed to decode by message type.", "type"),
+		tickDuration:      newHistogram("game_tick_duration_seconds", "Time a room takes to step and broadcast one tick.", TickBuckets),
+		broadcastDuration: newHistogram("game_broadcast_duration_seconds", "Time a room takes to send one tick to all its players.", TickBuckets),
+	}
+	m.collectors = []collector{
+		m.packetsIn, m.bytesIn, m.packetsOut, m.bytesOut,
+		m.dropped, m.decodeFailures, m.tickDuration, m.broadcastDuration,
+	}
+	m.Gauge("game_goroutines", "Goroutines currently running.", func() float64 {
+		return float64(runtime.NumGoroutine())
+	})
+	return m
+}
+
+// Gauge registers a gauge whose value is read from fn on every scrape.
+func (m *Metrics) Gauge(name, help string, fn func() float64) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.collectors = append(m.collectors, gauge{name: name, help: help, fn: fn})
+}
+
+// Received counts an incoming packet. Its type is read from its first byte.
+func (m *Metrics) Received(data []byte) {
+	if m == nil {
+		return
+	}
+	label := packetType(data)
+	m.packetsIn.add(label, 1)
+	m.bytesIn.add(label, uint64(len(data)))
+}
+
+// Sent counts an outgoing packet.
+func (m *Metrics) Sent(data []byte) {
+	if m == nil {
+		return
+	}
+	label := packetType(data)
+	m.packetsOut.add(label, 1)
+	m.bytesOut.add(label, uint64(len(data)))
+}
+
+// Dropped counts a packet the server ignored for the given reason.
+func (m *Metrics) Dropped(reason string) {
+	if m == nil {
+		return
+	}
+	m.dropped.add(reason, 1)
+}
+
+// DecodeFailed counts a packet of msgType that could not be decoded.
+func (m *Metrics) DecodeFailed(msgType protocol.MsgType) {
+	if m == nil {
+		return
+	}
+	m.decodeFailures.add(msgType.String(), 1)
+}
+
+func (m *Metrics) ObserveTick(d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.tickDuration.observe(d.Seconds())
+}
+
+func (m *Metrics) ObserveBroadcast(d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.broadcastDuration.observe(d.Seconds())
+}
+
+// Write renders every metric in the Prometheus text format.
+func (m *Metrics) Write(w io.Writer) {
+	m.mu.Lock()
+	collectors := append([]collector(nil), m.collectors...)
+	m.mu.Unlock()
+
+	for _, c := range collectors {
+		c.write(w)
+	}
+}
+
+// Handler serves the metrics for Prometheus to scrape.
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		m.Write(w)
+	})
+}
+
+// UDPConn is the part of a UDP connection the server writes with.
+type UDPConn interface {
+	WriteToUDP(b []byte, addr *net.UDPAddr) (int, error)
+}
+
+type countingConn struct {
+	UDPConn
+	metrics *Metrics
+}
+
+// Conn wraps conn so that every packet written through it is counted.
+func Conn(conn UDPConn, m *Metrics) UDPConn {
+	return countingConn{UDPConn: conn, metrics: m}
+}
+
+func (c countingConn) WriteToUDP(b []byte, addr *net.UDPAddr) (int, error) {
+	n, err := c.UDPConn.WriteToUDP(b, addr)
+	if err == nil {
+		c.metrics.Sent(b)
+	}
+	return n, err
+}
+
+func packetType(data []byte) string {
+	msgType, err := protocol.Type(data)
+	if err != nil {
+		return "empty"
+	}
+	return msgType.String()
+}
+
+type counterVec struct {
+	name, help, label string
+
+	mu     sync.Mutex
+	values map[string]uint64
+}
+
+func newCounterVec(name, help, label string) *counterVec {
+	return &counterVec{name: name, help: help, label: label, values: make(map[string]uint64)}
+}
+
+func (c *counterVec) add(value string, n uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.values[value] += n
+}
+
+func (c *counterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	values := make([]string, 0, len(c.values))
+	for value := range c.values {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	for _, value := range values {
+		fmt.Fprintf(w, "%s{%s=%q} %d\n", c.name, c.label, value, c.values[value])
+	}
+}
+
+type gauge struct {
+	name, help string
+	fn         func() float64
+}
+
+func (g gauge) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %s\n", g.name, g.help, g.name, g.name, formatFloat(g.fn()))
+}
+
+type histogram struct {
+	name, help string
+	bounds     []float64
+
+	mu     sync.Mutex
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func newHistogram(name, help string, bounds []float64) *histogram {
+	return &histogram{name: name, help: help, bounds: bounds, counts: make([]uint64, len(bounds))}
+}
+
+func (h *histogram) observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i, bound := range h.bounds {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+func (h *histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	for i, bound := range h.bounds {
+		fmt.Fprintf(w, "%s_bucket{le=%q} %d\n", h.name, formatFloat(bound), h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", h.name, h.count)
+	fmt.Fprintf(w, "%s_sum %s\n%s_count %d\n", h.name, formatFloat(h.sum), h.name, h.count)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
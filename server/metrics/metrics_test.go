@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zainokta/client-server-multiplayer/shared/protocol"
+)
+
+type discardConn struct{}
+
+func (discardConn) WriteToUDP(b []byte, addr *net.UDPAddr) (int, error) {
+	return len(b), nil
+}
+
+func render(m *Metrics) string {
+	var out strings.Builder
+	m.Write(&out)
+	return out.String()
+}
+
+func TestNilMetricsRecordNothing(t *testing.T) {
+	var m *Metrics
+	m.Received([]byte{byte(protocol.MsgHello)})
+	m.Dropped(DropOutdated)
+	m.DecodeFailed(protocol.MsgChat)
+	m.ObserveTick(time.Millisecond)
+	m.Gauge("game_test", "Test.", func() float64 { return 1 })
+}
+
+func TestCounters(t *testing.T) {
+	m := New()
+	conn := Conn(discardConn{}, m)
+
+	conn.WriteToUDP([]byte{byte(protocol.MsgWelcome), 0, 0}, nil)
+	conn.WriteToUDP([]byte{byte(protocol.MsgWelcome), 0}, nil)
+	m.Received([]byte{byte(protocol.MsgHello), 0, 0, 0})
+	m.Received([]byte{200})
+	m.Dropped(DropOutdated)
+	m.DecodeFailed(protocol.MsgChat)
+
+	out := render(m)
+	assert.Contains(t, out, "# TYPE game_packets_sent_total counter\n")
+	assert.Contains(t, out, `game_packets_sent_total{type="welcome"} 2`+"\n")
+	assert.Contains(t, out, `game_bytes_sent_total{type="welcome"} 5`+"\n")
+	assert.Contains(t, out, `game_bytes_received_total{type="hello"} 4`+"\n")
+	assert.Contains(t, out, `game_packets_received_total{type="unknown"} 1`+"\n")
+	assert.Contains(t, out, `game_packets_dropped_total{reason="outdated"} 1`+"\n")
+	assert.Contains(t, out, `game_decode_failures_total{type="chat"} 1`+"\n")
+}
+
+func TestHistogram(t *testing.T) {
+	m := New()
+	m.ObserveTick(300 * time.Microsecond)
+	m.ObserveTick(20 * time.Millisecond)
+	m.Gauge("game_players", "Players.", func() float64 { return 3 })
+
+	out := render(m)
+	assert.Contains(t, out, "# TYPE game_tick_duration_seconds histogram\n")
+	assert.Contains(t, out, `game_tick_duration_seconds_bucket{le="0.00025"} 0`+"\n")
+	assert.Contains(t, out, `game_tick_duration_seconds_bucket{le="0.0005"} 1`+"\n")
+	assert.Contains(t, out, `game_tick_duration_seconds_bucket{le="0.025"} 2`+"\n")
+	assert.Contains(t, out, `game_tick_duration_seconds_bucket{le="+Inf"} 2`+"\n")
+	assert.Contains(t, out, "game_tick_duration_seconds_sum 0.0203")
+	assert.Contains(t, out, "game_tick_duration_seconds_count 2\n")
+	assert.Contains(t, out, "# TYPE game_players gauge\ngame_players 3\n")
+}
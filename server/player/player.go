@@ -1,8 +1,7 @@
 package player
 
 import (
-	"bytes"
-	"encoding/binary"
+	"github.com/zainokta/client-server-multiplayer/shared/protocol"
 )
 
 type Player struct {
@@ -14,17 +13,11 @@ type Player struct {
 }
 
 func SerializePlayer(player Player) ([]byte, error) {
-	buf := new(bytes.Buffer)
-	err := binary.Write(buf, binary.LittleEndian, player)
-	if err != nil {
-		return nil, err
-	}
-	return buf.Bytes(), nil
+	return protocol.Encode(protocol.MsgPlayerUpdate, player)
 }
 
 func DeserializePlayer(data []byte) (Player, error) {
 	var gamePlayer Player
-	buf := bytes.NewReader(data)
-	err := binary.Read(buf, binary.LittleEndian, &gamePlayer)
+	err := protocol.Decode(data, protocol.MsgPlayerUpdate, &gamePlayer)
 	return gamePlayer, err
 }
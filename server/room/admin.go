@@ -0,0 +1,264 @@
+package room
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/zainokta/client-server-multiplayer/server/player"
+	"github.com/zainokta/client-server-multiplayer/shared/protocol"
+)
+
+// MaxTickRate bounds the tick rate an operator may set.
+const MaxTickRate = 1000
+
+var (
+	ErrNoSuchPlayer = errors.New("no such player")
+	ErrTickRate     = fmt.Errorf("tick rate must be between 1 and %d", MaxTickRate)
+)
+
+// PlayerStatus describes a seated player for operators.
+type PlayerStatus struct {
+	ID       int32
+	Name     string
+	Room     uint32
+	Addr     string
+	X        float32
+	Y        float32
+	LastSeen time.Time
+	// Silent is how long ago LastSeen was on the clock of the manager.
+	Silent time.Duration
+	// RTT is the smoothed round trip time, zero until the first pong.
+	RTT time.Duration
+	// Loss is the share of recent pings left unanswered, from 0 to 1.
+	Loss float64
+	// SendRate is how many snapshots per second the client is sent, zero
+	// when it gets one every tick.
+	SendRate int
+	// Violations is the movement violation score of the player.
+	Violations float64
+}
+
+// Stats summarises the server for operators.
+type Stats struct {
+	Uptime          time.Duration
+	Rooms           int
+	Players         int
+	Queued          int
+	Banned          int
+	PendingReliable int
+}
+
+// Players lists every seated player ordered by room and ID. Positions are
+// zero until the first move of a player.
+func (m *Manager) Players() []PlayerStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.clock.Now()
+	players := make([]PlayerStatus, 0, len(m.sessions))
+	for _, s := range m.sessions {
+		status := PlayerStatus{
+			ID:         s.playerID,
+			Name:       s.name.String(),
+			Room:       s.room.ID,
+			Addr:       s.addr.String(),
+			LastSeen:   s.lastSeen,
+			Silent:     now.Sub(s.lastSeen),
+			RTT:        s.latency.rtt,
+			Loss:       s.latency.loss(now),
+			SendRate:   s.room.State.SendRate(s.playerID),
+			Violations: s.room.State.ViolationScore(s.addr),
+		}
+		if value, exists := s.room.State.Players.Load(s.playerID); exists {
+			p := value.(player.Player)
+			status.X, status.Y = p.X, p.Y
+		}
+		players = append(players, status)
+	}
+
+	sort.Slice(players, func(i, j int) bool {
+		if players[i].Room != players[j].Room {
+			return players[i].Room < players[j].Room
+		}
+		return players[i].ID < players[j].ID
+	})
+	return players
+}
+
+// Kick removes the player from the room and tells its client. Player IDs are
+// only unique within a room, so the room is needed to tell players apart. The
+// client may join again.
+func (m *Manager) Kick(roomID uint32, playerID int32) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	kicked := m.disconnect(func(s *session) bool {
+		return s.room.ID == roomID && s.playerID == playerID
+	}, protocol.DisconnectKicked)
+	if kicked == 0 {
+		return ErrNoSuchPlayer
+	}
+
+	return nil
+}
+
+// Ban drops every packet from ip from now on and kicks its players. It returns
+// how many players were kicked.
+func (m *Manager) Ban(ip net.IP) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.banned[ip.String()] = struct{}{}
+	for key, p := range m.peers {
+		if p.addr.IP.Equal(ip) {
+			delete(m.peers, key)
+		}
+	}
+
+	kicked := m.disconnect(func(s *session) bool {
+		return s.addr.IP.Equal(ip)
+	}, protocol.DisconnectBanned)
+
+	m.log.Info("Banned address", "ip", ip.String(), "kicked", kicked)
+	return kicked
+}
+
+// Bans lists the banned IP addresses.
+func (m *Manager) Bans() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	bans := make([]string, 0, len(m.banned))
+	for ip := range m.banned {
+		bans = append(bans, ip)
+	}
+	sort.Strings(bans)
+	return bans
+}
+
+// ParseIP reads the IP address of addr, which may include a port as listed
+// by Players.
+func ParseIP(addr string) (net.IP, error) {
+	host := addr
+	if h, _, err := net.SplitHostPort(addr); err == nil {
+		host = h
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid address %q", addr)
+	}
+	return ip, nil
+}
+
+func (m *Manager) isBanned(ip net.IP) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, banned := m.banned[ip.String()]
+	return banned
+}
+
+// disconnect removes the sessions matching match and tells their clients why.
+// The caller holds the manager lock.
+func (m *Manager) disconnect(match func(s *session) bool, reason protocol.DisconnectReason) int {
+	disconnected := 0
+	for key, s := range m.sessions {
+		if !match(s) {
+			continue
+		}
+
+		s.log.Info("Disconnected player", "reason", reason.String())
+		m.send(s.addr, protocol.MsgDisconnect, protocol.Disconnect{Reason: reason})
+		m.leave(key, s)
+		delete(m.peers, key)
+		disconnected++
+	}
+	return disconnected
+}
+
+// expel kicks the client at addr out of the room for breaking the movement
+// rules.
+func (m *Manager) expel(roomID uint32, addr *net.UDPAddr) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.disconnect(func(s *session) bool {
+		return s.room.ID == roomID && s.addr.String() == addr.String()
+	}, protocol.DisconnectInvalidMovement)
+}
+
+// Teleport moves the player of the room to (x, y) on the next tick of the
+// room.
+func (m *Manager) Teleport(roomID uint32, playerID int32, x, y float32) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, s := range m.sessions {
+		if s.room.ID == roomID && s.playerID == playerID {
+			return s.room.State.Teleport(playerID, x, y)
+		}
+	}
+	return ErrNoSuchPlayer
+}
+
+// Announce sends a message from the server to every seated player.
+func (m *Manager) Announce(text string) error {
+	text = cleanChat(text)
+	if text == "" || len(text) > protocol.MaxChatLength {
+		return fmt.Errorf("announcement must be 1 to %d bytes", protocol.MaxChatLength)
+	}
+
+	data, err := protocol.EncodeChat(protocol.Chat{Channel: protocol.ChatAnnouncement}, text)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, s := range m.sessions {
+		m.writeReliable(s.addr, data)
+	}
+	return nil
+}
+
+// SetTickRate changes the tick rate of every room, including rooms opened
+// later.
+func (m *Manager) SetTickRate(rate int) error {
+	if rate < 1 || rate > MaxTickRate {
+		return ErrTickRate
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.tickRate = rate
+	for _, r := range m.rooms {
+		r.setTickRate(rate)
+	}
+
+	m.log.Info("Tick rate changed", "tick_rate", rate)
+	return nil
+}
+
+func (m *Manager) Stats() Stats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := Stats{
+		Uptime:  m.clock.Since(m.started),
+		Rooms:   len(m.rooms),
+		Players: len(m.sessions),
+		Banned:  len(m.banned),
+	}
+	for _, queue := range m.queues {
+		stats.Queued += len(queue)
+	}
+	for _, p := range m.peers {
+		stats.PendingReliable += p.endpoint.Pending()
+	}
+	return stats
+}
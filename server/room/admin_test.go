@@ -0,0 +1,164 @@
+package room
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zainokta/client-server-multiplayer/server/game"
+	"github.com/zainokta/client-server-multiplayer/server/player"
+	"github.com/zainokta/client-server-multiplayer/server/world"
+	"github.com/zainokta/client-server-multiplayer/shared/clock"
+	"github.com/zainokta/client-server-multiplayer/shared/protocol"
+)
+
+func disconnects(t *testing.T, conn *recordingConn, addr *net.UDPAddr) []protocol.DisconnectReason {
+	var reasons []protocol.DisconnectReason
+	for _, data := range conn.messages(addr, protocol.MsgDisconnect) {
+		var disconnect protocol.Disconnect
+		assert.NoError(t, protocol.Decode(data, protocol.MsgDisconnect, &disconnect))
+		reasons = append(reasons, disconnect.Reason)
+	}
+	return reasons
+}
+
+func TestPlayersListsPositions(t *testing.T) {
+	conn := &recordingConn{}
+	clk := clock.NewFake(time.Unix(1_700_000_000, 0))
+	m := newManager(conn, 0, WithClock(clk))
+	first := m.Create()
+	second := m.Create()
+
+	hello(t, m, addrFor(9002), 2, first.ID)
+	hello(t, m, addrFor(9001), 1, second.ID)
+	update(t, m, addrFor(9002), player.Player{ID: 2, X: 4, Y: 5, Sequence: 1})
+	first.State.Step(conn)
+	seen := clk.Now()
+	clk.Advance(3 * time.Second)
+
+	players := m.Players()
+	assert.Len(t, players, 2)
+	assert.Equal(t, PlayerStatus{ID: 2, Name: "player2", Room: first.ID, Addr: addrFor(9002).String(), X: 4, Y: 5, LastSeen: seen, Silent: 3 * time.Second}, players[0])
+	assert.Equal(t, int32(1), players[1].ID)
+	assert.Zero(t, players[1].X, "players that never moved have no position yet")
+}
+
+func TestKick(t *testing.T) {
+	conn := &recordingConn{}
+	m := newManager(conn, 0)
+	r := m.Create()
+	other := m.Create()
+	hello(t, m, addrFor(9001), 1, r.ID)
+	twin := helloFor(1, other.ID, protocol.JoinCode{})
+	twin.Name, _ = protocol.ParseName("twin")
+	_, err := m.Join(addrFor(9002), twin)
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, m.Kick(r.ID, 2), ErrNoSuchPlayer)
+	assert.ErrorIs(t, m.Kick(42, 1), ErrNoSuchPlayer)
+	assert.NoError(t, m.Kick(r.ID, 1))
+
+	assert.Equal(t, []protocol.DisconnectReason{protocol.DisconnectKicked}, disconnects(t, conn, addrFor(9001)))
+	assert.Empty(t, disconnects(t, conn, addrFor(9002)), "players with the same ID in other rooms stay")
+	assert.Len(t, m.Players(), 1)
+
+	hello(t, m, addrFor(9001), 1, r.ID)
+	assert.Len(t, m.Players(), 2, "kicked players may join again")
+}
+
+func TestKickForInvalidMovement(t *testing.T) {
+	conn := &recordingConn{}
+	m := NewManager(conn, func(id uint32) Settings {
+		return Settings{TickRate: 10, World: world.New(20, 10), Movement: game.MovementRules{KickScore: 2}}
+	})
+	r := m.Create()
+	hello(t, m, addrFor(9001), 1, 0)
+	update(t, m, addrFor(9001), player.Player{ID: 1, X: 2, Y: 2, Sequence: 1})
+	r.State.Step(conn)
+
+	for seq := uint32(2); seq <= 3; seq++ {
+		update(t, m, addrFor(9001), player.Player{ID: 1, X: 40, Y: 2, Sequence: seq})
+		r.State.Step(conn)
+	}
+
+	assert.Equal(t, []protocol.DisconnectReason{protocol.DisconnectInvalidMovement}, disconnects(t, conn, addrFor(9001)))
+	assert.Empty(t, m.Players())
+}
+
+func TestBan(t *testing.T) {
+	conn := &recordingConn{}
+	m := newManager(conn, 0)
+	m.Create()
+	hello(t, m, addrFor(9001), 1, 0)
+	hello(t, m, addrFor(9002), 2, 0)
+
+	remote := &net.UDPAddr{IP: net.ParseIP("10.0.0.7"), Port: 9003}
+	hello(t, m, remote, 3, 0)
+
+	assert.Equal(t, 2, m.Ban(net.ParseIP("127.0.0.1")))
+	assert.Equal(t, []protocol.DisconnectReason{protocol.DisconnectBanned}, disconnects(t, conn, addrFor(9002)))
+
+	hello(t, m, addrFor(9004), 4, 0)
+	lobbyRequest(t, m, addrFor(9004), protocol.MsgListRooms)
+	assert.Empty(t, conn.messages(addrFor(9004), protocol.MsgWelcome), "banned addresses cannot join")
+	assert.Empty(t, conn.messages(addrFor(9004), protocol.MsgRoomList))
+
+	players := m.Players()
+	assert.Len(t, players, 1)
+	assert.Equal(t, int32(3), players[0].ID)
+	assert.Equal(t, 1, m.Stats().Banned)
+}
+
+func TestTeleport(t *testing.T) {
+	conn := &recordingConn{}
+	m := newManager(conn, 0)
+	r := m.Create()
+	hello(t, m, addrFor(9001), 1, 0)
+
+	assert.ErrorIs(t, m.Teleport(r.ID, 2, 3, 3), ErrNoSuchPlayer)
+	assert.ErrorIs(t, m.Teleport(r.ID+1, 1, 3, 3), ErrNoSuchPlayer)
+	assert.ErrorIs(t, m.Teleport(r.ID, 1, 3, 3), game.ErrUnknownPlayer, "players without a position cannot be moved")
+
+	update(t, m, addrFor(9001), player.Player{ID: 1, X: 2, Y: 2, Sequence: 1})
+	r.State.Step(conn)
+	assert.NoError(t, m.Teleport(r.ID, 1, 8, 6))
+	r.State.Step(conn)
+
+	players := m.Players()
+	assert.Equal(t, float32(8), players[0].X)
+	assert.Equal(t, float32(6), players[0].Y)
+}
+
+func TestAnnounce(t *testing.T) {
+	conn := &recordingConn{}
+	m := newManager(conn, 0)
+	first := m.Create()
+	second := m.Create()
+	hello(t, m, addrFor(9001), 1, first.ID)
+	hello(t, m, addrFor(9002), 2, second.ID)
+
+	assert.Error(t, m.Announce("  "))
+	assert.NoError(t, m.Announce("restarting soon"))
+
+	for _, port := range []int{9001, 9002} {
+		assert.Equal(t, []string{"announcement : restarting soon"}, chats(t, conn, addrFor(port)))
+	}
+
+	say(t, m, addrFor(9001), protocol.ChatAnnouncement, "", "fake")
+	assert.Len(t, chats(t, conn, addrFor(9002)), 1, "players cannot announce")
+}
+
+func TestSetTickRate(t *testing.T) {
+	m := newManager(&recordingConn{}, 0)
+	m.Create()
+
+	assert.ErrorIs(t, m.SetTickRate(0), ErrTickRate)
+	assert.ErrorIs(t, m.SetTickRate(MaxTickRate+1), ErrTickRate)
+	assert.NoError(t, m.SetTickRate(60))
+	m.Create()
+
+	for _, info := range m.Rooms() {
+		assert.Equal(t, 60, info.TickRate)
+	}
+}
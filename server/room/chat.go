@@ -0,0 +1,139 @@
+package room
+
+import (
+	"net"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/zainokta/client-server-multiplayer/shared/protocol"
+)
+
+const (
+	// ChatBurst is how many chat messages a player may send at once.
+	ChatBurst = 5
+	// ChatInterval is how often a player earns another chat message once the
+	// burst is spent.
+	ChatInterval = time.Second
+)
+
+// limiter is a token bucket holding up to ChatBurst messages and refilling
+// one every ChatInterval.
+type limiter struct {
+	tokens float64
+	at     time.Time
+}
+
+func newLimiter(now time.Time) limiter {
+	return limiter{tokens: ChatBurst, at: now}
+}
+
+func (l *limiter) allow(now time.Time) bool {
+	l.tokens = min(ChatBurst, l.tokens+float64(now.Sub(l.at))/float64(ChatInterval))
+	l.at = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// cleanChat makes text safe to print on a terminal by replacing control
+// characters, such as escape sequences, and invalid UTF-8.
+func cleanChat(text string) string {
+	text = strings.ToValidUTF8(text, "?")
+	text = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, text)
+	return strings.TrimSpace(text)
+}
+
+// handleChat relays a chat message from a player in a room to its channel.
+// The sender receives its own message as well, confirming delivery.
+func (m *Manager) handleChat(addr *net.UDPAddr, data []byte) {
+	chat, text, err := protocol.DecodeChat(data)
+	if err != nil {
+		m.log.Warn("Failed to decode chat message", "addr", addr.String(), "err", err)
+		m.metrics.DecodeFailed(protocol.MsgChat)
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, exists := m.sessions[addr.String()]
+	if !exists {
+		return
+	}
+
+	if !s.chat.allow(m.clock.Now()) {
+		m.rejectChat(addr, protocol.ChatRateLimited)
+		return
+	}
+
+	text = cleanChat(text)
+	if text == "" {
+		m.rejectChat(addr, protocol.ChatEmpty)
+		return
+	}
+	if len(text) > protocol.MaxChatLength {
+		m.rejectChat(addr, protocol.ChatTooLong)
+		return
+	}
+
+	var recipients []*session
+	switch chat.Channel {
+	case protocol.ChatGlobal:
+		for _, other := range m.sessions {
+			recipients = append(recipients, other)
+		}
+	case protocol.ChatRoom:
+		for _, other := range m.sessions {
+			if other.room == s.room {
+				recipients = append(recipients, other)
+			}
+		}
+	case protocol.ChatWhisper:
+		target := m.byName(chat.To)
+		if target == nil {
+			m.rejectChat(addr, protocol.ChatNoSuchPlayer)
+			return
+		}
+		chat.To = target.name
+		recipients = append(recipients, target)
+		if target != s {
+			recipients = append(recipients, s)
+		}
+	default:
+		return
+	}
+
+	chat.From = s.name
+	relayed, err := protocol.EncodeChat(chat, text)
+	if err != nil {
+		s.log.Error("Failed to encode chat message", "err", err)
+		return
+	}
+
+	for _, recipient := range recipients {
+		m.writeReliable(recipient.addr, relayed)
+	}
+}
+
+// byName finds the session of the player with the given name, ignoring case.
+// The caller holds the manager lock.
+func (m *Manager) byName(name protocol.Name) *session {
+	for _, s := range m.sessions {
+		if strings.EqualFold(s.name.String(), name.String()) {
+			return s
+		}
+	}
+	return nil
+}
+
+func (m *Manager) rejectChat(addr *net.UDPAddr, reason protocol.ChatRejectReason) {
+	m.sendReliable(addr, protocol.MsgChatRejected, protocol.ChatRejected{Reason: reason})
+}
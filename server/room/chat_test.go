@@ -0,0 +1,152 @@
+package room
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zainokta/client-server-multiplayer/shared/protocol"
+	"github.com/zainokta/client-server-multiplayer/shared/reliable"
+)
+
+func say(t *testing.T, m *Manager, addr *net.UDPAddr, channel protocol.ChatChannel, to, text string) {
+	chat := protocol.Chat{Channel: channel}
+	if to != "" {
+		var err error
+		chat.To, err = protocol.ParseName(to)
+		assert.NoError(t, err)
+	}
+
+	data, err := protocol.EncodeChat(chat, text)
+	assert.NoError(t, err)
+	m.Handle(addr, data)
+}
+
+// received returns the reliable messages of msgType sent to addr, unwrapped.
+func received(t *testing.T, conn *recordingConn, addr *net.UDPAddr, msgType protocol.MsgType) [][]byte {
+	var found [][]byte
+	for _, data := range conn.messages(addr, protocol.MsgReliable) {
+		_, inner, err := protocol.DecodeReliable(data)
+		assert.NoError(t, err)
+		if got, _ := protocol.Type(inner); got == msgType {
+			found = append(found, inner)
+		}
+	}
+	return found
+}
+
+// chats returns the chat messages sent to addr as "channel from>to: text".
+func chats(t *testing.T, conn *recordingConn, addr *net.UDPAddr) []string {
+	var lines []string
+	for _, data := range received(t, conn, addr, protocol.MsgChat) {
+		chat, text, err := protocol.DecodeChat(data)
+		assert.NoError(t, err)
+		line := chat.Channel.String() + " " + chat.From.String()
+		if chat.To.Valid() {
+			line += ">" + chat.To.String()
+		}
+		lines = append(lines, line+": "+text)
+	}
+	return lines
+}
+
+func chatRejections(t *testing.T, conn *recordingConn, addr *net.UDPAddr) []protocol.ChatRejectReason {
+	var reasons []protocol.ChatRejectReason
+	for _, data := range received(t, conn, addr, protocol.MsgChatRejected) {
+		var rejected protocol.ChatRejected
+		assert.NoError(t, protocol.Decode(data, protocol.MsgChatRejected, &rejected))
+		reasons = append(reasons, rejected.Reason)
+	}
+	return reasons
+}
+
+func TestChatChannels(t *testing.T) {
+	conn := &recordingConn{}
+	m := newManager(conn, 0)
+	first := m.Create()
+	second := m.Create()
+
+	hello(t, m, addrFor(9001), 1, first.ID)
+	hello(t, m, addrFor(9002), 2, first.ID)
+	hello(t, m, addrFor(9003), 3, second.ID)
+
+	say(t, m, addrFor(9001), protocol.ChatRoom, "", "hello room")
+	say(t, m, addrFor(9003), protocol.ChatGlobal, "", "hello all")
+	say(t, m, addrFor(9001), protocol.ChatWhisper, "PLAYER3", "psst")
+	say(t, m, addrFor(9001), protocol.ChatWhisper, "nobody", "anyone?")
+	say(t, m, addrFor(9009), protocol.ChatGlobal, "", "from the lobby")
+
+	assert.Equal(t, []string{
+		"room player1: hello room",
+		"global player3: hello all",
+		"whisper player1>player3: psst",
+	}, chats(t, conn, addrFor(9001)), "senders receive their own messages")
+	assert.Equal(t, []string{
+		"room player1: hello room",
+		"global player3: hello all",
+	}, chats(t, conn, addrFor(9002)))
+	assert.Equal(t, []string{
+		"global player3: hello all",
+		"whisper player1>player3: psst",
+	}, chats(t, conn, addrFor(9003)))
+
+	assert.Equal(t, []protocol.ChatRejectReason{protocol.ChatNoSuchPlayer}, chatRejections(t, conn, addrFor(9001)))
+	assert.Empty(t, conn.messages(addrFor(9009), protocol.MsgReliable), "players outside of rooms cannot chat")
+}
+
+func TestChatLimits(t *testing.T) {
+	conn := &recordingConn{}
+	m := newManager(conn, 0)
+	m.Create()
+	addr := addrFor(9001)
+	hello(t, m, addr, 1, 0)
+
+	say(t, m, addr, protocol.ChatRoom, "", strings.Repeat("a", protocol.MaxChatLength+1))
+	say(t, m, addr, protocol.ChatRoom, "", " \t ")
+	say(t, m, addr, protocol.ChatRoom, "", "hi\x1b[2J")
+	for range ChatBurst {
+		say(t, m, addr, protocol.ChatRoom, "", "spam")
+	}
+
+	assert.Equal(t, []protocol.ChatRejectReason{
+		protocol.ChatTooLong, protocol.ChatEmpty, protocol.ChatRateLimited, protocol.ChatRateLimited, protocol.ChatRateLimited,
+	}, chatRejections(t, conn, addr))
+
+	lines := chats(t, conn, addr)
+	assert.Equal(t, []string{"room player1: hi [2J", "room player1: spam", "room player1: spam"}, lines,
+		"control characters are replaced")
+}
+
+func TestChatLimiterRefills(t *testing.T) {
+	now := time.Now()
+	l := newLimiter(now)
+	for range ChatBurst {
+		assert.True(t, l.allow(now))
+	}
+	assert.False(t, l.allow(now))
+	assert.False(t, l.allow(now.Add(ChatInterval/2)))
+	assert.True(t, l.allow(now.Add(ChatInterval)))
+	assert.False(t, l.allow(now.Add(ChatInterval)))
+}
+
+func TestChatOverReliableChannel(t *testing.T) {
+	conn := &recordingConn{}
+	m := newManager(conn, 0)
+	m.Create()
+	addr := addrFor(9001)
+	hello(t, m, addr, 1, 0)
+
+	endpoint := reliable.NewEndpoint()
+	inner, err := protocol.EncodeChat(protocol.Chat{Channel: protocol.ChatRoom}, "reliably")
+	assert.NoError(t, err)
+	data, err := endpoint.Wrap(inner, time.Now())
+	assert.NoError(t, err)
+
+	m.Handle(addr, data)
+	m.Handle(addr, data)
+
+	assert.Len(t, conn.messages(addr, protocol.MsgAck), 2)
+	assert.Equal(t, []string{"room player1: reliably"}, chats(t, conn, addr), "retransmissions are delivered once")
+}
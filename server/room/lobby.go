@@ -0,0 +1,227 @@
+package room
+
+import (
+	"math/rand/v2"
+	"net"
+	"time"
+
+	"github.com/zainokta/client-server-multiplayer/shared/protocol"
+)
+
+// DefaultPartySize is how many queued players are matched into a room when
+// neither the client nor WithPartySize says otherwise.
+const DefaultPartySize = 2
+
+// codeAlphabet leaves out letters and digits that are easily confused.
+const codeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// WithPartySize sets the default number of players the matchmaking queue
+// groups into a room. Sizes below one keep DefaultPartySize.
+func WithPartySize(n int) Option {
+	return func(m *Manager) {
+		if n > 0 {
+			m.partySize = min(n, 255)
+		}
+	}
+}
+
+type queued struct {
+	key      string
+	addr     *net.UDPAddr
+	playerID int32
+	lastSeen time.Time
+}
+
+// match remembers the room found for a queued client until it joins, so a
+// lost MatchFound is sent again when the client asks.
+type match struct {
+	found protocol.MatchFound
+	at    time.Time
+}
+
+func (m *Manager) newCode() protocol.JoinCode {
+	for {
+		var code protocol.JoinCode
+		for i := range code {
+			code[i] = codeAlphabet[rand.IntN(len(codeAlphabet))]
+		}
+		if _, taken := m.codes[code]; !taken {
+			return code
+		}
+	}
+}
+
+func (m *Manager) handleListRooms(addr *net.UDPAddr) {
+	var entries []protocol.RoomEntry
+	for _, info := range m.Rooms() {
+		if info.Private {
+			continue
+		}
+		entries = append(entries, protocol.RoomEntry{
+			ID:       info.ID,
+			Players:  uint16(info.Players),
+			Capacity: uint16(info.Capacity),
+			Width:    uint16(info.Width),
+			Height:   uint16(info.Height),
+		})
+	}
+
+	data, err := protocol.EncodeRoomList(entries)
+	if err != nil {
+		m.log.Error("Failed to encode room list", "err", err)
+		return
+	}
+	m.write(addr, data)
+}
+
+// handleCreateRoom opens a private room for the client. Repeated requests
+// get the same room until the client joins it.
+func (m *Manager) handleCreateRoom(addr *net.UDPAddr) {
+	key := addr.String()
+
+	m.mu.Lock()
+	r, exists := m.created[key]
+	if !exists || m.rooms[r.ID] != r {
+		var err error
+		if r, err = m.createPrivate(); err != nil {
+			m.mu.Unlock()
+			m.log.Warn("Refused to open a private room", "addr", key, "err", err)
+			m.send(addr, protocol.MsgJoinRejected, protocol.JoinRejected{Reason: protocol.RejectTooManyRooms})
+			return
+		}
+		m.created[key] = r
+	}
+	created := protocol.RoomCreated{RoomID: r.ID, Code: r.Code}
+	m.mu.Unlock()
+
+	m.send(addr, protocol.MsgRoomCreated, created)
+}
+
+// handleQueueJoin queues the client, or refreshes its place in the queue.
+// Once enough players wait for the same party size a private room is opened
+// and every one of them is told to join it.
+func (m *Manager) handleQueueJoin(addr *net.UDPAddr, data []byte) {
+	var join protocol.QueueJoin
+	if err := protocol.Decode(data, protocol.MsgQueueJoin, &join); err != nil {
+		m.log.Warn("Failed to decode queue join", "addr", addr.String(), "err", err)
+		m.metrics.DecodeFailed(protocol.MsgQueueJoin)
+		return
+	}
+
+	key := addr.String()
+	size := int(join.PartySize)
+	if size == 0 {
+		size = m.partySize
+	}
+
+	m.mu.Lock()
+	if found, matched := m.matches[key]; matched {
+		m.mu.Unlock()
+		m.send(addr, protocol.MsgMatchFound, found.found)
+		return
+	}
+
+	party, waiting := m.enqueue(key, addr, join.PlayerID, size)
+	var found protocol.MatchFound
+	if party != nil {
+		r, err := m.createPrivate()
+		if err != nil {
+			m.mu.Unlock()
+			m.log.Warn("Refused to open a room for a party", "size", size, "err", err)
+			for _, q := range party {
+				m.send(q.addr, protocol.MsgJoinRejected, protocol.JoinRejected{Reason: protocol.RejectTooManyRooms})
+			}
+			return
+		}
+		if r.Capacity > 0 {
+			r.Capacity = max(r.Capacity, size)
+		}
+
+		found = protocol.MatchFound{RoomID: r.ID, Code: r.Code}
+		for _, q := range party {
+			m.matches[q.key] = match{found: found, at: m.clock.Now()}
+		}
+		m.log.Info("Matched a party", "size", size, "room", r.ID)
+	}
+	m.mu.Unlock()
+
+	if party == nil {
+		m.send(addr, protocol.MsgQueueStatus, protocol.QueueStatus{Waiting: uint8(waiting), PartySize: uint8(size)})
+		return
+	}
+
+	for _, q := range party {
+		m.send(q.addr, protocol.MsgMatchFound, found)
+	}
+}
+
+func (m *Manager) handleQueueLeave(addr *net.UDPAddr) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.dequeue(addr.String())
+}
+
+// enqueue adds the client to the queue for size, keeping its place if it is
+// already waiting there. It returns the party once the queue is long enough,
+// or how many players are waiting.
+func (m *Manager) enqueue(key string, addr *net.UDPAddr, playerID int32, size int) ([]*queued, int) {
+	queue := m.queues[uint8(size)]
+
+	waiting := false
+	for _, q := range queue {
+		if q.key == key {
+			q.playerID = playerID
+			q.lastSeen = m.clock.Now()
+			waiting = true
+		}
+	}
+
+	if !waiting {
+		m.dequeue(key)
+		queue = append(m.queues[uint8(size)], &queued{key: key, addr: addr, playerID: playerID, lastSeen: m.clock.Now()})
+	}
+
+	if len(queue) >= size {
+		party := queue[:size]
+		m.queues[uint8(size)] = append([]*queued(nil), queue[size:]...)
+		return party, 0
+	}
+
+	m.queues[uint8(size)] = queue
+	return nil, len(queue)
+}
+
+func (m *Manager) dequeue(key string) {
+	for size, queue := range m.queues {
+		m.queues[size] = without(queue, func(q *queued) bool { return q.key == key })
+	}
+}
+
+func (m *Manager) expireLobby(now time.Time) {
+	for size, queue := range m.queues {
+		m.queues[size] = without(queue, func(q *queued) bool { return now.Sub(q.lastSeen) > SessionTimeout })
+	}
+
+	for key, found := range m.matches {
+		if now.Sub(found.at) > SessionTimeout {
+			delete(m.matches, key)
+		}
+	}
+
+	for key, r := range m.created {
+		if m.rooms[r.ID] != r {
+			delete(m.created, key)
+		}
+	}
+}
+
+func without(queue []*queued, drop func(q *queued) bool) []*queued {
+	kept := queue[:0]
+	for _, q := range queue {
+		if !drop(q) {
+			kept = append(kept, q)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,185 @@
+package room
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zainokta/client-server-multiplayer/shared/protocol"
+)
+
+func lobbyRequest(t *testing.T, m *Manager, addr *net.UDPAddr, msgType protocol.MsgType) {
+	data, err := protocol.Encode(msgType, protocol.LobbyRequest{PlayerID: int32(addr.Port)})
+	assert.NoError(t, err)
+	m.Handle(addr, data)
+}
+
+func queueJoin(t *testing.T, m *Manager, addr *net.UDPAddr, partySize uint8) {
+	data, err := protocol.Encode(protocol.MsgQueueJoin, protocol.QueueJoin{PlayerID: int32(addr.Port), PartySize: partySize})
+	assert.NoError(t, err)
+	m.Handle(addr, data)
+}
+
+func last[T any](t *testing.T, conn *recordingConn, addr *net.UDPAddr, msgType protocol.MsgType) T {
+	var payload T
+	found := conn.messages(addr, msgType)
+	if !assert.NotEmpty(t, found, "no message of type %d", msgType) {
+		return payload
+	}
+	assert.NoError(t, protocol.Decode(found[len(found)-1], msgType, &payload))
+	return payload
+}
+
+func TestListRoomsHidesPrivateRooms(t *testing.T) {
+	conn := &recordingConn{}
+	m := newManager(conn, 4)
+	public := m.Create()
+	m.CreatePrivate()
+
+	_, err := m.Join(addrFor(9001), helloFor(1, public.ID, protocol.JoinCode{}))
+	assert.NoError(t, err)
+
+	lobbyRequest(t, m, addrFor(9002), protocol.MsgListRooms)
+
+	lists := conn.messages(addrFor(9002), protocol.MsgRoomList)
+	assert.Len(t, lists, 1)
+
+	entries, err := protocol.DecodeRoomList(lists[0])
+	assert.NoError(t, err)
+	assert.Equal(t, []protocol.RoomEntry{{ID: public.ID, Players: 1, Capacity: 4, Width: 20, Height: 10}}, entries)
+}
+
+func TestPrivateRoomJoinedByCode(t *testing.T) {
+	conn := &recordingConn{}
+	m := newManager(conn, 0)
+	m.Create()
+	owner := addrFor(9001)
+
+	lobbyRequest(t, m, owner, protocol.MsgCreateRoom)
+	lobbyRequest(t, m, owner, protocol.MsgCreateRoom)
+
+	created := conn.messages(owner, protocol.MsgRoomCreated)
+	assert.Len(t, created, 2)
+	room := last[protocol.RoomCreated](t, conn, owner, protocol.MsgRoomCreated)
+	assert.Len(t, m.Rooms(), 2, "a repeated request reuses the room")
+
+	_, err := m.Join(addrFor(9002), helloFor(2, room.RoomID, protocol.JoinCode{}))
+	assert.ErrorIs(t, err, ErrNoSuchRoom, "private rooms need their code")
+
+	_, err = m.Join(addrFor(9002), helloFor(2, 0, protocol.JoinCode{'N', 'O', 'P', 'E', '0', '0'}))
+	assert.ErrorIs(t, err, ErrNoSuchRoom)
+
+	r, err := m.Join(owner, helloFor(1, 0, room.Code))
+	assert.NoError(t, err)
+	assert.Equal(t, room.RoomID, r.ID)
+
+	r, err = m.Join(addrFor(9002), helloFor(2, 0, room.Code))
+	assert.NoError(t, err)
+	assert.Equal(t, room.RoomID, r.ID)
+
+	auto, err := m.Join(addrFor(9003), helloFor(3, 0, protocol.JoinCode{}))
+	assert.NoError(t, err)
+	assert.NotEqual(t, room.RoomID, auto.ID, "private rooms are never assigned automatically")
+}
+
+func TestEmptyPrivateRoomsExpire(t *testing.T) {
+	m := newManager(&recordingConn{}, 0)
+	m.Create()
+	r, err := m.CreatePrivate()
+	assert.NoError(t, err)
+
+	m.expire(time.Now())
+	_, exists := m.Get(r.ID)
+	assert.True(t, exists)
+
+	m.expire(time.Now().Add(SessionTimeout + time.Second))
+	_, exists = m.Get(r.ID)
+	assert.False(t, exists)
+
+	_, err = m.Join(addrFor(9001), helloFor(1, 0, r.Code))
+	assert.ErrorIs(t, err, ErrNoSuchRoom)
+}
+
+func TestMatchmakingQueue(t *testing.T) {
+	conn := &recordingConn{}
+	m := newManager(conn, 0, WithPartySize(3))
+	m.Create()
+
+	queueJoin(t, m, addrFor(9001), 0)
+	queueJoin(t, m, addrFor(9001), 0)
+	status := last[protocol.QueueStatus](t, conn, addrFor(9001), protocol.MsgQueueStatus)
+	assert.Equal(t, protocol.QueueStatus{Waiting: 1, PartySize: 3}, status, "refreshing keeps a single place")
+
+	queueJoin(t, m, addrFor(9002), 0)
+	status = last[protocol.QueueStatus](t, conn, addrFor(9002), protocol.MsgQueueStatus)
+	assert.Equal(t, uint8(2), status.Waiting)
+
+	queueJoin(t, m, addrFor(9009), 2)
+	status = last[protocol.QueueStatus](t, conn, addrFor(9009), protocol.MsgQueueStatus)
+	assert.Equal(t, protocol.QueueStatus{Waiting: 1, PartySize: 2}, status, "parties of other sizes queue separately")
+
+	queueJoin(t, m, addrFor(9003), 0)
+
+	var found protocol.MatchFound
+	for port := 9001; port <= 9003; port++ {
+		match := last[protocol.MatchFound](t, conn, addrFor(port), protocol.MsgMatchFound)
+		if port > 9001 {
+			assert.Equal(t, found, match, "the whole party gets the same room")
+		}
+		found = match
+	}
+	assert.Empty(t, conn.messages(addrFor(9009), protocol.MsgMatchFound))
+
+	queueJoin(t, m, addrFor(9001), 0)
+	assert.Len(t, conn.messages(addrFor(9001), protocol.MsgMatchFound), 2, "a lost match is sent again")
+
+	r, err := m.Join(addrFor(9001), helloFor(1, 0, found.Code))
+	assert.NoError(t, err)
+	assert.Equal(t, found.RoomID, r.ID)
+	assert.True(t, r.Private)
+}
+
+func TestRoomLimitCoversPrivateRooms(t *testing.T) {
+	conn := &recordingConn{}
+	m := newManager(conn, 0, WithMaxRooms(2), WithPartySize(2))
+	m.Create()
+
+	lobbyRequest(t, m, addrFor(9001), protocol.MsgCreateRoom)
+	assert.NotEmpty(t, conn.messages(addrFor(9001), protocol.MsgRoomCreated))
+
+	lobbyRequest(t, m, addrFor(9002), protocol.MsgCreateRoom)
+	assert.Empty(t, conn.messages(addrFor(9002), protocol.MsgRoomCreated))
+	rejected := last[protocol.JoinRejected](t, conn, addrFor(9002), protocol.MsgJoinRejected)
+	assert.Equal(t, protocol.RejectTooManyRooms, rejected.Reason)
+
+	queueJoin(t, m, addrFor(9003), 0)
+	queueJoin(t, m, addrFor(9004), 0)
+	for port := 9003; port <= 9004; port++ {
+		assert.Empty(t, conn.messages(addrFor(port), protocol.MsgMatchFound))
+		rejected = last[protocol.JoinRejected](t, conn, addrFor(port), protocol.MsgJoinRejected)
+		assert.Equal(t, protocol.RejectTooManyRooms, rejected.Reason, "no room is opened for the party")
+	}
+
+	_, err := m.CreatePrivate()
+	assert.ErrorIs(t, err, ErrTooManyRooms)
+	assert.Len(t, m.Rooms(), 2)
+}
+
+func TestQueueLeave(t *testing.T) {
+	conn := &recordingConn{}
+	m := newManager(conn, 0)
+
+	queueJoin(t, m, addrFor(9001), 0)
+	lobbyRequest(t, m, addrFor(9001), protocol.MsgQueueLeave)
+	queueJoin(t, m, addrFor(9002), 0)
+
+	status := last[protocol.QueueStatus](t, conn, addrFor(9002), protocol.MsgQueueStatus)
+	assert.Equal(t, uint8(1), status.Waiting)
+
+	m.expire(time.Now().Add(SessionTimeout + time.Second))
+	queueJoin(t, m, addrFor(9003), 0)
+
+	status = last[protocol.QueueStatus](t, conn, addrFor(9003), protocol.MsgQueueStatus)
+	assert.Equal(t, uint8(1), status.Waiting, "silent players drop out of the queue")
+}
@@ -0,0 +1,687 @@
+package room
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zainokta/client-server-multiplayer/server/game"
+	"github.com/zainokta/client-server-multiplayer/server/metrics"
+	"github.com/zainokta/client-server-multiplayer/server/player"
+	"github.com/zainokta/client-server-multiplayer/shared/clock"
+	"github.com/zainokta/client-server-multiplayer/shared/netsim"
+	"github.com/zainokta/client-server-multiplayer/shared/protocol"
+	"github.com/zainokta/client-server-multiplayer/shared/reliable"
+)
+
+// SessionTimeout is how long a client may stay silent before it loses its
+// seat in a room.
+const SessionTimeout = game.DisconnectTimer
+
+var (
+	ErrRoomFull     = errors.New("room is full")
+	ErrNoSuchRoom   = errors.New("room does not exist")
+	ErrNameTaken    = errors.New("name is taken")
+	ErrInvalidName  = errors.New("name is invalid")
+	ErrIDTaken      = errors.New("player ID is taken")
+	ErrTooManyRooms = errors.New("too many rooms")
+)
+
+// Info is a snapshot of a room for listings.
+type Info struct {
+	ID       uint32
+	Name     string
+	Private  bool
+	Players  int
+	Capacity int
+	Width    int
+	Height   int
+	TickRate int
+	// TickDuration is how long the last tick took.
+	TickDuration time.Duration
+	// Tick is the tick counter of the room and Overruns how many ticks took
+	// longer than their budget.
+	Tick     uint64
+	Overruns uint64
+}
+
+type session struct {
+	// id tells the sessions of a client apart in logs. It is kept when the
+	// client switches rooms.
+	id       uint64
+	log      *slog.Logger
+	addr     *net.UDPAddr
+	room     *Room
+	playerID int32
+	name     protocol.Name
+	color    protocol.Color
+	lastSeen time.Time
+	chat     limiter
+	latency  latency
+}
+
+func (s *session) info() protocol.PlayerInfo {
+	return protocol.PlayerInfo{PlayerID: s.playerID, Name: s.name, Color: s.color}
+}
+
+// identify attaches the session, player and address to every record logged
+// for the session.
+func (s *session) identify(base *slog.Logger) {
+	s.log = base.With("session", s.id, "player", s.playerID, "addr", s.addr.String())
+}
+
+type Option func(*Manager)
+
+// WithMaxRooms limits how many rooms may exist at once. Once reached, no room
+// is opened on demand, as a private room or for a matched party, though rooms
+// added with Create still are. Zero means unlimited.
+func WithMaxRooms(n int) Option {
+	return func(m *Manager) {
+		m.maxRooms = n
+	}
+}
+
+// WithMetrics records the packets the manager sends and ignores, the
+// connected players and the tick durations of its rooms in m.
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(mgr *Manager) {
+		mgr.metrics = m
+	}
+}
+
+// WithNetworkConditions degrades every packet the manager and its rooms send.
+// Packets are counted by WithMetrics once they leave the simulated network,
+// so the metrics only report what went on the wire.
+func WithNetworkConditions(conditions netsim.Conditions) Option {
+	return func(m *Manager) {
+		m.conditions = conditions
+	}
+}
+
+// WithClock sets the clock that times sessions, the lobby and the game loops
+// of the rooms. Defaults to clock.Real.
+func WithClock(c clock.Clock) Option {
+	return func(m *Manager) {
+		m.clock = c
+	}
+}
+
+// WithLogger sets the logger of the manager and its rooms. Defaults to
+// slog.Default.
+func WithLogger(l *slog.Logger) Option {
+	return func(m *Manager) {
+		m.log = l
+	}
+}
+
+// Manager hosts the rooms of a server and routes every packet to the room of
+// the client that sent it.
+type Manager struct {
+	conn     game.UDPConn
+	settings func(id uint32) Settings
+	maxRooms int
+	metrics  *metrics.Metrics
+	log      *slog.Logger
+	clock    clock.Clock
+	// conditions simulate a bad network on the way out.
+	conditions netsim.Conditions
+
+	mu     sync.Mutex
+	ctx    context.Context
+	wg     sync.WaitGroup
+	rooms  map[uint32]*Room
+	nextID uint32
+	// nextSession is the ID of the last session.
+	nextSession uint64
+	sessions    map[string]*session
+	codes       map[protocol.JoinCode]*Room
+	peers       map[string]*peer
+
+	partySize int
+	queues    map[uint8][]*queued
+	matches   map[string]match
+	created   map[string]*Room
+
+	// tickRate overrides the tick rate of the room settings once set by an
+	// operator.
+	tickRate int
+	banned   map[string]struct{}
+	started  time.Time
+}
+
+// NewManager creates a manager writing to conn. settings describes the room
+// with the given ID, both for rooms created with Create and on demand.
+func NewManager(conn game.UDPConn, settings func(id uint32) Settings, opts ...Option) *Manager {
+	m := &Manager{
+		conn:      conn,
+		settings:  settings,
+		rooms:     make(map[uint32]*Room),
+		sessions:  make(map[string]*session),
+		codes:     make(map[protocol.JoinCode]*Room),
+		peers:     make(map[string]*peer),
+		partySize: DefaultPartySize,
+		queues:    make(map[uint8][]*queued),
+		matches:   make(map[string]match),
+		created:   make(map[string]*Room),
+		banned:    make(map[string]struct{}),
+		log:       slog.Default(),
+		clock:     clock.Real,
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+	m.started = m.clock.Now()
+
+	if m.metrics != nil {
+		m.conn = metrics.Conn(conn, m.metrics)
+		m.metrics.Gauge("game_players", "Players connected to a room.", func() float64 {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			return float64(len(m.sessions))
+		})
+	}
+	if m.conditions.Enabled() {
+		m.conn = netsim.WrapUDP(m.conn, m.conditions)
+	}
+
+	return m
+}
+
+// Create adds a room that stays open while the manager runs.
+func (m *Manager) Create() *Room {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.create(false)
+}
+
+func (m *Manager) create(temporary bool) *Room {
+	m.nextID++
+	settings := m.settings(m.nextID)
+	if m.tickRate > 0 {
+		settings.TickRate = m.tickRate
+	}
+
+	id := m.nextID
+	r := newRoom(id, settings, temporary, m.metrics, m.log, m.clock, func(addr *net.UDPAddr) {
+		m.expel(id, addr)
+	})
+	m.rooms[r.ID] = r
+
+	if m.ctx != nil {
+		m.start(r)
+	}
+
+	m.log.Info("Opened room", "room", r.ID, "name", r.Name, "temporary", temporary)
+	return r
+}
+
+// CreatePrivate adds a private room with a fresh join code. Like rooms opened
+// on demand it is closed once empty, and it fails with ErrTooManyRooms once
+// the limit of WithMaxRooms is reached.
+func (m *Manager) CreatePrivate() (*Room, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.createPrivate()
+}
+
+func (m *Manager) createPrivate() (*Room, error) {
+	if m.atRoomLimit() {
+		return nil, ErrTooManyRooms
+	}
+
+	r := m.create(true)
+	r.Private = true
+	r.Code = m.newCode()
+	m.codes[r.Code] = r
+	return r, nil
+}
+
+// atRoomLimit reports whether no more rooms may be opened on demand.
+func (m *Manager) atRoomLimit() bool {
+	return m.maxRooms > 0 && len(m.rooms) >= m.maxRooms
+}
+
+func (m *Manager) start(r *Room) {
+	ctx, cancel := context.WithCancel(m.ctx)
+	r.cancel = cancel
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		r.run(ctx, m.conn)
+	}()
+}
+
+// Run starts the game loop of every room and expires silent sessions until
+// ctx is cancelled. It returns once every room has stopped.
+func (m *Manager) Run(ctx context.Context) {
+	m.mu.Lock()
+	m.ctx = ctx
+	for _, r := range m.rooms {
+		m.start(r)
+	}
+	m.mu.Unlock()
+
+	ticker := m.clock.NewTicker(SessionTimeout)
+	defer ticker.Stop()
+
+	resend := m.clock.NewTicker(reliable.ResendInterval)
+	defer resend.Stop()
+
+	ping := m.clock.NewTicker(PingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			m.wg.Wait()
+			return
+		case <-ticker.C():
+			m.expire(m.clock.Now())
+		case <-resend.C():
+			m.resend(m.clock.Now())
+		case <-ping.C():
+			m.ping(m.clock.Now())
+		}
+	}
+}
+
+// Get returns the room with the given ID.
+func (m *Manager) Get(id uint32) (*Room, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r, exists := m.rooms[id]
+	return r, exists
+}
+
+// Rooms lists every open room ordered by ID.
+func (m *Manager) Rooms() []Info {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	infos := make([]Info, 0, len(m.rooms))
+	for _, r := range m.rooms {
+		infos = append(infos, Info{
+			ID:           r.ID,
+			Name:         r.Name,
+			Private:      r.Private,
+			Players:      r.members,
+			Capacity:     r.Capacity,
+			Width:        r.World.Width,
+			Height:       r.World.Height,
+			TickRate:     r.TickRate(),
+			TickDuration: r.TickDuration(),
+			Tick:         r.State.Tick(),
+			Overruns:     r.State.Overruns(),
+		})
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
+
+// RoomOf returns the room the client at addr is in.
+func (m *Manager) RoomOf(addr *net.UDPAddr) (*Room, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, exists := m.sessions[addr.String()]
+	if !exists {
+		return nil, false
+	}
+	return s.room, true
+}
+
+// Join seats the client at addr in the room its hello asks for. A non-zero
+// code joins the private room it belongs to. Otherwise room ID 0 keeps the
+// client in its current room, or picks the first public room with a free seat
+// and opens a new one when all are full. Joining another room leaves the
+// current one. Names must be valid and not used by any other client, and
+// player IDs must not be used by another client in the same room.
+func (m *Manager) Join(addr *net.UDPAddr, hello protocol.Hello) (*Room, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := addr.String()
+	if !hello.Name.Valid() {
+		return nil, ErrInvalidName
+	}
+	if m.nameTaken(key, hello.Name) {
+		return nil, ErrNameTaken
+	}
+	if hello.Color > protocol.ColorWhite {
+		hello.Color = protocol.ColorDefault
+	}
+
+	s, exists := m.sessions[key]
+	if exists && s.room.matches(hello.RoomID, hello.Code) {
+		s.lastSeen = m.clock.Now()
+		if s.playerID == hello.PlayerID && s.name == hello.Name && s.color == hello.Color {
+			return s.room, nil
+		}
+		if m.idTaken(key, s.room, hello.PlayerID) {
+			return nil, ErrIDTaken
+		}
+
+		s.room.State.RemovePlayer(s.playerID)
+		s.playerID, s.name, s.color = hello.PlayerID, hello.Name, hello.Color
+		s.identify(m.log)
+		m.introduce(key, s)
+		return s.room, nil
+	}
+
+	target, err := m.pick(hello.RoomID, hello.Code)
+	if err != nil {
+		return nil, err
+	}
+	if m.idTaken(key, target, hello.PlayerID) {
+		return nil, ErrIDTaken
+	}
+
+	m.dequeue(key)
+	delete(m.matches, key)
+	delete(m.created, key)
+
+	if exists {
+		s.log.Info("Player switches rooms", "from", s.room.ID, "to", target.ID)
+		m.leave(key, s)
+	}
+
+	chat, latency := newLimiter(m.clock.Now()), latency{}
+	if exists {
+		chat, latency = s.chat, s.latency
+	}
+
+	id := m.nextSession + 1
+	if exists {
+		id = s.id
+	} else {
+		m.nextSession = id
+	}
+
+	target.members++
+	s = &session{
+		id:       id,
+		addr:     addr,
+		room:     target,
+		playerID: hello.PlayerID,
+		name:     hello.Name,
+		color:    hello.Color,
+		lastSeen: m.clock.Now(),
+		chat:     chat,
+		latency:  latency,
+	}
+	s.identify(m.log)
+	s.log.Info("Player joined", "room", target.ID, "name", hello.Name.String())
+	m.sessions[key] = s
+	m.introduce(key, s)
+	return target, nil
+}
+
+func (m *Manager) nameTaken(key string, name protocol.Name) bool {
+	for other, s := range m.sessions {
+		if other != key && strings.EqualFold(s.name.String(), name.String()) {
+			return true
+		}
+	}
+	return false
+}
+
+// idTaken reports whether a client other than the one at key plays as id in
+// room r.
+func (m *Manager) idTaken(key string, r *Room, id int32) bool {
+	for other, s := range m.sessions {
+		if other != key && s.room == r && s.playerID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// introduce tells the room about the player of session s, and the player
+// about everyone already in the room, itself included.
+func (m *Manager) introduce(key string, s *session) {
+	for otherKey, other := range m.sessions {
+		if other.room != s.room {
+			continue
+		}
+
+		if otherKey != key {
+			m.sendReliable(other.addr, protocol.MsgPlayerInfo, s.info())
+		}
+		m.sendReliable(s.addr, protocol.MsgPlayerInfo, other.info())
+	}
+}
+
+func (m *Manager) pick(roomID uint32, code protocol.JoinCode) (*Room, error) {
+	if code != (protocol.JoinCode{}) {
+		r, exists := m.codes[code]
+		if !exists {
+			return nil, ErrNoSuchRoom
+		}
+		if r.full() {
+			return nil, ErrRoomFull
+		}
+		return r, nil
+	}
+
+	if roomID != 0 {
+		r, exists := m.rooms[roomID]
+		if !exists || r.Private {
+			return nil, ErrNoSuchRoom
+		}
+		if r.full() {
+			return nil, ErrRoomFull
+		}
+		return r, nil
+	}
+
+	ids := make([]uint32, 0, len(m.rooms))
+	for id := range m.rooms {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for _, id := range ids {
+		if r := m.rooms[id]; !r.Private && !r.full() {
+			return r, nil
+		}
+	}
+
+	if m.atRoomLimit() {
+		return nil, ErrRoomFull
+	}
+	return m.create(true), nil
+}
+
+func (m *Manager) leave(key string, s *session) {
+	s.room.State.RemovePlayer(s.playerID)
+	s.room.members--
+	delete(m.sessions, key)
+
+	if s.room.temporary && s.room.members == 0 {
+		m.close(s.room)
+	}
+}
+
+func (m *Manager) close(r *Room) {
+	if r.cancel != nil {
+		r.cancel()
+	}
+	delete(m.rooms, r.ID)
+	if r.Private {
+		delete(m.codes, r.Code)
+	}
+	m.log.Info("Closed empty room", "room", r.ID)
+}
+
+// expire drops silent sessions and queue entries, and closes temporary rooms
+// nobody joined in time.
+func (m *Manager) expire(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, s := range m.sessions {
+		if now.Sub(s.lastSeen) > SessionTimeout {
+			s.log.Info("Session expired", "room", s.room.ID)
+			m.leave(key, s)
+		}
+	}
+
+	for key := range m.peers {
+		if _, seated := m.sessions[key]; !seated {
+			delete(m.peers, key)
+		}
+	}
+
+	m.expireLobby(now)
+
+	for _, r := range m.rooms {
+		if r.temporary && r.members == 0 && now.Sub(r.opened) > SessionTimeout {
+			m.close(r)
+		}
+	}
+}
+
+// Handle routes a packet to the room of its sender. Hello packets join or
+// switch rooms; anything else from a client outside of every room is dropped.
+func (m *Manager) Handle(addr *net.UDPAddr, data []byte) {
+	msgType, err := protocol.Type(data)
+	if err != nil {
+		m.log.Debug("Dropping empty packet", "addr", addr.String())
+		m.metrics.Dropped(metrics.DropEmpty)
+		return
+	}
+
+	m.log.Debug("Received packet", "addr", addr.String(), "type", msgType.String(), "size", len(data))
+
+	if m.isBanned(addr.IP) {
+		m.metrics.Dropped(metrics.DropBanned)
+		return
+	}
+
+	switch msgType {
+	case protocol.MsgAck:
+		m.handleAck(addr, data)
+		return
+	case protocol.MsgReliable:
+		m.handleReliable(addr, data)
+		return
+	case protocol.MsgHello:
+		m.handleHello(addr, data)
+		return
+	case protocol.MsgListRooms:
+		m.handleListRooms(addr)
+		return
+	case protocol.MsgCreateRoom:
+		m.handleCreateRoom(addr)
+		return
+	case protocol.MsgQueueJoin:
+		m.handleQueueJoin(addr, data)
+		return
+	case protocol.MsgQueueLeave:
+		m.handleQueueLeave(addr)
+		return
+	case protocol.MsgChat:
+		m.handleChat(addr, data)
+		return
+	case protocol.MsgPing:
+		m.handlePing(addr, data)
+		return
+	case protocol.MsgPong:
+		m.handlePong(addr, data)
+		return
+	}
+
+	m.mu.Lock()
+	s, exists := m.sessions[addr.String()]
+	if exists {
+		s.lastSeen = m.clock.Now()
+	}
+	m.mu.Unlock()
+
+	if !exists {
+		m.log.Debug("Dropping packet from outside of any room", "addr", addr.String(), "type", msgType.String())
+		m.metrics.Dropped(metrics.DropNoSession)
+		return
+	}
+
+	if id, ok := claimedPlayer(msgType, data); ok && id != s.playerID {
+		s.log.Warn("Dropping packet for another player", "type", msgType.String(), "claimed", id)
+		m.metrics.Dropped(metrics.DropWrongPlayer)
+		return
+	}
+
+	s.room.State.HandleClient(m.conn, addr, data)
+}
+
+// claimedPlayer returns the player a move or action is for. Packets that do
+// not decode are left to the game, which reports them.
+func claimedPlayer(msgType protocol.MsgType, data []byte) (int32, bool) {
+	switch msgType {
+	case protocol.MsgPlayerUpdate:
+		p, err := player.DeserializePlayer(data)
+		return p.ID, err == nil
+	case protocol.MsgAction:
+		var action protocol.Action
+		err := protocol.Decode(data, protocol.MsgAction, &action)
+		return action.PlayerID, err == nil
+	}
+	return 0, false
+}
+
+func (m *Manager) handleHello(addr *net.UDPAddr, data []byte) {
+	var hello protocol.Hello
+	if err := protocol.Decode(data, protocol.MsgHello, &hello); err != nil {
+		m.log.Warn("Failed to decode hello", "addr", addr.String(), "err", err)
+		m.metrics.DecodeFailed(protocol.MsgHello)
+		return
+	}
+
+	r, err := m.Join(addr, hello)
+	if err != nil {
+		reason := protocol.RejectRoomFull
+		switch {
+		case errors.Is(err, ErrNoSuchRoom):
+			reason = protocol.RejectNoSuchRoom
+		case errors.Is(err, ErrNameTaken):
+			reason = protocol.RejectNameTaken
+		case errors.Is(err, ErrInvalidName):
+			reason = protocol.RejectInvalidName
+		case errors.Is(err, ErrIDTaken):
+			reason = protocol.RejectIDTaken
+		}
+		m.reject(addr, hello.RoomID, reason)
+		return
+	}
+
+	r.State.HandleClient(m.conn, addr, data)
+}
+
+func (m *Manager) reject(addr *net.UDPAddr, roomID uint32, reason protocol.RejectReason) {
+	m.send(addr, protocol.MsgJoinRejected, protocol.JoinRejected{RoomID: roomID, Reason: reason})
+}
+
+func (m *Manager) send(addr *net.UDPAddr, msgType protocol.MsgType, payload any) {
+	data, err := protocol.Encode(msgType, payload)
+	if err != nil {
+		m.log.Error("Failed to encode message", "type", msgType.String(), "err", err)
+		return
+	}
+	m.write(addr, data)
+}
+
+func (m *Manager) write(addr *net.UDPAddr, data []byte) {
+	if _, err := m.conn.WriteToUDP(data, addr); err != nil {
+		m.log.Warn("Failed to send message", "addr", addr.String(), "err", err)
+	}
+}
@@ -0,0 +1,515 @@
+package room
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zainokta/client-server-multiplayer/server/metrics"
+	"github.com/zainokta/client-server-multiplayer/server/player"
+	"github.com/zainokta/client-server-multiplayer/server/world"
+	"github.com/zainokta/client-server-multiplayer/shared/clock"
+	"github.com/zainokta/client-server-multiplayer/shared/netsim"
+	"github.com/zainokta/client-server-multiplayer/shared/protocol"
+	"github.com/zainokta/client-server-multiplayer/shared/reliable"
+)
+
+type recordingConn struct {
+	mu      sync.Mutex
+	written map[int][][]byte
+}
+
+func (c *recordingConn) WriteToUDP(b []byte, addr *net.UDPAddr) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.written == nil {
+		c.written = make(map[int][][]byte)
+	}
+	c.written[addr.Port] = append(c.written[addr.Port], append([]byte(nil), b...))
+	return len(b), nil
+}
+
+func (c *recordingConn) messages(addr *net.UDPAddr, msgType protocol.MsgType) [][]byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var found [][]byte
+	for _, data := range c.written[addr.Port] {
+		if t, _ := protocol.Type(data); t == msgType {
+			found = append(found, data)
+		}
+	}
+	return found
+}
+
+func addrFor(port int) *net.UDPAddr {
+	return &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: port}
+}
+
+func newManager(conn *recordingConn, capacity int, opts ...Option) *Manager {
+	return NewManager(conn, func(id uint32) Settings {
+		return Settings{Capacity: capacity, TickRate: 10, World: world.New(20, 10)}
+	}, opts...)
+}
+
+// helloFor builds a hello with a name unique to the player ID.
+func helloFor(playerID int32, roomID uint32, code protocol.JoinCode) protocol.Hello {
+	name, _ := protocol.ParseName(fmt.Sprintf("player%d", playerID))
+	return protocol.Hello{PlayerID: playerID, RoomID: roomID, Code: code, Name: name}
+}
+
+func hello(t *testing.T, m *Manager, addr *net.UDPAddr, playerID int32, roomID uint32) {
+	data, err := protocol.Encode(protocol.MsgHello, helloFor(playerID, roomID, protocol.JoinCode{}))
+	assert.NoError(t, err)
+	m.Handle(addr, data)
+}
+
+func update(t *testing.T, m *Manager, addr *net.UDPAddr, p player.Player) {
+	p.Timestamp = time.Now().UnixMilli()
+	data, err := player.SerializePlayer(p)
+	assert.NoError(t, err)
+	m.Handle(addr, data)
+}
+
+func TestJoinAssignsFirstFreeRoom(t *testing.T) {
+	m := newManager(&recordingConn{}, 2)
+	first := m.Create()
+
+	for port := 9001; port <= 9002; port++ {
+		r, err := m.Join(addrFor(port), helloFor(int32(port), 0, protocol.JoinCode{}))
+		assert.NoError(t, err)
+		assert.Equal(t, first.ID, r.ID)
+	}
+
+	overflow, err := m.Join(addrFor(9003), helloFor(3, 0, protocol.JoinCode{}))
+	assert.NoError(t, err)
+	assert.NotEqual(t, first.ID, overflow.ID, "a new room is opened once every room is full")
+
+	rooms := m.Rooms()
+	assert.Len(t, rooms, 2)
+	assert.Equal(t, 2, rooms[0].Players)
+	assert.Equal(t, 1, rooms[1].Players)
+
+	again, err := m.Join(addrFor(9001), helloFor(9001, 0, protocol.JoinCode{}))
+	assert.NoError(t, err)
+	assert.Equal(t, first.ID, again.ID, "a repeated hello keeps the client in its room")
+}
+
+func TestJoinRejections(t *testing.T) {
+	m := newManager(&recordingConn{}, 1, WithMaxRooms(1))
+	r := m.Create()
+
+	_, err := m.Join(addrFor(9001), helloFor(1, 42, protocol.JoinCode{}))
+	assert.ErrorIs(t, err, ErrNoSuchRoom)
+
+	_, err = m.Join(addrFor(9001), helloFor(1, r.ID, protocol.JoinCode{}))
+	assert.NoError(t, err)
+
+	_, err = m.Join(addrFor(9002), helloFor(2, r.ID, protocol.JoinCode{}))
+	assert.ErrorIs(t, err, ErrRoomFull)
+
+	_, err = m.Join(addrFor(9002), helloFor(2, 0, protocol.JoinCode{}))
+	assert.ErrorIs(t, err, ErrRoomFull, "no room may be opened beyond the limit")
+}
+
+func TestHelloRejectedWhenRoomMissing(t *testing.T) {
+	conn := &recordingConn{}
+	m := newManager(conn, 0)
+	m.Create()
+
+	hello(t, m, addrFor(9001), 1, 7)
+
+	rejections := conn.messages(addrFor(9001), protocol.MsgJoinRejected)
+	assert.Len(t, rejections, 1)
+
+	var rejected protocol.JoinRejected
+	assert.NoError(t, protocol.Decode(rejections[0], protocol.MsgJoinRejected, &rejected))
+	assert.Equal(t, uint32(7), rejected.RoomID)
+	assert.Equal(t, protocol.RejectNoSuchRoom, rejected.Reason)
+}
+
+func TestSwitchRoom(t *testing.T) {
+	conn := &recordingConn{}
+	m := newManager(conn, 0)
+	first := m.Create()
+	second := m.Create()
+	addr := addrFor(9001)
+
+	hello(t, m, addr, 1, first.ID)
+	update(t, m, addr, player.Player{ID: 1, X: 10, Y: 5, Sequence: 1})
+	first.State.Step(conn)
+
+	_, exists := first.State.Players.Load(int32(1))
+	assert.True(t, exists)
+
+	hello(t, m, addr, 1, second.ID)
+
+	_, exists = first.State.Players.Load(int32(1))
+	assert.False(t, exists, "the player leaves its old room")
+
+	r, ok := m.RoomOf(addr)
+	assert.True(t, ok)
+	assert.Equal(t, second.ID, r.ID)
+
+	var welcome protocol.Welcome
+	welcomes := conn.messages(addr, protocol.MsgWelcome)
+	assert.NoError(t, protocol.Decode(welcomes[len(welcomes)-1], protocol.MsgWelcome, &welcome))
+	assert.Equal(t, second.ID, welcome.RoomID)
+}
+
+func TestBroadcastsStayInsideRooms(t *testing.T) {
+	conn := &recordingConn{}
+	m := newManager(conn, 0)
+	first := m.Create()
+	second := m.Create()
+
+	hello(t, m, addrFor(9001), 1, first.ID)
+	hello(t, m, addrFor(9002), 2, second.ID)
+	update(t, m, addrFor(9001), player.Player{ID: 1, X: 10, Y: 5, Sequence: 1})
+	update(t, m, addrFor(9002), player.Player{ID: 2, X: 11, Y: 5, Sequence: 1})
+
+	for _, r := range []*Room{first, second} {
+		r.State.Step(conn)
+		r.State.Broadcast(conn)
+	}
+
+	for port, other := range map[int]int32{9001: 2, 9002: 1} {
+		for _, data := range conn.messages(addrFor(port), protocol.MsgEntityEnter) {
+			var entered player.Player
+			assert.NoError(t, protocol.Decode(data, protocol.MsgEntityEnter, &entered))
+			assert.NotEqual(t, other, entered.ID, "player %d leaked into another room", other)
+		}
+	}
+}
+
+func TestUpdatesOutsideRoomsAreDropped(t *testing.T) {
+	conn := &recordingConn{}
+	m := newManager(conn, 0)
+	r := m.Create()
+
+	update(t, m, addrFor(9001), player.Player{ID: 1, X: 10, Y: 5, Sequence: 1})
+	r.State.Step(conn)
+
+	_, exists := r.State.Players.Load(int32(1))
+	assert.False(t, exists)
+}
+
+func TestSilentSessionsExpire(t *testing.T) {
+	m := newManager(&recordingConn{}, 1)
+	m.Create()
+
+	_, err := m.Join(addrFor(9001), helloFor(1, 0, protocol.JoinCode{}))
+	assert.NoError(t, err)
+	_, err = m.Join(addrFor(9002), helloFor(2, 0, protocol.JoinCode{}))
+	assert.NoError(t, err)
+	assert.Len(t, m.Rooms(), 2)
+
+	m.expire(time.Now().Add(SessionTimeout + time.Second))
+
+	rooms := m.Rooms()
+	assert.Len(t, rooms, 1, "empty rooms opened on demand are closed")
+	assert.Zero(t, rooms[0].Players)
+
+	_, ok := m.RoomOf(addrFor(9001))
+	assert.False(t, ok)
+}
+
+func TestRunExpiresSessionsOnItsClock(t *testing.T) {
+	clk := clock.NewFake(time.Unix(0, 0))
+	m := newManager(&recordingConn{}, 0, WithClock(clk))
+	m.Create()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		m.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	_, err := m.Join(addrFor(9001), helloFor(1, 0, protocol.JoinCode{}))
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool { return clk.Waiting() >= 3 }, time.Second, time.Millisecond)
+	clk.Advance(SessionTimeout)
+	_, ok := m.RoomOf(addrFor(9001))
+	assert.True(t, ok, "the session is not silent for long enough yet")
+
+	assert.Eventually(t, func() bool {
+		clk.Advance(time.Second)
+		_, ok := m.RoomOf(addrFor(9001))
+		return !ok
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestRunStartsRoomLoops(t *testing.T) {
+	conn := &recordingConn{}
+	m := newManager(conn, 0)
+	r := m.Create()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		m.Run(ctx)
+		close(done)
+	}()
+
+	addr := addrFor(9001)
+	hello(t, m, addr, 1, 0)
+	update(t, m, addr, player.Player{ID: 1, X: 10, Y: 5, Sequence: 1})
+
+	assert.Eventually(t, func() bool {
+		_, exists := r.State.Players.Load(int32(1))
+		return exists && len(conn.messages(addr, protocol.MsgEntityEnter)) > 0
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+}
+
+// infos returns the PlayerInfo messages sent reliably to addr.
+func infos(t *testing.T, conn *recordingConn, addr *net.UDPAddr) []protocol.PlayerInfo {
+	var found []protocol.PlayerInfo
+	for _, data := range conn.messages(addr, protocol.MsgReliable) {
+		_, inner, err := protocol.DecodeReliable(data)
+		assert.NoError(t, err)
+
+		var info protocol.PlayerInfo
+		if protocol.Decode(inner, protocol.MsgPlayerInfo, &info) == nil {
+			found = append(found, info)
+		}
+	}
+	return found
+}
+
+func TestJoinValidatesNames(t *testing.T) {
+	m := newManager(&recordingConn{}, 0)
+	m.Create()
+
+	alice := helloFor(1, 0, protocol.JoinCode{})
+	alice.Name, _ = protocol.ParseName("Alice")
+	_, err := m.Join(addrFor(9001), alice)
+	assert.NoError(t, err)
+
+	_, err = m.Join(addrFor(9001), alice)
+	assert.NoError(t, err, "a repeated hello keeps its own name")
+
+	other := helloFor(2, 0, protocol.JoinCode{})
+	other.Name, _ = protocol.ParseName("alice")
+	_, err = m.Join(addrFor(9002), other)
+	assert.ErrorIs(t, err, ErrNameTaken, "names are compared ignoring case")
+
+	other.Name = protocol.Name{}
+	_, err = m.Join(addrFor(9002), other)
+	assert.ErrorIs(t, err, ErrInvalidName)
+
+	other.Name = protocol.Name{'b', 0, 'b'}
+	_, err = m.Join(addrFor(9002), other)
+	assert.ErrorIs(t, err, ErrInvalidName)
+}
+
+func TestJoinIntroducesPlayers(t *testing.T) {
+	conn := &recordingConn{}
+	m := newManager(conn, 0)
+	first := m.Create()
+	second := m.Create()
+
+	hello(t, m, addrFor(9001), 1, first.ID)
+
+	colored := helloFor(2, first.ID, protocol.JoinCode{})
+	colored.Color = protocol.ColorCyan
+	_, err := m.Join(addrFor(9002), colored)
+	assert.NoError(t, err)
+
+	hello(t, m, addrFor(9003), 3, second.ID)
+
+	assert.Equal(t, []protocol.PlayerInfo{helloInfo(1), {PlayerID: 2, Name: colored.Name, Color: protocol.ColorCyan}},
+		infos(t, conn, addrFor(9001)))
+	assert.ElementsMatch(t, []protocol.PlayerInfo{helloInfo(1), {PlayerID: 2, Name: colored.Name, Color: protocol.ColorCyan}},
+		infos(t, conn, addrFor(9002)))
+	assert.Equal(t, []protocol.PlayerInfo{helloInfo(3)}, infos(t, conn, addrFor(9003)), "other rooms are not told")
+}
+
+func helloInfo(playerID int32) protocol.PlayerInfo {
+	hello := helloFor(playerID, 0, protocol.JoinCode{})
+	return protocol.PlayerInfo{PlayerID: playerID, Name: hello.Name}
+}
+
+func TestPlayerInfoResentUntilAcknowledged(t *testing.T) {
+	conn := &recordingConn{}
+	m := newManager(conn, 0)
+	m.Create()
+	addr := addrFor(9001)
+
+	hello(t, m, addr, 1, 0)
+	sent := conn.messages(addr, protocol.MsgReliable)
+	assert.Len(t, sent, 1)
+
+	m.resend(time.Now().Add(reliable.ResendInterval))
+	assert.Len(t, conn.messages(addr, protocol.MsgReliable), 2)
+
+	seq, _, err := protocol.DecodeReliable(sent[0])
+	assert.NoError(t, err)
+	ack, err := protocol.Encode(protocol.MsgAck, protocol.Ack{Seq: seq})
+	assert.NoError(t, err)
+	m.Handle(addr, ack)
+
+	m.resend(time.Now().Add(2 * reliable.ResendInterval))
+	assert.Len(t, conn.messages(addr, protocol.MsgReliable), 2, "acknowledged messages are not resent")
+}
+
+func TestUnacknowledgedClientIsDropped(t *testing.T) {
+	conn := &recordingConn{}
+	m := newManager(conn, 0)
+	m.Create()
+	addr := addrFor(9001)
+
+	hello(t, m, addr, 1, 0)
+	now := time.Now()
+	for i := 1; i < reliable.MaxAttempts; i++ {
+		m.resend(now.Add(time.Duration(i) * reliable.ResendInterval))
+	}
+	assert.Equal(t, 1, m.Rooms()[0].Players, "the client is kept while the message is resent")
+
+	m.resend(now.Add(reliable.MaxAttempts * reliable.ResendInterval))
+	assert.Zero(t, m.Rooms()[0].Players, "a message that never arrived drops the client")
+	assert.Len(t, conn.messages(addr, protocol.MsgReliable), reliable.MaxAttempts)
+}
+
+func TestSessionLogs(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	m := newManager(&recordingConn{}, 0, WithLogger(logger))
+	first := m.Create()
+	second := m.Create()
+
+	hello(t, m, addrFor(9001), 1, first.ID)
+	hello(t, m, addrFor(9002), 2, first.ID)
+	hello(t, m, addrFor(9001), 1, second.ID)
+	update(t, m, addrFor(9003), player.Player{ID: 3, Sequence: 1})
+
+	var joined, switched []map[string]any
+	dropped := false
+	for _, line := range bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n")) {
+		var record map[string]any
+		assert.NoError(t, json.Unmarshal(line, &record))
+		switch record["msg"] {
+		case "Player joined":
+			joined = append(joined, record)
+		case "Player switches rooms":
+			switched = append(switched, record)
+		case "Dropping packet from outside of any room":
+			dropped = record["level"] == "DEBUG" && record["addr"] == "127.0.0.1:9003"
+		}
+	}
+
+	if assert.Len(t, joined, 3) {
+		assert.Equal(t, map[string]any{"session": 1.0, "player": 1.0, "addr": "127.0.0.1:9001", "room": float64(first.ID)},
+			pick(joined[0], "session", "player", "addr", "room"))
+		assert.Equal(t, 2.0, joined[1]["session"])
+		assert.Equal(t, 1.0, joined[2]["session"], "switching rooms keeps the session")
+		assert.Equal(t, float64(second.ID), joined[2]["room"])
+	}
+	if assert.Len(t, switched, 1) {
+		assert.Equal(t, "127.0.0.1:9001", switched[0]["addr"])
+	}
+	assert.True(t, dropped, "packets are logged at debug")
+}
+
+func pick(record map[string]any, keys ...string) map[string]any {
+	picked := make(map[string]any)
+	for _, key := range keys {
+		picked[key] = record[key]
+	}
+	return picked
+}
+
+func TestJoinRejectsTakenID(t *testing.T) {
+	conn := &recordingConn{}
+	m := newManager(conn, 0)
+	first := m.Create()
+	second := m.Create()
+
+	_, err := m.Join(addrFor(9001), helloFor(1, first.ID, protocol.JoinCode{}))
+	assert.NoError(t, err)
+
+	taken := helloFor(1, first.ID, protocol.JoinCode{})
+	taken.Name, _ = protocol.ParseName("copycat")
+	_, err = m.Join(addrFor(9002), taken)
+	assert.ErrorIs(t, err, ErrIDTaken)
+
+	taken.RoomID = second.ID
+	_, err = m.Join(addrFor(9002), taken)
+	assert.NoError(t, err, "IDs only need to be unique within a room")
+
+	_, err = m.Join(addrFor(9003), helloFor(3, second.ID, protocol.JoinCode{}))
+	assert.NoError(t, err)
+	changed := helloFor(3, second.ID, protocol.JoinCode{})
+	changed.PlayerID = 1
+	_, err = m.Join(addrFor(9003), changed)
+	assert.ErrorIs(t, err, ErrIDTaken, "a seated client cannot take the ID of another")
+
+	taken.RoomID = first.ID
+	data, err := protocol.Encode(protocol.MsgHello, taken)
+	assert.NoError(t, err)
+	m.Handle(addrFor(9002), data)
+
+	rejections := conn.messages(addrFor(9002), protocol.MsgJoinRejected)
+	assert.Len(t, rejections, 1)
+	var rejected protocol.JoinRejected
+	assert.NoError(t, protocol.Decode(rejections[0], protocol.MsgJoinRejected, &rejected))
+	assert.Equal(t, protocol.RejectIDTaken, rejected.Reason)
+}
+
+func TestUpdatesForAnotherPlayerAreDropped(t *testing.T) {
+	conn := &recordingConn{}
+	m := newManager(conn, 0)
+	r := m.Create()
+	hello(t, m, addrFor(9001), 1, r.ID)
+	hello(t, m, addrFor(9002), 2, r.ID)
+	update(t, m, addrFor(9002), player.Player{ID: 2, X: 5, Y: 5, Sequence: 1})
+	r.State.Step(conn)
+
+	update(t, m, addrFor(9001), player.Player{ID: 2, X: 6, Y: 5, Sequence: 2})
+	action, err := protocol.Encode(protocol.MsgAction, protocol.Action{PlayerID: 2, Kind: protocol.ActionTag, Target: 1, Sequence: 1})
+	assert.NoError(t, err)
+	m.Handle(addrFor(9001), action)
+	r.State.Step(conn)
+
+	value, exists := r.State.Players.Load(int32(2))
+	assert.True(t, exists)
+	assert.Equal(t, float32(5), value.(player.Player).X, "only the client of a player moves it")
+	_, exists = r.State.Players.Load(int32(1))
+	assert.False(t, exists)
+	assert.Empty(t, conn.messages(addrFor(9001), protocol.MsgActionResult))
+
+	addr, _ := r.State.Clients.Load(int32(2))
+	assert.Equal(t, addrFor(9002).String(), addr.(*net.UDPAddr).String(), "the snapshots of a player keep going to its client")
+}
+
+func TestSimulatedLossIsNotCountedAsSent(t *testing.T) {
+	conn := &recordingConn{}
+	stats := metrics.New()
+	m := newManager(conn, 0, WithMetrics(stats), WithNetworkConditions(netsim.Conditions{Loss: 1, Seed: 1}))
+	m.Create()
+
+	hello(t, m, addrFor(9001), 1, 0)
+	_, seated := m.RoomOf(addrFor(9001))
+	assert.True(t, seated)
+	assert.Empty(t, conn.messages(addrFor(9001), protocol.MsgWelcome), "the welcome is lost on the way")
+
+	var out bytes.Buffer
+	stats.Write(&out)
+	assert.NotContains(t, out.String(), `game_packets_sent_total{type="welcome"}`)
+}
@@ -0,0 +1,113 @@
+package room
+
+import (
+	"net"
+	"time"
+
+	"github.com/zainokta/client-server-multiplayer/shared/protocol"
+	"github.com/zainokta/client-server-multiplayer/shared/reliable"
+)
+
+// peer is the reliable channel with one client.
+type peer struct {
+	addr     *net.UDPAddr
+	endpoint *reliable.Endpoint
+}
+
+// peer returns the reliable channel with addr, opening it on first use. The
+// caller holds the manager lock.
+func (m *Manager) peer(addr *net.UDPAddr) *peer {
+	key := addr.String()
+	p, exists := m.peers[key]
+	if !exists {
+		p = &peer{addr: addr, endpoint: reliable.NewEndpoint()}
+		m.peers[key] = p
+	}
+	return p
+}
+
+// sendReliable sends a message that is resent until the client acknowledges
+// it. The caller holds the manager lock.
+func (m *Manager) sendReliable(addr *net.UDPAddr, msgType protocol.MsgType, payload any) {
+	inner, err := protocol.Encode(msgType, payload)
+	if err != nil {
+		m.log.Error("Failed to encode message", "type", msgType.String(), "err", err)
+		return
+	}
+	m.writeReliable(addr, inner)
+}
+
+// writeReliable sends an encoded message like sendReliable. The caller holds
+// the manager lock.
+func (m *Manager) writeReliable(addr *net.UDPAddr, inner []byte) {
+	data, err := m.peer(addr).endpoint.Wrap(inner, m.clock.Now())
+	if err != nil {
+		m.log.Error("Failed to wrap reliable message", "addr", addr.String(), "err", err)
+		return
+	}
+	m.write(addr, data)
+}
+
+func (m *Manager) resend(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, p := range m.peers {
+		due, err := p.endpoint.Resend(now)
+		if err != nil {
+			// Later messages would wait for the lost one forever, so the
+			// client is dropped instead.
+			m.log.Warn("Dropping unreachable client", "addr", p.addr.String(), "err", err)
+			if s, seated := m.sessions[key]; seated {
+				m.leave(key, s)
+			}
+			delete(m.peers, key)
+			continue
+		}
+		for _, data := range due {
+			m.write(p.addr, data)
+		}
+	}
+}
+
+func (m *Manager) handleAck(addr *net.UDPAddr, data []byte) {
+	var ack protocol.Ack
+	if err := protocol.Decode(data, protocol.MsgAck, &ack); err != nil {
+		m.log.Warn("Failed to decode ack", "addr", addr.String(), "err", err)
+		m.metrics.DecodeFailed(protocol.MsgAck)
+		return
+	}
+
+	m.mu.Lock()
+	p, exists := m.peers[addr.String()]
+	m.mu.Unlock()
+
+	if exists {
+		p.endpoint.Ack(ack.Seq)
+	}
+}
+
+// handleReliable acknowledges a reliable message and handles every message
+// that is now ready in order like any other packet.
+func (m *Manager) handleReliable(addr *net.UDPAddr, data []byte) {
+	m.mu.Lock()
+	p := m.peer(addr)
+	m.mu.Unlock()
+
+	ack, ready, err := p.endpoint.Receive(data)
+	if err != nil {
+		m.log.Warn("Failed to decode reliable message", "addr", addr.String(), "err", err)
+		m.metrics.DecodeFailed(protocol.MsgReliable)
+		return
+	}
+	if ack != nil {
+		m.write(addr, ack)
+	}
+
+	for _, inner := range ready {
+		if t, _ := protocol.Type(inner); t == protocol.MsgReliable {
+			continue
+		}
+		m.Handle(addr, inner)
+	}
+}
@@ -0,0 +1,119 @@
+package room
+
+import (
+	"net"
+	"time"
+
+	"github.com/zainokta/client-server-multiplayer/shared/protocol"
+)
+
+const (
+	PingInterval = time.Second
+	// PingWindow is how many of the latest pings packet loss is measured
+	// over.
+	PingWindow = 20
+)
+
+type pingSample struct {
+	seq      uint32
+	sent     time.Time
+	answered bool
+}
+
+// latency measures the round trip time and packet loss of a client from the
+// pings it answers.
+type latency struct {
+	nextSeq uint32
+	samples [PingWindow]pingSample
+	// rtt is smoothed over recent pongs.
+	rtt time.Duration
+}
+
+func (l *latency) next(now time.Time) protocol.Ping {
+	l.nextSeq++
+	l.samples[l.nextSeq%PingWindow] = pingSample{seq: l.nextSeq, sent: now}
+	return protocol.Ping{Seq: l.nextSeq, SentAt: now.UnixMilli()}
+}
+
+// answer records a pong. Late pongs whose sample was overwritten and
+// duplicates are ignored.
+func (l *latency) answer(seq uint32, now time.Time) {
+	sample := &l.samples[seq%PingWindow]
+	if sample.seq != seq || sample.answered {
+		return
+	}
+	sample.answered = true
+
+	rtt := now.Sub(sample.sent)
+	if l.rtt == 0 {
+		l.rtt = rtt
+		return
+	}
+	l.rtt += (rtt - l.rtt) / 8
+}
+
+// loss returns the share of pings that went unanswered, ignoring pings sent
+// within the last PingInterval that may still be answered.
+func (l *latency) loss(now time.Time) float64 {
+	sent, lost := 0, 0
+	for _, sample := range l.samples {
+		if sample.seq == 0 || now.Sub(sample.sent) < PingInterval {
+			continue
+		}
+		sent++
+		if !sample.answered {
+			lost++
+		}
+	}
+	if sent == 0 {
+		return 0
+	}
+	return float64(lost) / float64(sent)
+}
+
+// ping probes every client and adapts its snapshot rate to the loss measured
+// so far.
+func (m *Manager) ping(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, s := range m.sessions {
+		s.room.State.AdaptSendRate(s.playerID, s.latency.loss(now))
+		m.send(s.addr, protocol.MsgPing, s.latency.next(now))
+	}
+}
+
+// handlePing answers the latency probe of a seated client right away.
+func (m *Manager) handlePing(addr *net.UDPAddr, data []byte) {
+	var ping protocol.Ping
+	if err := protocol.Decode(data, protocol.MsgPing, &ping); err != nil {
+		m.log.Warn("Failed to decode ping", "addr", addr.String(), "err", err)
+		m.metrics.DecodeFailed(protocol.MsgPing)
+		return
+	}
+
+	m.mu.Lock()
+	_, exists := m.sessions[addr.String()]
+	m.mu.Unlock()
+
+	if exists {
+		m.send(addr, protocol.MsgPong, ping)
+	}
+}
+
+func (m *Manager) handlePong(addr *net.UDPAddr, data []byte) {
+	var pong protocol.Ping
+	if err := protocol.Decode(data, protocol.MsgPong, &pong); err != nil {
+		m.log.Warn("Failed to decode pong", "addr", addr.String(), "err", err)
+		m.metrics.DecodeFailed(protocol.MsgPong)
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s, exists := m.sessions[addr.String()]; exists {
+		s.latency.answer(pong.Seq, m.clock.Now())
+		s.room.State.SetRTT(s.playerID, s.latency.rtt)
+	}
+}
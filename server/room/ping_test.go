@@ -0,0 +1,98 @@
+package room
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zainokta/client-server-multiplayer/server/player"
+	"github.com/zainokta/client-server-multiplayer/server/world"
+	"github.com/zainokta/client-server-multiplayer/shared/protocol"
+)
+
+func TestLatency(t *testing.T) {
+	var l latency
+	now := time.Now()
+
+	first := l.next(now)
+	second := l.next(now)
+	assert.Equal(t, uint32(1), first.Seq)
+	assert.Zero(t, l.loss(now), "pings in flight are not lost yet")
+
+	l.answer(first.Seq, now.Add(100*time.Millisecond))
+	l.answer(first.Seq, now.Add(900*time.Millisecond))
+	assert.Equal(t, 100*time.Millisecond, l.rtt, "duplicate pongs are ignored")
+
+	later := now.Add(PingInterval)
+	third := l.next(later)
+	l.answer(third.Seq, later.Add(20*time.Millisecond))
+	assert.Equal(t, 90*time.Millisecond, l.rtt)
+
+	assert.Equal(t, 0.5, l.loss(later), "the second ping was never answered")
+
+	for range PingWindow {
+		later = later.Add(PingInterval)
+		l.next(later)
+	}
+	l.answer(second.Seq, later)
+	assert.Equal(t, 90*time.Millisecond, l.rtt, "pongs older than the window are ignored")
+}
+
+func TestPingPong(t *testing.T) {
+	conn := &recordingConn{}
+	m := newManager(conn, 0)
+	m.Create()
+	addr := addrFor(9001)
+	hello(t, m, addr, 1, 0)
+
+	m.ping(time.Now())
+	pings := conn.messages(addr, protocol.MsgPing)
+	assert.Len(t, pings, 1)
+
+	pong := append([]byte(nil), pings[0]...)
+	pong[0] = byte(protocol.MsgPong)
+	m.Handle(addr, pong)
+
+	players := m.Players()
+	assert.Positive(t, players[0].RTT)
+	assert.Zero(t, players[0].Loss)
+}
+
+func TestServerAnswersPings(t *testing.T) {
+	conn := &recordingConn{}
+	m := newManager(conn, 0)
+	m.Create()
+	ping, err := protocol.Encode(protocol.MsgPing, protocol.Ping{Seq: 3, SentAt: 42})
+	assert.NoError(t, err)
+
+	m.Handle(addrFor(9001), ping)
+	assert.Empty(t, conn.messages(addrFor(9001), protocol.MsgPong), "only seated clients are answered")
+
+	hello(t, m, addrFor(9001), 1, 0)
+	m.Handle(addrFor(9001), ping)
+	pongs := conn.messages(addrFor(9001), protocol.MsgPong)
+	assert.Len(t, pongs, 1)
+
+	var pong protocol.Ping
+	assert.NoError(t, protocol.Decode(pongs[0], protocol.MsgPong, &pong))
+	assert.Equal(t, protocol.Ping{Seq: 3, SentAt: 42}, pong)
+}
+
+func TestPingAdaptsSendRate(t *testing.T) {
+	conn := &recordingConn{}
+	m := NewManager(conn, func(id uint32) Settings {
+		return Settings{TickRate: 10, World: world.New(20, 10), SnapshotRate: 20, AdaptiveSendRate: true, MinSendRate: 5}
+	})
+	r := m.Create()
+	addr := addrFor(9001)
+	hello(t, m, addr, 1, 0)
+	update(t, m, addr, player.Player{ID: 1, X: 1, Y: 1, Sequence: 1})
+	r.State.Step(conn)
+	r.State.Broadcast(conn)
+	assert.Equal(t, 20, m.Players()[0].SendRate)
+
+	now := time.Now()
+	m.ping(now)
+	m.ping(now.Add(PingInterval))
+	assert.Equal(t, 10, m.Players()[0].SendRate, "an unanswered ping halves the rate")
+}
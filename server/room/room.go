@@ -0,0 +1,133 @@
+package room
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/zainokta/client-server-multiplayer/server/game"
+	"github.com/zainokta/client-server-multiplayer/server/metrics"
+	"github.com/zainokta/client-server-multiplayer/server/world"
+	"github.com/zainokta/client-server-multiplayer/shared/clock"
+	"github.com/zainokta/client-server-multiplayer/shared/protocol"
+)
+
+// Settings describe a single room. Every room gets its own copy of the world
+// and runs its own game loop.
+type Settings struct {
+	Name string
+	// Capacity is the maximum number of clients in the room. Zero means
+	// unlimited.
+	Capacity       int
+	TickRate       int
+	World          world.World
+	Mode           game.Mode
+	InterestRadius int
+	GridCellSize   int
+	// SnapshotRate is how many snapshots per second clients are sent. Zero
+	// sends one every tick.
+	SnapshotRate int
+	// AdaptiveSendRate lowers the snapshot rate of clients that lose packets
+	// or exceed ClientBandwidth bytes per second, down to MinSendRate.
+	AdaptiveSendRate bool
+	MinSendRate      int
+	ClientBandwidth  int
+	// LagCompensationWindow is how far back actions may be judged.
+	LagCompensationWindow time.Duration
+	// Movement bounds player moves. Players that keep breaking it are kicked.
+	Movement game.MovementRules
+}
+
+// Room is an independent game instance. Players in different rooms never see
+// each other.
+type Room struct {
+	ID       uint32
+	Name     string
+	Capacity int
+	World    world.World
+	State    *game.GameState
+	// Private rooms are not listed or picked automatically and can only be
+	// joined with their Code.
+	Private bool
+	Code    protocol.JoinCode
+
+	tickRate atomic.Int32
+	// tickDuration is how long the last tick took to step and broadcast.
+	tickDuration atomic.Int64
+	// temporary rooms are created when every other room is full and closed
+	// again once the last player leaves.
+	temporary bool
+	// members is guarded by the manager lock.
+	members int
+	opened  time.Time
+	cancel  context.CancelFunc
+	metrics *metrics.Metrics
+}
+
+func newRoom(id uint32, settings Settings, temporary bool, m *metrics.Metrics, logger *slog.Logger, clk clock.Clock, kick func(addr *net.UDPAddr)) *Room {
+	opts := []game.Option{
+		game.WithRoomID(id),
+		game.WithWorld(settings.World),
+		game.WithMode(settings.Mode),
+		game.WithInterest(settings.InterestRadius, settings.GridCellSize),
+		game.WithSnapshotRate(settings.SnapshotRate),
+		game.WithLagCompensation(settings.LagCompensationWindow),
+		game.WithMovementRules(settings.Movement, kick),
+		game.WithMetrics(m),
+		game.WithLogger(logger.With("room", id)),
+		game.WithClock(clk),
+	}
+	if settings.AdaptiveSendRate {
+		opts = append(opts, game.WithAdaptiveSendRate(settings.MinSendRate, settings.ClientBandwidth))
+	}
+
+	r := &Room{
+		ID:        id,
+		Name:      settings.Name,
+		Capacity:  settings.Capacity,
+		World:     settings.World,
+		State:     game.New(opts...),
+		temporary: temporary,
+		opened:    clk.Now(),
+		metrics:   m,
+	}
+	r.tickRate.Store(int32(max(settings.TickRate, 1)))
+	return r
+}
+
+// TickRate returns how many times per second the room steps.
+func (r *Room) TickRate() int {
+	return int(r.tickRate.Load())
+}
+
+func (r *Room) TickDuration() time.Duration {
+	return time.Duration(r.tickDuration.Load())
+}
+
+// setTickRate changes the tick rate of a running room from its next tick.
+func (r *Room) setTickRate(rate int) {
+	r.tickRate.Store(int32(rate))
+}
+
+func (r *Room) full() bool {
+	return r.Capacity > 0 && r.members >= r.Capacity
+}
+
+// matches reports whether a Hello for roomID and code asks for this room.
+func (r *Room) matches(roomID uint32, code protocol.JoinCode) bool {
+	if code != (protocol.JoinCode{}) {
+		return r.Private && code == r.Code
+	}
+	return roomID == 0 || roomID == r.ID
+}
+
+// run runs the tick loop of the room until ctx is cancelled.
+func (r *Room) run(ctx context.Context, conn game.UDPConn) {
+	r.State.Run(ctx, conn, r.TickRate, func(stats game.TickStats) {
+		r.tickDuration.Store(int64(stats.Duration))
+		r.metrics.ObserveTick(stats.Duration)
+		r.metrics.ObserveBroadcast(stats.Broadcast)
+	})
+}
@@ -0,0 +1,401 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/zainokta/client-server-multiplayer/server/admin"
+	"github.com/zainokta/client-server-multiplayer/server/config"
+	"github.com/zainokta/client-server-multiplayer/server/game"
+	"github.com/zainokta/client-server-multiplayer/server/metrics"
+	"github.com/zainokta/client-server-multiplayer/server/room"
+	"github.com/zainokta/client-server-multiplayer/server/world"
+	"github.com/zainokta/client-server-multiplayer/shared/clock"
+)
+
+var (
+	ErrAlreadyStarted = errors.New("server already started")
+	ErrNotStarted     = errors.New("server not started")
+	ErrAdminNotLocal  = errors.New("admin API must listen on a loopback address")
+	ErrAdminToken     = errors.New("admin API needs a token")
+)
+
+type Option func(*Server)
+
+// WithListenIP overrides the IP the UDP listener binds to. Defaults to 127.0.0.1.
+func WithListenIP(ip net.IP) Option {
+	return func(s *Server) {
+		s.ip = ip
+	}
+}
+
+// WithLogger sets the logger of the server and its rooms. Defaults to
+// slog.Default.
+func WithLogger(l *slog.Logger) Option {
+	return func(s *Server) {
+		s.log = l
+	}
+}
+
+// WithClock sets the clock that times sessions and game loops. Defaults to
+// clock.Real.
+func WithClock(c clock.Clock) Option {
+	return func(s *Server) {
+		s.clock = c
+	}
+}
+
+// WithOnStart registers a hook called once the listener is bound.
+func WithOnStart(fn func(addr net.Addr)) Option {
+	return func(s *Server) {
+		s.onStart = append(s.onStart, fn)
+	}
+}
+
+// WithOnStop registers a hook called after the server has fully shut down.
+func WithOnStop(fn func()) Option {
+	return func(s *Server) {
+		s.onStop = append(s.onStop, fn)
+	}
+}
+
+type Server struct {
+	cfg     config.Config
+	ip      net.IP
+	log     *slog.Logger
+	clock   clock.Clock
+	onStart []func(addr net.Addr)
+	onStop  []func()
+
+	mu      sync.Mutex
+	conn    *net.UDPConn
+	rooms   *room.Manager
+	admin   net.Listener
+	metrics net.Listener
+	cancel  context.CancelFunc
+	// done is closed once the server has shut down.
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func New(cfg config.Config, opts ...Option) *Server {
+	s := &Server{
+		cfg:   cfg,
+		ip:    net.ParseIP("127.0.0.1"),
+		log:   slog.Default(),
+		clock: clock.Real,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Start binds the UDP listener and runs the game loops in the background.
+// The server runs until ctx is cancelled or Stop is called.
+func (s *Server) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn != nil {
+		return ErrAlreadyStarted
+	}
+
+	worlds, err := s.loadWorlds()
+	if err != nil {
+		return err
+	}
+
+	mode, err := s.gameMode()
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkAdmin(); err != nil {
+		return err
+	}
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{Port: s.cfg.Port, IP: s.ip})
+	if err != nil {
+		return err
+	}
+
+	var adminListener, metricsListener net.Listener
+	if s.cfg.AdminAddr != "" {
+		adminListener, err = net.Listen("tcp", s.cfg.AdminAddr)
+		if err != nil {
+			conn.Close()
+			return err
+		}
+	}
+	if s.cfg.MetricsAddr != "" {
+		metricsListener, err = net.Listen("tcp", s.cfg.MetricsAddr)
+		if err != nil {
+			conn.Close()
+			if adminListener != nil {
+				adminListener.Close()
+			}
+			return err
+		}
+	}
+
+	var stats *metrics.Metrics
+	if metricsListener != nil {
+		stats = metrics.New()
+	}
+
+	conditions := s.cfg.NetworkConditions()
+	if conditions.Enabled() {
+		s.log.Warn("Simulating network conditions", "conditions", conditions)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	s.conn = conn
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	s.rooms = room.NewManager(conn, func(id uint32) room.Settings {
+		return room.Settings{
+			Name:                  fmt.Sprintf("room-%d", id),
+			Capacity:              s.cfg.RoomCapacity,
+			TickRate:              s.cfg.GameTickRate,
+			World:                 worlds[int(id-1)%len(worlds)],
+			Mode:                  mode,
+			InterestRadius:        s.cfg.InterestRadius,
+			GridCellSize:          s.cfg.GridCellSize,
+			SnapshotRate:          s.cfg.SnapshotRate,
+			AdaptiveSendRate:      s.cfg.AdaptiveSendRate,
+			MinSendRate:           s.cfg.MinSendRate,
+			ClientBandwidth:       s.cfg.ClientBandwidth,
+			LagCompensationWindow: s.cfg.LagCompensationWindow,
+			Movement: game.MovementRules{
+				MaxSpeed:  s.cfg.MaxSpeed,
+				Burst:     s.cfg.MoveBurst,
+				WarnScore: s.cfg.ViolationWarnScore,
+				KickScore: s.cfg.ViolationKickScore,
+				Decay:     s.cfg.ViolationDecay,
+			},
+		}
+	}, room.WithMaxRooms(s.cfg.MaxRooms), room.WithPartySize(s.cfg.PartySize), room.WithMetrics(stats), room.WithNetworkConditions(conditions), room.WithLogger(s.log), room.WithClock(s.clock))
+
+	for range max(s.cfg.Rooms, 1) {
+		s.rooms.Create()
+	}
+
+	s.wg.Add(2)
+	go func() {
+		defer s.wg.Done()
+		s.rooms.Run(ctx)
+	}()
+	go func() {
+		defer s.wg.Done()
+		s.readLoop(conn, stats)
+	}()
+
+	if adminListener != nil {
+		s.admin = adminListener
+		s.serveHTTP(ctx, adminListener, admin.New(s.rooms, s.cfg.AdminToken), "admin API")
+	}
+
+	if metricsListener != nil {
+		s.metrics = metricsListener
+		mux := http.NewServeMux()
+		mux.Handle("GET /metrics", stats.Handler())
+		s.serveHTTP(ctx, metricsListener, mux, "metrics")
+	}
+
+	go s.shutdown(ctx, conn, s.done)
+
+	for _, fn := range s.onStart {
+		fn(conn.LocalAddr())
+	}
+
+	return nil
+}
+
+// Addr returns the bound listener address, or nil if the server is not running.
+func (s *Server) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.LocalAddr()
+}
+
+// AdminAddr returns the address of the admin API, or nil if it is disabled or
+// the server is not running.
+func (s *Server) AdminAddr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil || s.admin == nil {
+		return nil
+	}
+	return s.admin.Addr()
+}
+
+// MetricsAddr returns the address serving /metrics, or nil if metrics are
+// disabled or the server is not running.
+func (s *Server) MetricsAddr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil || s.metrics == nil {
+		return nil
+	}
+	return s.metrics.Addr()
+}
+
+// Rooms returns the room manager of the running server.
+func (s *Server) Rooms() *room.Manager {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.rooms
+}
+
+// Stop shuts the listener down and waits for all server goroutines to exit.
+func (s *Server) Stop() error {
+	s.mu.Lock()
+	if s.conn == nil {
+		s.mu.Unlock()
+		return ErrNotStarted
+	}
+	cancel, done := s.cancel, s.done
+	s.mu.Unlock()
+
+	cancel()
+	<-done
+	return nil
+}
+
+// shutdown waits for ctx to end, whether Stop cancelled it or its parent was
+// cancelled, then closes the listener, waits for every server goroutine and
+// resets the server so that it can be started again.
+func (s *Server) shutdown(ctx context.Context, conn *net.UDPConn, done chan struct{}) {
+	<-ctx.Done()
+	conn.Close()
+	s.wg.Wait()
+
+	s.mu.Lock()
+	s.conn = nil
+	s.cancel = nil
+	s.admin = nil
+	s.metrics = nil
+	s.mu.Unlock()
+
+	for _, fn := range s.onStop {
+		fn()
+	}
+	close(done)
+}
+
+// serveHTTP serves handler on listener in the wait group until ctx is done.
+func (s *Server) serveHTTP(ctx context.Context, listener net.Listener, handler http.Handler, name string) {
+	httpServer := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	s.wg.Add(2)
+	go func() {
+		defer s.wg.Done()
+		if err := httpServer.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+			s.log.Error("Failed to serve "+name, "err", err)
+		}
+	}()
+	go func() {
+		defer s.wg.Done()
+		<-ctx.Done()
+		httpServer.Close()
+	}()
+}
+
+// checkAdmin makes sure the admin API, when enabled, is only reachable from
+// the machine itself and protected by a token.
+func (s *Server) checkAdmin() error {
+	if s.cfg.AdminAddr == "" {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(s.cfg.AdminAddr)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return ErrAdminNotLocal
+	}
+
+	if s.cfg.AdminToken == "" {
+		return ErrAdminToken
+	}
+	return nil
+}
+
+func (s *Server) gameMode() (game.Mode, error) {
+	name := s.cfg.GameMode
+	if name == "" {
+		name = "classic"
+	}
+
+	mode, err := game.LookupMode(name)
+	if err != nil {
+		return mode, err
+	}
+
+	if s.cfg.CollisionRule != "" {
+		mode.Collision, err = game.ParseCollisionRule(s.cfg.CollisionRule)
+	}
+	return mode, err
+}
+
+// loadWorlds loads the maps rooms cycle through. Without any map configured
+// every room gets an open world of the configured size.
+func (s *Server) loadWorlds() ([]world.World, error) {
+	paths := s.cfg.RoomMaps
+	if len(paths) == 0 && s.cfg.MapPath != "" {
+		paths = []string{s.cfg.MapPath}
+	}
+	if len(paths) == 0 {
+		if err := world.CheckSize(s.cfg.WorldWidth, s.cfg.WorldHeight); err != nil {
+			return nil, err
+		}
+		return []world.World{world.New(s.cfg.WorldWidth, s.cfg.WorldHeight)}, nil
+	}
+
+	worlds := make([]world.World, 0, len(paths))
+	for _, path := range paths {
+		w, err := world.Load(path)
+		if err != nil {
+			return nil, err
+		}
+		worlds = append(worlds, w)
+	}
+	return worlds, nil
+}
+
+func (s *Server) readLoop(conn *net.UDPConn, stats *metrics.Metrics) {
+	for {
+		buf := make([]byte, 1024)
+		n, addr, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			s.log.Warn("Failed to read packet", "err", err)
+			continue
+		}
+
+		stats.Received(buf[:n])
+		go s.rooms.Handle(addr, buf[:n])
+	}
+}
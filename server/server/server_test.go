@@ -0,0 +1,192 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zainokta/client-server-multiplayer/server/config"
+	"github.com/zainokta/client-server-multiplayer/server/world"
+	"github.com/zainokta/client-server-multiplayer/shared/protocol"
+)
+
+func TestServerLifecycle(t *testing.T) {
+	var startedAddr net.Addr
+	stopped := false
+
+	srv := New(config.Config{Port: 0, GameTickRate: 10, WorldWidth: 20, WorldHeight: 10},
+		WithOnStart(func(addr net.Addr) { startedAddr = addr }),
+		WithOnStop(func() { stopped = true }),
+	)
+
+	assert.Nil(t, srv.Addr())
+	assert.ErrorIs(t, srv.Stop(), ErrNotStarted)
+
+	err := srv.Start(context.Background())
+	assert.NoError(t, err)
+
+	addr := srv.Addr().(*net.UDPAddr)
+	assert.NotZero(t, addr.Port)
+	assert.Equal(t, addr, startedAddr)
+	assert.Len(t, srv.Rooms().Rooms(), 1)
+
+	assert.ErrorIs(t, srv.Start(context.Background()), ErrAlreadyStarted)
+
+	assert.NoError(t, srv.Stop())
+	assert.True(t, stopped)
+	assert.Nil(t, srv.Addr())
+}
+
+func TestServerStopsOnContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{}, 2)
+
+	srv := New(config.Config{Port: 0, GameTickRate: 10, WorldWidth: 20, WorldHeight: 10}, WithOnStop(func() { stopped <- struct{}{} }))
+	assert.NoError(t, srv.Start(ctx))
+
+	cancel()
+
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("server did not stop")
+	}
+	assert.Nil(t, srv.Addr())
+	assert.ErrorIs(t, srv.Stop(), ErrNotStarted)
+
+	assert.NoError(t, srv.Start(context.Background()), "the server can be started again")
+	assert.NoError(t, srv.Stop())
+}
+
+func TestServerStopAfterContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	srv := New(config.Config{Port: 0, GameTickRate: 10, WorldWidth: 20, WorldHeight: 10})
+	assert.NoError(t, srv.Start(ctx))
+
+	cancel()
+
+	err := srv.Stop()
+	if err != nil {
+		assert.ErrorIs(t, err, ErrNotStarted, "the server may have stopped already")
+	}
+	assert.Nil(t, srv.Addr())
+}
+
+func TestServerLoadsMap(t *testing.T) {
+	srv := New(config.Config{Port: 0, GameTickRate: 10, MapPath: "../maps/arena.txt"})
+	assert.NoError(t, srv.Start(context.Background()))
+	assert.NoError(t, srv.Stop())
+
+	srv = New(config.Config{Port: 0, GameTickRate: 10, MapPath: "missing.txt"})
+	assert.Error(t, srv.Start(context.Background()))
+	assert.Nil(t, srv.Addr())
+}
+
+func TestServerRejectsInvalidWorldSize(t *testing.T) {
+	for _, size := range [][2]int{{0, 0}, {20, 0}, {-5, 10}, {2, 10}, {world.MaxWidth + 1, 10}} {
+		srv := New(config.Config{Port: 0, GameTickRate: 10, WorldWidth: size[0], WorldHeight: size[1]})
+		assert.ErrorIs(t, srv.Start(context.Background()), world.ErrWorldSize, "%dx%d", size[0], size[1])
+		assert.Nil(t, srv.Addr())
+	}
+
+	srv := New(config.Config{Port: 0, GameTickRate: 10, WorldWidth: world.MinSize, WorldHeight: world.MinSize})
+	assert.NoError(t, srv.Start(context.Background()))
+	assert.NoError(t, srv.Stop())
+}
+
+func TestServerRejectsUnknownGameMode(t *testing.T) {
+	srv := New(config.Config{Port: 0, GameTickRate: 10, WorldWidth: 20, WorldHeight: 10, GameMode: "unknown"})
+	assert.Error(t, srv.Start(context.Background()))
+
+	srv = New(config.Config{Port: 0, GameTickRate: 10, WorldWidth: 20, WorldHeight: 10, GameMode: "classic", CollisionRule: "bounce"})
+	assert.Error(t, srv.Start(context.Background()))
+}
+
+func TestServerAdminAPI(t *testing.T) {
+	srv := New(config.Config{Port: 0, GameTickRate: 10, WorldWidth: 20, WorldHeight: 10})
+	assert.NoError(t, srv.Start(context.Background()))
+	assert.Nil(t, srv.AdminAddr(), "the admin API is disabled by default")
+	assert.NoError(t, srv.Stop())
+
+	srv = New(config.Config{Port: 0, GameTickRate: 10, WorldWidth: 20, WorldHeight: 10, AdminAddr: "0.0.0.0:0", AdminToken: "secret"})
+	assert.ErrorIs(t, srv.Start(context.Background()), ErrAdminNotLocal)
+
+	srv = New(config.Config{Port: 0, GameTickRate: 10, WorldWidth: 20, WorldHeight: 10, AdminAddr: "127.0.0.1:0"})
+	assert.ErrorIs(t, srv.Start(context.Background()), ErrAdminToken)
+
+	srv = New(config.Config{Port: 0, GameTickRate: 10, WorldWidth: 20, WorldHeight: 10, AdminAddr: "127.0.0.1:0", AdminToken: "secret"})
+	assert.NoError(t, srv.Start(context.Background()))
+
+	req, err := http.NewRequest("GET", "http://"+srv.AdminAddr().String()+"/rooms", nil)
+	assert.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer secret")
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	addr := srv.AdminAddr().String()
+	assert.NoError(t, srv.Stop())
+	_, err = net.Dial("tcp", addr)
+	assert.Error(t, err, "the admin API stops with the server")
+}
+
+func TestServerMetrics(t *testing.T) {
+	srv := New(config.Config{Port: 0, GameTickRate: 10, WorldWidth: 20, WorldHeight: 10})
+	assert.NoError(t, srv.Start(context.Background()))
+	assert.Nil(t, srv.MetricsAddr(), "metrics are disabled by default")
+	assert.NoError(t, srv.Stop())
+
+	srv = New(config.Config{Port: 0, GameTickRate: 10, WorldWidth: 20, WorldHeight: 10, MetricsAddr: "127.0.0.1:0"})
+	assert.NoError(t, srv.Start(context.Background()))
+	defer srv.Stop()
+
+	client, err := net.DialUDP("udp", nil, srv.Addr().(*net.UDPAddr))
+	assert.NoError(t, err)
+	defer client.Close()
+
+	name, err := protocol.ParseName("alice")
+	assert.NoError(t, err)
+	hello, err := protocol.Encode(protocol.MsgHello, protocol.Hello{PlayerID: 1, Name: name})
+	assert.NoError(t, err)
+	_, err = client.Write(hello)
+	assert.NoError(t, err)
+	_, err = client.Write(hello[:3])
+	assert.NoError(t, err)
+
+	buf := make([]byte, 2048)
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, err = client.Read(buf)
+	assert.NoError(t, err)
+
+	var body string
+	assert.Eventually(t, func() bool {
+		resp, err := http.Get("http://" + srv.MetricsAddr().String() + "/metrics")
+		if !assert.NoError(t, err) {
+			return false
+		}
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Contains(t, resp.Header.Get("Content-Type"), "text/plain")
+
+		data, err := io.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		body = string(data)
+		return strings.Contains(body, `game_decode_failures_total{type="hello"} 1`)
+	}, 2*time.Second, 50*time.Millisecond)
+
+	assert.Contains(t, body, "game_players 1\n")
+	assert.Contains(t, body, `game_packets_received_total{type="hello"} 2`)
+	assert.Contains(t, body, `game_packets_sent_total{type="welcome"} 1`)
+	assert.Contains(t, body, `game_bytes_sent_total{type="welcome"}`)
+	assert.Contains(t, body, "# TYPE game_tick_duration_seconds histogram")
+	assert.Contains(t, body, `game_broadcast_duration_seconds_bucket{le="+Inf"}`)
+	assert.Contains(t, body, "# TYPE game_goroutines gauge")
+}
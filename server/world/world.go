@@ -0,0 +1,228 @@
+package world
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MaxWidth keeps a single map row small enough to fit in one packet.
+const MaxWidth = 960
+
+// MinSize is the smallest width and height of an open world: a wall on each
+// side with floor in between.
+const MinSize = 3
+
+var (
+	ErrEmptyMap    = errors.New("map has no tiles")
+	ErrMapTooWide  = fmt.Errorf("map is wider than %d tiles", MaxWidth)
+	ErrWorldSize   = fmt.Errorf("world must be %d to %d tiles wide and at least %d tall", MinSize, MaxWidth, MinSize)
+	ErrNoFloor     = errors.New("map has no floor to spawn on")
+	ErrInvalidTile = errors.New("invalid map tile")
+)
+
+type Tile uint8
+
+const (
+	TileFloor Tile = iota
+	TileWall
+)
+
+type Point struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// World describes the playable area as a grid of tiles. Anything outside the
+// grid counts as wall.
+type World struct {
+	Width  int
+	Height int
+	Spawns []Point
+
+	tiles []Tile
+}
+
+// CheckSize reports ErrWorldSize unless New can build a playable world of
+// the given size that clients can receive.
+func CheckSize(width, height int) error {
+	if width < MinSize || width > MaxWidth || height < MinSize {
+		return fmt.Errorf("%w: got %dx%d", ErrWorldSize, width, height)
+	}
+	return nil
+}
+
+// New creates an empty world of the given size enclosed by a wall, with a
+// single spawn point in the middle. The size is expected to pass CheckSize.
+func New(width, height int) World {
+	w := World{
+		Width:  width,
+		Height: height,
+		Spawns: []Point{{X: width / 2, Y: height / 2}},
+		tiles:  make([]Tile, width*height),
+	}
+
+	for y := range height {
+		for x := range width {
+			if x == 0 || y == 0 || x == width-1 || y == height-1 {
+				w.tiles[y*width+x] = TileWall
+			}
+		}
+	}
+
+	return w
+}
+
+// Load reads a map file. Files ending in .json are decoded as JSON, anything
+// else as a text map.
+func Load(path string) (World, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return World{}, err
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return ParseJSON(f)
+	}
+	return ParseText(f)
+}
+
+// ParseText reads a text map where '#' is a wall, '.' or ' ' is floor and
+// 'S' is a floor tile players spawn on. Short rows are padded with wall.
+func ParseText(r io.Reader) (World, error) {
+	var rows []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		rows = append(rows, strings.TrimRight(scanner.Text(), "\r"))
+	}
+	if err := scanner.Err(); err != nil {
+		return World{}, err
+	}
+
+	return fromRows(rows, nil)
+}
+
+type jsonMap struct {
+	Rows   []string `json:"rows"`
+	Spawns []Point  `json:"spawns"`
+}
+
+// ParseJSON reads a map of the form {"rows": [...], "spawns": [{"x": 1, "y": 1}]}.
+// Rows use the same characters as text maps, spawns are optional and added to
+// any 'S' tiles in the rows.
+func ParseJSON(r io.Reader) (World, error) {
+	var m jsonMap
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return World{}, err
+	}
+
+	return fromRows(m.Rows, m.Spawns)
+}
+
+func fromRows(rows []string, spawns []Point) (World, error) {
+	for len(rows) > 0 && rows[len(rows)-1] == "" {
+		rows = rows[:len(rows)-1]
+	}
+
+	width := 0
+	for _, row := range rows {
+		width = max(width, len([]rune(row)))
+	}
+	if width == 0 {
+		return World{}, ErrEmptyMap
+	}
+	if width > MaxWidth {
+		return World{}, ErrMapTooWide
+	}
+
+	w := World{
+		Width:  width,
+		Height: len(rows),
+		tiles:  make([]Tile, width*len(rows)),
+	}
+
+	for y, row := range rows {
+		for x := range width {
+			w.tiles[y*width+x] = TileWall
+		}
+
+		for x, ch := range []rune(row) {
+			switch ch {
+			case '#':
+			case '.', ' ':
+				w.tiles[y*width+x] = TileFloor
+			case 'S':
+				w.tiles[y*width+x] = TileFloor
+				w.Spawns = append(w.Spawns, Point{X: x, Y: y})
+			default:
+				return World{}, fmt.Errorf("%w %q at %d,%d", ErrInvalidTile, ch, x, y)
+			}
+		}
+	}
+
+	for _, p := range spawns {
+		if !w.Walkable(p.X, p.Y) {
+			return World{}, fmt.Errorf("%w: spawn %d,%d is not on floor", ErrInvalidTile, p.X, p.Y)
+		}
+		w.Spawns = append(w.Spawns, p)
+	}
+
+	if len(w.Spawns) == 0 {
+		for i, t := range w.tiles {
+			if t == TileFloor {
+				w.Spawns = append(w.Spawns, Point{X: i % width, Y: i / width})
+				break
+			}
+		}
+	}
+	if len(w.Spawns) == 0 {
+		return World{}, ErrNoFloor
+	}
+
+	return w, nil
+}
+
+func (w World) Tile(x, y int) Tile {
+	if x < 0 || y < 0 || x >= w.Width || y >= w.Height {
+		return TileWall
+	}
+	return w.tiles[y*w.Width+x]
+}
+
+func (w World) Walkable(x, y int) bool {
+	return w.Tile(x, y) == TileFloor
+}
+
+// Contains reports whether a player may stand at (x, y).
+func (w World) Contains(x, y float32) bool {
+	if x < 0 || y < 0 {
+		return false
+	}
+	return w.Walkable(int(x), int(y))
+}
+
+// Row returns the tiles of row y.
+func (w World) Row(y int) []Tile {
+	return w.tiles[y*w.Width : (y+1)*w.Width]
+}
+
+// Spawn returns the position the player with the given ID starts at. Players
+// are spread over the spawn points by ID.
+func (w World) Spawn(playerID int32) (float32, float32) {
+	if len(w.Spawns) == 0 {
+		return 0, 0
+	}
+
+	i := int(playerID) % len(w.Spawns)
+	if i < 0 {
+		i += len(w.Spawns)
+	}
+	p := w.Spawns[i]
+	return float32(p.X), float32(p.Y)
+}
@@ -0,0 +1,95 @@
+package world
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContains(t *testing.T) {
+	w := New(20, 10)
+
+	assert.True(t, w.Contains(1, 1))
+	assert.True(t, w.Contains(18, 8))
+	assert.False(t, w.Contains(0, 5), "left wall")
+	assert.False(t, w.Contains(19, 5), "right wall")
+	assert.False(t, w.Contains(5, 0), "top wall")
+	assert.False(t, w.Contains(5, 9), "bottom wall")
+	assert.False(t, w.Contains(-3, 40))
+	assert.False(t, w.Contains(-0.5, 5))
+}
+
+func TestSpawn(t *testing.T) {
+	x, y := New(20, 10).Spawn(3)
+	assert.Equal(t, float32(10), x)
+	assert.Equal(t, float32(5), y)
+}
+
+func TestParseText(t *testing.T) {
+	w, err := ParseText(strings.NewReader("#####\n#S..#\n#.#S#\n###\n"))
+	assert.NoError(t, err)
+
+	assert.Equal(t, 5, w.Width)
+	assert.Equal(t, 4, w.Height)
+	assert.Equal(t, []Point{{X: 1, Y: 1}, {X: 3, Y: 2}}, w.Spawns)
+	assert.True(t, w.Walkable(2, 1))
+	assert.False(t, w.Walkable(2, 2))
+	assert.False(t, w.Walkable(4, 3), "short rows are padded with wall")
+	assert.Equal(t, TileWall, w.Tile(-1, 0))
+
+	x, y := w.Spawn(1)
+	assert.Equal(t, float32(3), x)
+	assert.Equal(t, float32(2), y)
+}
+
+func TestParseTextWithoutSpawnUsesFirstFloor(t *testing.T) {
+	w, err := ParseText(strings.NewReader("###\n#.#\n###"))
+	assert.NoError(t, err)
+	assert.Equal(t, []Point{{X: 1, Y: 1}}, w.Spawns)
+}
+
+func TestParseTextErrors(t *testing.T) {
+	_, err := ParseText(strings.NewReader(""))
+	assert.ErrorIs(t, err, ErrEmptyMap)
+
+	_, err = ParseText(strings.NewReader("###\n###"))
+	assert.ErrorIs(t, err, ErrNoFloor)
+
+	_, err = ParseText(strings.NewReader("#x#"))
+	assert.ErrorIs(t, err, ErrInvalidTile)
+
+	_, err = ParseText(strings.NewReader(strings.Repeat(".", MaxWidth+1)))
+	assert.ErrorIs(t, err, ErrMapTooWide)
+}
+
+func TestParseJSON(t *testing.T) {
+	w, err := ParseJSON(strings.NewReader(`{"rows": ["####", "#..#", "####"], "spawns": [{"x": 2, "y": 1}]}`))
+	assert.NoError(t, err)
+	assert.Equal(t, 4, w.Width)
+	assert.Equal(t, []Point{{X: 2, Y: 1}}, w.Spawns)
+
+	_, err = ParseJSON(strings.NewReader(`{"rows": ["###", "#.#", "###"], "spawns": [{"x": 0, "y": 0}]}`))
+	assert.ErrorIs(t, err, ErrInvalidTile)
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	textPath := filepath.Join(dir, "map.txt")
+	assert.NoError(t, os.WriteFile(textPath, []byte("###\n#S#\n###"), 0o644))
+	w, err := Load(textPath)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, w.Height)
+
+	jsonPath := filepath.Join(dir, "map.json")
+	assert.NoError(t, os.WriteFile(jsonPath, []byte(`{"rows": ["###", "#S#", "###"]}`), 0o644))
+	w, err = Load(jsonPath)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, w.Width)
+
+	_, err = Load(filepath.Join(dir, "missing.txt"))
+	assert.Error(t, err)
+}
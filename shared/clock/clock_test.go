@@ -0,0 +1,78 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func received(c <-chan time.Time) (time.Time, bool) {
+	select {
+	case t := <-c:
+		return t, true
+	default:
+		return time.Time{}, false
+	}
+}
+
+func TestFakeTimer(t *testing.T) {
+	start := time.Unix(1000, 0)
+	clk := NewFake(start)
+	timer := clk.NewTimer(time.Second)
+	assert.Equal(t, 1, clk.Waiting())
+
+	clk.Advance(999 * time.Millisecond)
+	_, fired := received(timer.C())
+	assert.False(t, fired)
+
+	clk.Advance(time.Millisecond)
+	at, fired := received(timer.C())
+	assert.True(t, fired)
+	assert.Equal(t, start.Add(time.Second), at)
+	assert.Zero(t, clk.Waiting(), "fired timers are disarmed")
+
+	assert.False(t, timer.Reset(time.Second))
+	assert.True(t, timer.Stop())
+	clk.Advance(time.Hour)
+	_, fired = received(timer.C())
+	assert.False(t, fired, "stopped timers never fire")
+	assert.Equal(t, time.Hour+time.Second, clk.Since(start))
+}
+
+func TestFakeTicker(t *testing.T) {
+	start := time.Unix(1000, 0)
+	clk := NewFake(start)
+	ticker := clk.NewTicker(time.Second)
+	timer := clk.NewTimer(1500 * time.Millisecond)
+
+	var seen []time.Time
+	clk.Advance(time.Second)
+	at, _ := received(ticker.C())
+	seen = append(seen, at)
+
+	clk.Advance(3 * time.Second)
+	at, _ = received(ticker.C())
+	seen = append(seen, at)
+	_, fired := received(ticker.C())
+	assert.False(t, fired, "ticks nobody received are dropped")
+
+	assert.Equal(t, []time.Time{start.Add(time.Second), start.Add(2 * time.Second)}, seen)
+	at, _ = received(timer.C())
+	assert.Equal(t, start.Add(1500*time.Millisecond), at)
+
+	ticker.Stop()
+	assert.Zero(t, clk.Waiting())
+}
+
+func TestRealClock(t *testing.T) {
+	timer := Real.NewTimer(time.Millisecond)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C():
+	case <-time.After(time.Second):
+		t.Fatal("timer did not fire")
+	}
+	assert.WithinDuration(t, time.Now(), Real.Now(), time.Second)
+}
@@ -0,0 +1,33 @@
+// Package logging builds the structured logger from the LOG_LEVEL and
+// LOG_FORMAT settings.
+package logging
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+var ErrFormat = errors.New("log format must be text or json")
+
+// New returns a logger writing records at level or above to w, formatted as
+// text or json. An empty level means info and an empty format means text.
+func New(w io.Writer, level, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if level != "" {
+		if err := lvl.UnmarshalText([]byte(level)); err != nil {
+			return nil, err
+		}
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch strings.ToLower(format) {
+	case "", "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	default:
+		return nil, ErrFormat
+	}
+}
@@ -0,0 +1,34 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := New(&out, "", "")
+	assert.NoError(t, err)
+	logger.Debug("hidden")
+	logger.Info("shown", "player", 7)
+	assert.Contains(t, out.String(), "level=INFO msg=shown player=7")
+	assert.NotContains(t, out.String(), "hidden")
+
+	out.Reset()
+	logger, err = New(&out, "debug", "JSON")
+	assert.NoError(t, err)
+	logger.Debug("packet", "type", "hello")
+
+	var record map[string]any
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &record))
+	assert.Equal(t, "DEBUG", record["level"])
+	assert.Equal(t, "hello", record["type"])
+
+	_, err = New(&out, "loud", "text")
+	assert.Error(t, err)
+	_, err = New(&out, "warn", "xml")
+	assert.ErrorIs(t, err, ErrFormat)
+}
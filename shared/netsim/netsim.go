@@ -0,0 +1,132 @@
+// Package netsim degrades the packets a program sends to reproduce lag,
+// packet loss, duplication and reordering on a local network. Every decision
+// is drawn from a seeded generator, so the same seed makes the same decisions
+// for the same packets.
+package netsim
+
+import (
+	"math/rand/v2"
+	"net"
+	"sync"
+	"time"
+)
+
+// ReorderDelay is how much longer reordered packets are held back, letting
+// the packets sent after them arrive first.
+const ReorderDelay = 50 * time.Millisecond
+
+// Conditions describes the simulated network. Shares are from 0 to 1.
+type Conditions struct {
+	// Latency delays every packet, give or take up to Jitter.
+	Latency time.Duration
+	Jitter  time.Duration
+	// Loss is the share of packets dropped.
+	Loss float64
+	// Duplicate is the share of packets delivered twice.
+	Duplicate float64
+	// Reorder is the share of packets held back by ReorderDelay.
+	Reorder float64
+	Seed    uint64
+}
+
+// Enabled reports whether the conditions change anything.
+func (c Conditions) Enabled() bool {
+	return c.Latency > 0 || c.Jitter > 0 || c.Loss > 0 || c.Duplicate > 0 || c.Reorder > 0
+}
+
+// Link decides the fate of each packet sent over it.
+type Link struct {
+	conditions Conditions
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+func NewLink(conditions Conditions) *Link {
+	return &Link{
+		conditions: conditions,
+		rng:        rand.New(rand.NewPCG(conditions.Seed, conditions.Seed)),
+	}
+}
+
+// Plan decides the fate of the next packet. It returns the delay of every
+// copy to deliver, none when the packet is lost.
+func (l *Link) Plan() []time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	lost := l.rng.Float64() < l.conditions.Loss
+	copies := 1
+	if l.rng.Float64() < l.conditions.Duplicate {
+		copies = 2
+	}
+
+	delays := make([]time.Duration, 0, copies)
+	for range copies {
+		delay := l.conditions.Latency + time.Duration((l.rng.Float64()*2-1)*float64(l.conditions.Jitter))
+		if l.rng.Float64() < l.conditions.Reorder {
+			delay += ReorderDelay
+		}
+		delays = append(delays, max(delay, 0))
+	}
+
+	if lost {
+		return nil
+	}
+	return delays
+}
+
+// Send passes data to deliver as planned. Copies without delay are delivered
+// right away, the others from a timer, so data is copied first.
+func (l *Link) Send(data []byte, deliver func(data []byte)) {
+	for _, delay := range l.Plan() {
+		if delay == 0 {
+			deliver(data)
+			continue
+		}
+
+		delayed := append([]byte(nil), data...)
+		time.AfterFunc(delay, func() { deliver(delayed) })
+	}
+}
+
+// UDPConn is the part of a server socket that sends packets.
+type UDPConn interface {
+	WriteToUDP(b []byte, addr *net.UDPAddr) (int, error)
+}
+
+type udpConn struct {
+	UDPConn
+	link *Link
+}
+
+// WrapUDP degrades every packet written to conn. Writes always succeed, like
+// packets lost on the way.
+func WrapUDP(conn UDPConn, conditions Conditions) UDPConn {
+	return udpConn{UDPConn: conn, link: NewLink(conditions)}
+}
+
+func (c udpConn) WriteToUDP(b []byte, addr *net.UDPAddr) (int, error) {
+	c.link.Send(b, func(data []byte) {
+		c.UDPConn.WriteToUDP(data, addr)
+	})
+	return len(b), nil
+}
+
+// Conn is a connected socket whose writes are degraded.
+type Conn struct {
+	net.Conn
+	link *Link
+}
+
+// Wrap degrades every packet written to conn. Reads are left alone.
+func Wrap(conn net.Conn, conditions Conditions) *Conn {
+	return &Conn{Conn: conn, link: NewLink(conditions)}
+}
+
+func (c *Conn) Write(b []byte) (int, error) {
+	c.link.Send(b, func(data []byte) {
+		c.Conn.Write(data)
+	})
+	return len(b), nil
+}
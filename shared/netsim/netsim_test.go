@@ -0,0 +1,113 @@
+package netsim
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPerfectLink(t *testing.T) {
+	link := NewLink(Conditions{})
+	assert.False(t, Conditions{Seed: 7}.Enabled())
+
+	var delivered [][]byte
+	link.Send([]byte{1}, func(data []byte) { delivered = append(delivered, data) })
+	assert.Equal(t, [][]byte{{1}}, delivered, "packets without delay are delivered right away")
+}
+
+func TestSameSeedSameDecisions(t *testing.T) {
+	conditions := Conditions{Latency: 50 * time.Millisecond, Jitter: 20 * time.Millisecond, Loss: 0.2, Duplicate: 0.1, Reorder: 0.1, Seed: 42}
+	first, second := NewLink(conditions), NewLink(conditions)
+	for range 100 {
+		assert.Equal(t, first.Plan(), second.Plan())
+	}
+
+	conditions.Seed = 43
+	other := NewLink(conditions)
+	same := true
+	for range 100 {
+		same = same && assert.ObjectsAreEqual(first.Plan(), other.Plan())
+	}
+	assert.False(t, same, "another seed makes other decisions")
+}
+
+func TestConditionShares(t *testing.T) {
+	link := NewLink(Conditions{Latency: 100 * time.Millisecond, Jitter: 10 * time.Millisecond, Loss: 0.25, Duplicate: 0.5, Reorder: 0.2, Seed: 1})
+
+	const packets = 10000
+	lost, copies, reordered := 0, 0, 0
+	for range packets {
+		delays := link.Plan()
+		if delays == nil {
+			lost++
+		}
+		copies += len(delays)
+		for _, delay := range delays {
+			if delay > 110*time.Millisecond {
+				reordered++
+				assert.InDelta(t, 100*time.Millisecond+ReorderDelay, delay, float64(10*time.Millisecond))
+			} else {
+				assert.InDelta(t, 100*time.Millisecond, delay, float64(10*time.Millisecond))
+			}
+		}
+	}
+
+	assert.InDelta(t, 0.25, float64(lost)/packets, 0.02)
+	assert.InDelta(t, 1.5*0.75, float64(copies)/packets, 0.03)
+	assert.InDelta(t, 0.2, float64(reordered)/float64(copies), 0.02)
+}
+
+type recordingConn struct {
+	mu      sync.Mutex
+	written [][]byte
+}
+
+func (c *recordingConn) WriteToUDP(b []byte, addr *net.UDPAddr) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.written = append(c.written, b)
+	return len(b), nil
+}
+
+func (c *recordingConn) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.written)
+}
+
+func TestWrapUDPDelaysWrites(t *testing.T) {
+	conn := &recordingConn{}
+	wrapped := WrapUDP(conn, Conditions{Latency: 30 * time.Millisecond})
+
+	data := []byte{1, 2, 3}
+	n, err := wrapped.WriteToUDP(data, &net.UDPAddr{})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	data[0] = 9
+
+	assert.Zero(t, conn.count())
+	assert.Eventually(t, func() bool { return conn.count() == 1 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []byte{1, 2, 3}, conn.written[0], "delayed packets are copied")
+}
+
+func TestWrapDropsWrites(t *testing.T) {
+	server, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	assert.NoError(t, err)
+	defer server.Close()
+
+	client, err := net.DialUDP("udp", nil, server.LocalAddr().(*net.UDPAddr))
+	assert.NoError(t, err)
+	defer client.Close()
+
+	wrapped := Wrap(client, Conditions{Loss: 1})
+	n, err := wrapped.Write([]byte{1})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	server.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	_, _, err = server.ReadFromUDP(make([]byte, 16))
+	assert.Error(t, err, "lost packets never arrive")
+}
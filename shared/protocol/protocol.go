@@ -0,0 +1,647 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// MsgType is the first byte of every packet and selects how the rest of the
+// payload is decoded.
+type MsgType uint8
+
+const (
+	MsgPlayerUpdate MsgType = iota + 1
+	MsgHello
+	MsgWelcome
+	MsgCorrection
+	MsgMapChunk
+	MsgEntityEnter
+	MsgEntityLeave
+	MsgJoinRejected
+	MsgListRooms
+	MsgRoomList
+	MsgCreateRoom
+	MsgRoomCreated
+	MsgQueueJoin
+	MsgQueueLeave
+	MsgQueueStatus
+	MsgMatchFound
+	MsgReliable
+	MsgAck
+	MsgPlayerInfo
+	MsgChat
+	MsgChatRejected
+	MsgDisconnect
+	MsgPing
+	MsgPong
+	MsgAction
+	MsgActionResult
+)
+
+var msgNames = map[MsgType]string{
+	MsgPlayerUpdate: "player_update",
+	MsgHello:        "hello",
+	MsgWelcome:      "welcome",
+	MsgCorrection:   "correction",
+	MsgMapChunk:     "map_chunk",
+	MsgEntityEnter:  "entity_enter",
+	MsgEntityLeave:  "entity_leave",
+	MsgJoinRejected: "join_rejected",
+	MsgListRooms:    "list_rooms",
+	MsgRoomList:     "room_list",
+	MsgCreateRoom:   "create_room",
+	MsgRoomCreated:  "room_created",
+	MsgQueueJoin:    "queue_join",
+	MsgQueueLeave:   "queue_leave",
+	MsgQueueStatus:  "queue_status",
+	MsgMatchFound:   "match_found",
+	MsgReliable:     "reliable",
+	MsgAck:          "ack",
+	MsgPlayerInfo:   "player_info",
+	MsgChat:         "chat",
+	MsgChatRejected: "chat_rejected",
+	MsgDisconnect:   "disconnect",
+	MsgPing:         "ping",
+	MsgPong:         "pong",
+	MsgAction:       "action",
+	MsgActionResult: "action_result",
+}
+
+// String returns the snake_case name of the message type, or "unknown".
+func (t MsgType) String() string {
+	if name, exists := msgNames[t]; exists {
+		return name
+	}
+	return "unknown"
+}
+
+const (
+	// MaxChunkTiles bounds the tiles carried by a single MapChunk packet.
+	MaxChunkTiles = 960
+	// MaxRoomEntries bounds the rooms listed in a single RoomList packet.
+	MaxRoomEntries = 64
+	// MaxChatLength bounds the text of a chat message in bytes.
+	MaxChatLength = 200
+)
+
+var (
+	ErrEmptyMessage = errors.New("empty message")
+	ErrWrongType    = errors.New("unexpected message type")
+	ErrChunkSize    = errors.New("map chunk size does not match its header")
+	ErrJoinCode     = errors.New("join code must be 6 letters or digits")
+	ErrName         = fmt.Errorf("name must be 1 to %d printable characters", len(Name{}))
+	ErrColor        = errors.New("unknown color")
+	ErrChatSize     = errors.New("chat text does not match its header")
+)
+
+// JoinCode is the secret needed to join a private room.
+type JoinCode [6]byte
+
+func (c JoinCode) String() string {
+	return string(c[:])
+}
+
+// ParseJoinCode reads a join code typed by a player, ignoring case.
+func ParseJoinCode(s string) (JoinCode, error) {
+	var code JoinCode
+	s = strings.ToUpper(s)
+	if len(s) != len(code) {
+		return code, ErrJoinCode
+	}
+
+	for i := range len(s) {
+		ch := s[i]
+		if (ch < 'A' || ch > 'Z') && (ch < '0' || ch > '9') {
+			return code, ErrJoinCode
+		}
+		code[i] = ch
+	}
+	return code, nil
+}
+
+// Name is a player's display name, padded with zero bytes.
+type Name [16]byte
+
+func (n Name) String() string {
+	return strings.TrimRight(string(n[:]), "\x00")
+}
+
+// Valid reports whether n is a name ParseName would accept.
+func (n Name) Valid() bool {
+	parsed, err := ParseName(n.String())
+	return err == nil && parsed == n
+}
+
+// ParseName checks a display name: surrounding spaces are trimmed and the
+// rest must be printable ASCII that fits in a Name.
+func ParseName(s string) (Name, error) {
+	var name Name
+	s = strings.TrimSpace(s)
+	if len(s) == 0 || len(s) > len(name) {
+		return name, ErrName
+	}
+
+	for i := range len(s) {
+		if s[i] < ' ' || s[i] > '~' {
+			return name, ErrName
+		}
+	}
+	copy(name[:], s)
+	return name, nil
+}
+
+// Color is a player's preferred color. The values follow the ANSI color order.
+type Color uint8
+
+const (
+	ColorDefault Color = iota
+	ColorRed
+	ColorGreen
+	ColorYellow
+	ColorBlue
+	ColorMagenta
+	ColorCyan
+	ColorWhite
+)
+
+var colorNames = []string{"default", "red", "green", "yellow", "blue", "magenta", "cyan", "white"}
+
+func (c Color) String() string {
+	if int(c) < len(colorNames) {
+		return colorNames[c]
+	}
+	return "unknown"
+}
+
+// ParseColor reads a color by name. An empty name is the default color.
+func ParseColor(s string) (Color, error) {
+	if s == "" {
+		return ColorDefault, nil
+	}
+	for i, name := range colorNames {
+		if strings.EqualFold(s, name) {
+			return Color(i), nil
+		}
+	}
+	return ColorDefault, ErrColor
+}
+
+// Hello is sent by a client to join the game. RoomID 0 lets the server pick a
+// public room, a non-zero Code joins the private room it belongs to. Sending
+// Hello for another room while playing switches rooms. Names are unique
+// across the server.
+type Hello struct {
+	PlayerID int32
+	RoomID   uint32
+	Code     JoinCode
+	Name     Name
+	Color    Color
+}
+
+// Welcome answers a Hello with the room and world the client joined. The tile
+// map follows in MapChunk packets covering rows 0 to Height-1.
+type Welcome struct {
+	PlayerID int32
+	RoomID   uint32
+	Width    uint16
+	Height   uint16
+	SpawnX   float32
+	SpawnY   float32
+}
+
+type CorrectionReason uint8
+
+const (
+	ReasonOutOfBounds CorrectionReason = iota + 1
+	ReasonWall
+	// ReasonOccupied rejects a move into a cell another player stands on.
+	ReasonOccupied
+	// ReasonContested rejects a move into a cell another player moved into
+	// during the same tick.
+	ReasonContested
+	// ReasonBlocked rejects a push because the cell behind the occupant is
+	// not free.
+	ReasonBlocked
+	// ReasonPushed and ReasonSwapped tell a player it was moved by another
+	// player's move.
+	ReasonPushed
+	ReasonSwapped
+	// ReasonTeleported tells a player an operator moved it.
+	ReasonTeleported
+	// ReasonTooFast rejects a move further than the speed limit allows.
+	ReasonTooFast
+)
+
+func (r CorrectionReason) String() string {
+	switch r {
+	case ReasonOutOfBounds:
+		return "out of bounds"
+	case ReasonWall:
+		return "blocked by a wall"
+	case ReasonOccupied:
+		return "cell occupied by another player"
+	case ReasonContested:
+		return "another player moved there first"
+	case ReasonBlocked:
+		return "cannot push the other player"
+	case ReasonPushed:
+		return "pushed by another player"
+	case ReasonSwapped:
+		return "swapped with another player"
+	case ReasonTeleported:
+		return "teleported by the server"
+	case ReasonTooFast:
+		return "moving too fast"
+	}
+	return "unknown"
+}
+
+// Correction tells a client its last move was rejected and where the server
+// keeps its player instead.
+type Correction struct {
+	PlayerID int32
+	X        float32
+	Y        float32
+	Sequence uint32
+	Reason   CorrectionReason
+}
+
+// EntityLeave tells a client a player left its area of interest. Players
+// entering it are sent as MsgEntityEnter with the same payload as
+// MsgPlayerUpdate.
+type EntityLeave struct {
+	PlayerID int32
+}
+
+type RejectReason uint8
+
+const (
+	RejectRoomFull RejectReason = iota + 1
+	RejectNoSuchRoom
+	RejectNameTaken
+	RejectInvalidName
+	RejectIDTaken
+	// RejectTooManyRooms answers a request for a private room, directly or
+	// through matchmaking, once the server has as many rooms as it allows.
+	RejectTooManyRooms
+)
+
+func (r RejectReason) String() string {
+	switch r {
+	case RejectRoomFull:
+		return "room is full"
+	case RejectNoSuchRoom:
+		return "room does not exist"
+	case RejectNameTaken:
+		return "name is taken"
+	case RejectInvalidName:
+		return "name is invalid"
+	case RejectIDTaken:
+		return "player ID is taken"
+	case RejectTooManyRooms:
+		return "too many rooms"
+	}
+	return "unknown"
+}
+
+// JoinRejected answers a Hello the server could not place in a room.
+type JoinRejected struct {
+	RoomID uint32
+	Reason RejectReason
+}
+
+// LobbyRequest is the payload of MsgListRooms, MsgCreateRoom and
+// MsgQueueLeave, which a client sends from the lobby before joining a room.
+type LobbyRequest struct {
+	PlayerID int32
+}
+
+// RoomList answers MsgListRooms with the public rooms. The header is followed
+// by Count RoomEntry records.
+type RoomList struct {
+	Count uint16
+}
+
+type RoomEntry struct {
+	ID       uint32
+	Players  uint16
+	Capacity uint16
+	Width    uint16
+	Height   uint16
+}
+
+// RoomCreated answers MsgCreateRoom with a new private room. The client joins
+// it with a Hello carrying the code.
+type RoomCreated struct {
+	RoomID uint32
+	Code   JoinCode
+}
+
+// QueueJoin enters the matchmaking queue for parties of PartySize players, or
+// the server default when 0. Clients resend it to stay in the queue.
+type QueueJoin struct {
+	PlayerID  int32
+	PartySize uint8
+}
+
+// QueueStatus answers QueueJoin while the party is not complete.
+type QueueStatus struct {
+	Waiting   uint8
+	PartySize uint8
+}
+
+// MatchFound tells every queued client of a complete party which private room
+// was opened for it.
+type MatchFound struct {
+	RoomID uint32
+	Code   JoinCode
+}
+
+func EncodeRoomList(entries []RoomEntry) ([]byte, error) {
+	entries = entries[:min(len(entries), MaxRoomEntries)]
+
+	data, err := Encode(MsgRoomList, RoomList{Count: uint16(len(entries))})
+	if err != nil {
+		return nil, err
+	}
+
+	buf := bytes.NewBuffer(data)
+	if err := binary.Write(buf, binary.LittleEndian, entries); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func DecodeRoomList(data []byte) ([]RoomEntry, error) {
+	var list RoomList
+	if err := Decode(data, MsgRoomList, &list); err != nil {
+		return nil, err
+	}
+
+	entries := make([]RoomEntry, list.Count)
+	buf := bytes.NewReader(data[1+binary.Size(list):])
+	if err := binary.Read(buf, binary.LittleEndian, entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// PlayerInfo describes a player of the room. It is sent reliably to every
+// client in the room when the player joins.
+type PlayerInfo struct {
+	PlayerID int32
+	Name     Name
+	Color    Color
+}
+
+// Reliable wraps a message that must arrive. The header is followed by the
+// wrapped message, including its type byte. The receiver answers every
+// Reliable packet with an Ack carrying the same sequence number.
+type Reliable struct {
+	Seq uint32
+}
+
+type Ack struct {
+	Seq uint32
+}
+
+func EncodeReliable(seq uint32, inner []byte) ([]byte, error) {
+	data, err := Encode(MsgReliable, Reliable{Seq: seq})
+	if err != nil {
+		return nil, err
+	}
+	return append(data, inner...), nil
+}
+
+func DecodeReliable(data []byte) (uint32, []byte, error) {
+	var header Reliable
+	if err := Decode(data, MsgReliable, &header); err != nil {
+		return 0, nil, err
+	}
+
+	inner := data[1+binary.Size(header):]
+	if _, err := Type(inner); err != nil {
+		return 0, nil, err
+	}
+	return header.Seq, inner, nil
+}
+
+// ChatChannel selects who receives a chat message.
+type ChatChannel uint8
+
+const (
+	ChatRoom ChatChannel = iota + 1
+	ChatGlobal
+	ChatWhisper
+	// ChatAnnouncement is only sent by the server, to every player.
+	ChatAnnouncement
+)
+
+func (c ChatChannel) String() string {
+	switch c {
+	case ChatRoom:
+		return "room"
+	case ChatGlobal:
+		return "global"
+	case ChatWhisper:
+		return "whisper"
+	case ChatAnnouncement:
+		return "announcement"
+	}
+	return "unknown"
+}
+
+// Chat carries a chat message. Clients send it with To naming the player to
+// whisper to, and the server relays it with From set to the sender. Both
+// directions use the reliable channel. The header is followed by Length bytes
+// of UTF-8 text.
+type Chat struct {
+	Channel ChatChannel
+	From    Name
+	To      Name
+	Length  uint16
+}
+
+type ChatRejectReason uint8
+
+const (
+	ChatEmpty ChatRejectReason = iota + 1
+	ChatTooLong
+	ChatRateLimited
+	ChatNoSuchPlayer
+)
+
+func (r ChatRejectReason) String() string {
+	switch r {
+	case ChatEmpty:
+		return "message is empty"
+	case ChatTooLong:
+		return "message is too long"
+	case ChatRateLimited:
+		return "sending messages too fast"
+	case ChatNoSuchPlayer:
+		return "no such player"
+	}
+	return "unknown"
+}
+
+// ChatRejected tells a client its chat message was not delivered.
+type ChatRejected struct {
+	Reason ChatRejectReason
+}
+
+func EncodeChat(chat Chat, text string) ([]byte, error) {
+	if len(text) > math.MaxUint16 {
+		return nil, ErrChatSize
+	}
+	chat.Length = uint16(len(text))
+
+	data, err := Encode(MsgChat, chat)
+	if err != nil {
+		return nil, err
+	}
+	return append(data, text...), nil
+}
+
+func DecodeChat(data []byte) (Chat, string, error) {
+	var chat Chat
+	if err := Decode(data, MsgChat, &chat); err != nil {
+		return chat, "", err
+	}
+
+	text := data[1+binary.Size(chat):]
+	if len(text) != int(chat.Length) {
+		return chat, "", ErrChatSize
+	}
+	return chat, string(text), nil
+}
+
+type DisconnectReason uint8
+
+const (
+	DisconnectKicked DisconnectReason = iota + 1
+	DisconnectBanned
+	// DisconnectInvalidMovement kicks a player that broke the movement
+	// rules too often.
+	DisconnectInvalidMovement
+)
+
+func (r DisconnectReason) String() string {
+	switch r {
+	case DisconnectKicked:
+		return "kicked by the server"
+	case DisconnectBanned:
+		return "banned from the server"
+	case DisconnectInvalidMovement:
+		return "kicked for invalid movement"
+	}
+	return "unknown"
+}
+
+// Disconnect tells a client the server removed it from its room.
+type Disconnect struct {
+	Reason DisconnectReason
+}
+
+// Ping measures the round trip time between the server and a client. The
+// other side answers with MsgPong carrying the same payload.
+type Ping struct {
+	Seq    uint32
+	SentAt int64
+}
+
+type ActionKind uint8
+
+const (
+	// ActionTag tags another player within reach.
+	ActionTag ActionKind = iota + 1
+)
+
+// Action is an interaction of a player with another player. The server judges
+// it against where the target was when the client saw it: ViewDelayMs is how
+// old the target's state shown by the client was when the action was taken.
+type Action struct {
+	PlayerID    int32
+	Kind        ActionKind
+	Target      int32
+	Sequence    uint32
+	ViewDelayMs uint16
+}
+
+// ActionResult tells the actor, and the target when it was hit, how an action
+// was judged. RewindMs is how far back the server looked.
+type ActionResult struct {
+	PlayerID int32
+	Kind     ActionKind
+	Target   int32
+	Sequence uint32
+	Hit      bool
+	RewindMs uint16
+}
+
+// MapChunk carries Rows full rows of the tile map starting at StartRow. The
+// header is followed by Rows*Width tile bytes.
+type MapChunk struct {
+	StartRow uint16
+	Rows     uint16
+	Width    uint16
+}
+
+func EncodeMapChunk(chunk MapChunk, tiles []byte) ([]byte, error) {
+	if len(tiles) != int(chunk.Rows)*int(chunk.Width) {
+		return nil, ErrChunkSize
+	}
+
+	data, err := Encode(MsgMapChunk, chunk)
+	if err != nil {
+		return nil, err
+	}
+	return append(data, tiles...), nil
+}
+
+func DecodeMapChunk(data []byte) (MapChunk, []byte, error) {
+	var chunk MapChunk
+	if err := Decode(data, MsgMapChunk, &chunk); err != nil {
+		return chunk, nil, err
+	}
+
+	tiles := data[1+binary.Size(chunk):]
+	if len(tiles) != int(chunk.Rows)*int(chunk.Width) {
+		return chunk, nil, ErrChunkSize
+	}
+	return chunk, tiles, nil
+}
+
+func Type(data []byte) (MsgType, error) {
+	if len(data) == 0 {
+		return 0, ErrEmptyMessage
+	}
+	return MsgType(data[0]), nil
+}
+
+func Encode(msgType MsgType, payload any) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	buf.WriteByte(byte(msgType))
+	err := binary.Write(buf, binary.LittleEndian, payload)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func Decode(data []byte, msgType MsgType, payload any) error {
+	t, err := Type(data)
+	if err != nil {
+		return err
+	}
+	if t != msgType {
+		return ErrWrongType
+	}
+
+	buf := bytes.NewReader(data[1:])
+	return binary.Read(buf, binary.LittleEndian, payload)
+}
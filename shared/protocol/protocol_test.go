@@ -0,0 +1,147 @@
+package protocol
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	welcome := Welcome{PlayerID: 3, RoomID: 2, Width: 20, Height: 10, SpawnX: 10, SpawnY: 5}
+
+	data, err := Encode(MsgWelcome, welcome)
+	assert.NoError(t, err)
+
+	msgType, err := Type(data)
+	assert.NoError(t, err)
+	assert.Equal(t, MsgWelcome, msgType)
+
+	var decoded Welcome
+	assert.NoError(t, Decode(data, MsgWelcome, &decoded))
+	assert.Equal(t, welcome, decoded)
+
+	var hello Hello
+	assert.ErrorIs(t, Decode(data, MsgHello, &hello), ErrWrongType)
+}
+
+func TestDecodeInvalidData(t *testing.T) {
+	_, err := Type(nil)
+	assert.ErrorIs(t, err, ErrEmptyMessage)
+
+	var welcome Welcome
+	assert.Error(t, Decode([]byte{byte(MsgWelcome), 1, 2}, MsgWelcome, &welcome))
+}
+
+func TestMapChunk(t *testing.T) {
+	chunk := MapChunk{StartRow: 2, Rows: 2, Width: 3}
+	tiles := []byte{1, 1, 1, 1, 0, 1}
+
+	data, err := EncodeMapChunk(chunk, tiles)
+	assert.NoError(t, err)
+
+	decoded, decodedTiles, err := DecodeMapChunk(data)
+	assert.NoError(t, err)
+	assert.Equal(t, chunk, decoded)
+	assert.Equal(t, tiles, decodedTiles)
+
+	_, err = EncodeMapChunk(chunk, tiles[:4])
+	assert.ErrorIs(t, err, ErrChunkSize)
+
+	_, _, err = DecodeMapChunk(data[:len(data)-1])
+	assert.ErrorIs(t, err, ErrChunkSize)
+}
+
+func TestRoomList(t *testing.T) {
+	entries := []RoomEntry{
+		{ID: 1, Players: 3, Capacity: 8, Width: 40, Height: 17},
+		{ID: 4, Players: 0, Capacity: 0, Width: 20, Height: 10},
+	}
+
+	data, err := EncodeRoomList(entries)
+	assert.NoError(t, err)
+
+	decoded, err := DecodeRoomList(data)
+	assert.NoError(t, err)
+	assert.Equal(t, entries, decoded)
+
+	_, err = DecodeRoomList(data[:len(data)-1])
+	assert.Error(t, err)
+
+	empty, err := EncodeRoomList(nil)
+	assert.NoError(t, err)
+	decoded, err = DecodeRoomList(empty)
+	assert.NoError(t, err)
+	assert.Empty(t, decoded)
+}
+
+func TestParseJoinCode(t *testing.T) {
+	code, err := ParseJoinCode("ab12cd")
+	assert.NoError(t, err)
+	assert.Equal(t, "AB12CD", code.String())
+
+	for _, invalid := range []string{"", "ABC", "ABCDEFG", "AB-2CD"} {
+		_, err := ParseJoinCode(invalid)
+		assert.ErrorIs(t, err, ErrJoinCode, invalid)
+	}
+}
+
+func TestParseName(t *testing.T) {
+	name, err := ParseName("  alice ")
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", name.String())
+	assert.True(t, name.Valid())
+
+	for _, invalid := range []string{"", "   ", "a name that is too long", "tab\there", "ünicode"} {
+		_, err := ParseName(invalid)
+		assert.ErrorIs(t, err, ErrName, invalid)
+	}
+
+	assert.False(t, Name{}.Valid())
+	assert.False(t, Name{'a', 0, 'b'}.Valid())
+}
+
+func TestParseColor(t *testing.T) {
+	color, err := ParseColor("Cyan")
+	assert.NoError(t, err)
+	assert.Equal(t, ColorCyan, color)
+	assert.Equal(t, "cyan", color.String())
+
+	color, err = ParseColor("")
+	assert.NoError(t, err)
+	assert.Equal(t, ColorDefault, color)
+
+	_, err = ParseColor("mauve")
+	assert.ErrorIs(t, err, ErrColor)
+}
+
+func TestReliable(t *testing.T) {
+	inner, err := Encode(MsgPlayerInfo, PlayerInfo{PlayerID: 2, Color: ColorRed})
+	assert.NoError(t, err)
+
+	data, err := EncodeReliable(7, inner)
+	assert.NoError(t, err)
+
+	seq, decoded, err := DecodeReliable(data)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(7), seq)
+	assert.Equal(t, inner, decoded)
+
+	_, _, err = DecodeReliable(data[:5])
+	assert.ErrorIs(t, err, ErrEmptyMessage)
+}
+
+func TestChat(t *testing.T) {
+	to, err := ParseName("bob")
+	assert.NoError(t, err)
+
+	data, err := EncodeChat(Chat{Channel: ChatWhisper, To: to}, "hi bob")
+	assert.NoError(t, err)
+
+	chat, text, err := DecodeChat(data)
+	assert.NoError(t, err)
+	assert.Equal(t, Chat{Channel: ChatWhisper, To: to, Length: 6}, chat)
+	assert.Equal(t, "hi bob", text)
+
+	_, _, err = DecodeChat(data[:len(data)-1])
+	assert.ErrorIs(t, err, ErrChatSize)
+}
@@ -0,0 +1,149 @@
+// Package reliable delivers messages that must not be lost, such as player
+// metadata, in order on top of UDP. Every message is resent until the peer
+// acknowledges it.
+package reliable
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/zainokta/client-server-multiplayer/shared/protocol"
+)
+
+const (
+	ResendInterval = 200 * time.Millisecond
+	// MaxAttempts bounds how often a message is sent before the peer is
+	// considered gone.
+	MaxAttempts = 25
+	// WindowSize bounds how far ahead of the next expected message the
+	// receiver buffers. Anything further is ignored and arrives again later.
+	WindowSize = 256
+)
+
+// ErrUndelivered reports a message the peer never acknowledged. Every later
+// message waits for it, so the channel is broken and the peer should be
+// dropped.
+var ErrUndelivered = errors.New("message was never acknowledged")
+
+type pending struct {
+	data     []byte
+	sent     time.Time
+	attempts int
+}
+
+// Endpoint is one side of a reliable channel with a single peer.
+type Endpoint struct {
+	mu       sync.Mutex
+	nextSend uint32
+	pending  map[uint32]*pending
+	nextRecv uint32
+	buffered map[uint32][]byte
+}
+
+func NewEndpoint() *Endpoint {
+	return &Endpoint{
+		nextSend: 1,
+		pending:  make(map[uint32]*pending),
+		nextRecv: 1,
+		buffered: make(map[uint32][]byte),
+	}
+}
+
+// Wrap assigns the message the next sequence number and keeps it until it is
+// acknowledged. It returns the packet to send now.
+func (e *Endpoint) Wrap(inner []byte, now time.Time) ([]byte, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	data, err := protocol.EncodeReliable(e.nextSend, inner)
+	if err != nil {
+		return nil, err
+	}
+
+	e.pending[e.nextSend] = &pending{data: data, sent: now, attempts: 1}
+	e.nextSend++
+	return data, nil
+}
+
+// Ack stops resending the message with the given sequence number.
+func (e *Endpoint) Ack(seq uint32) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	delete(e.pending, seq)
+}
+
+// Resend returns the packets that were not acknowledged within
+// ResendInterval. Messages out of attempts are dropped and reported with
+// ErrUndelivered.
+func (e *Endpoint) Resend(now time.Time) ([][]byte, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var due [][]byte
+	var err error
+	for seq, p := range e.pending {
+		if now.Sub(p.sent) < ResendInterval {
+			continue
+		}
+		if p.attempts >= MaxAttempts {
+			delete(e.pending, seq)
+			err = ErrUndelivered
+			continue
+		}
+
+		p.sent = now
+		p.attempts++
+		due = append(due, p.data)
+	}
+	return due, err
+}
+
+// Pending returns how many messages wait for an acknowledgement.
+func (e *Endpoint) Pending() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return len(e.pending)
+}
+
+// Receive handles a Reliable packet from the peer. It returns the Ack to send
+// back, if any, and the messages that are now ready in order. Duplicates are
+// acknowledged again but not delivered twice.
+func (e *Endpoint) Receive(data []byte) ([]byte, [][]byte, error) {
+	seq, inner, err := protocol.DecodeReliable(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if seq >= e.nextRecv && seq-e.nextRecv >= WindowSize {
+		return nil, nil, nil
+	}
+
+	ack, err := protocol.Encode(protocol.MsgAck, protocol.Ack{Seq: seq})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if seq < e.nextRecv {
+		return ack, nil, nil
+	}
+
+	e.buffered[seq] = append([]byte(nil), inner...)
+
+	var ready [][]byte
+	for {
+		next, exists := e.buffered[e.nextRecv]
+		if !exists {
+			break
+		}
+		delete(e.buffered, e.nextRecv)
+		ready = append(ready, next)
+		e.nextRecv++
+	}
+	return ack, ready, nil
+}
@@ -0,0 +1,111 @@
+package reliable
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zainokta/client-server-multiplayer/shared/protocol"
+)
+
+func message(t *testing.T, id int32) []byte {
+	data, err := protocol.Encode(protocol.MsgPlayerInfo, protocol.PlayerInfo{PlayerID: id})
+	assert.NoError(t, err)
+	return data
+}
+
+func TestResendUntilAcknowledged(t *testing.T) {
+	e := NewEndpoint()
+	now := time.Now()
+
+	first, err := e.Wrap(message(t, 1), now)
+	assert.NoError(t, err)
+	_, err = e.Wrap(message(t, 2), now)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, e.Pending())
+
+	due, err := e.Resend(now.Add(ResendInterval / 2))
+	assert.NoError(t, err)
+	assert.Empty(t, due, "nothing is due before the interval")
+
+	e.Ack(2)
+	due, err = e.Resend(now.Add(ResendInterval))
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{first}, due)
+
+	e.Ack(1)
+	assert.Zero(t, e.Pending())
+	due, err = e.Resend(now.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Empty(t, due)
+}
+
+func TestResendGivesUp(t *testing.T) {
+	e := NewEndpoint()
+	now := time.Now()
+
+	_, err := e.Wrap(message(t, 1), now)
+	assert.NoError(t, err)
+
+	sent := 1
+	for i := 1; i < MaxAttempts; i++ {
+		due, err := e.Resend(now.Add(time.Duration(i) * ResendInterval))
+		assert.NoError(t, err)
+		sent += len(due)
+	}
+	assert.Equal(t, MaxAttempts, sent)
+
+	due, err := e.Resend(now.Add(MaxAttempts * ResendInterval))
+	assert.ErrorIs(t, err, ErrUndelivered, "the caller learns the message is lost")
+	assert.Empty(t, due)
+	assert.Zero(t, e.Pending())
+}
+
+func TestReceiveInOrder(t *testing.T) {
+	sender := NewEndpoint()
+	receiver := NewEndpoint()
+	now := time.Now()
+
+	var packets [][]byte
+	for id := int32(1); id <= 3; id++ {
+		data, err := sender.Wrap(message(t, id), now)
+		assert.NoError(t, err)
+		packets = append(packets, data)
+	}
+
+	ack, ready, err := receiver.Receive(packets[1])
+	assert.NoError(t, err)
+	assert.Empty(t, ready, "the second message waits for the first")
+
+	var acked protocol.Ack
+	assert.NoError(t, protocol.Decode(ack, protocol.MsgAck, &acked))
+	assert.Equal(t, uint32(2), acked.Seq)
+
+	_, ready, err = receiver.Receive(packets[0])
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{message(t, 1), message(t, 2)}, ready)
+
+	ack, ready, err = receiver.Receive(packets[0])
+	assert.NoError(t, err)
+	assert.NotNil(t, ack, "duplicates are acknowledged again")
+	assert.Empty(t, ready, "but not delivered twice")
+
+	_, ready, err = receiver.Receive(packets[2])
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{message(t, 3)}, ready)
+}
+
+func TestReceiveIgnoresBeyondWindow(t *testing.T) {
+	receiver := NewEndpoint()
+
+	data, err := protocol.EncodeReliable(WindowSize+1, message(t, 1))
+	assert.NoError(t, err)
+
+	ack, ready, err := receiver.Receive(data)
+	assert.NoError(t, err)
+	assert.Nil(t, ack)
+	assert.Empty(t, ready)
+
+	_, _, err = receiver.Receive([]byte{byte(protocol.MsgReliable)})
+	assert.Error(t, err)
+}